@@ -0,0 +1,124 @@
+package main
+
+import (
+	"URL-shortener/internal/config"
+	"URL-shortener/internal/lib/apikey"
+	"URL-shortener/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const keysUsage = `usage: url-shortener keys create NAME SCOPE[,SCOPE...]
+       url-shortener keys list
+       url-shortener keys revoke ID
+
+scopes: create, update, delete, read-stats, admin`
+
+// runKeys implements the "keys" subcommand and returns the exit code.
+// It is how the first admin key is created, later keys can be managed
+// through the /admin/keys endpoints as well.
+func runKeys(cfg *config.Config, log *slog.Logger, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, keysUsage)
+		return 2
+	}
+
+	st, _, closeStorage, err := setupStorage(cfg, log)
+	if err != nil {
+		log.Error("Failed to init storage", slog.String("error", err.Error()))
+		return 1
+	}
+	defer func() {
+		if err := closeStorage(); err != nil {
+			log.Error("Failed to close storage", slog.String("error", err.Error()))
+		}
+	}()
+
+	ctx := context.Background()
+
+	switch {
+	case args[0] == "create" && len(args) == 3:
+		scopes := strings.Split(args[2], ",")
+		for _, scope := range scopes {
+			if !apikey.ValidScope(scope) {
+				fmt.Fprintf(os.Stderr, "unknown scope %q\n\n%s\n", scope, keysUsage)
+				return 2
+			}
+		}
+
+		secret, prefix, hash, err := apikey.Generate()
+		if err != nil {
+			log.Error("Failed to generate API key", slog.String("error", err.Error()))
+			return 1
+		}
+
+		id, err := st.SaveAPIKey(ctx, storage.APIKey{
+			Name:      args[1],
+			Prefix:    prefix,
+			Hash:      hash,
+			Scopes:    scopes,
+			CreatedAt: time.Now().UTC(),
+		})
+		if err != nil {
+			log.Error("Failed to save API key", slog.String("error", err.Error()))
+			return 1
+		}
+
+		log.Info("API key created", slog.Int64("key_id", id), slog.String("name", args[1]))
+
+		// The key is printed last, on a line of its own, and is never shown again.
+		fmt.Println(secret)
+	case args[0] == "list" && len(args) == 1:
+		keys, err := st.ListAPIKeys(ctx)
+		if err != nil {
+			log.Error("Failed to list API keys", slog.String("error", err.Error()))
+			return 1
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tNAME\tPREFIX\tSCOPES\tCREATED AT\tLAST USED AT\tREVOKED AT")
+		for _, key := range keys {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Name, key.Prefix, strings.Join(key.Scopes, ","),
+				key.CreatedAt.Format(time.RFC3339), formatOptionalTime(key.LastUsedAt, "never"), formatOptionalTime(key.RevokedAt, "-"))
+		}
+		_ = w.Flush()
+	case args[0] == "revoke" && len(args) == 2:
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, keysUsage)
+			return 2
+		}
+
+		err = st.RevokeAPIKey(ctx, id)
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			log.Error("No active API key with this id", slog.Int64("key_id", id))
+			return 1
+		}
+		if err != nil {
+			log.Error("Failed to revoke API key", slog.String("error", err.Error()))
+			return 1
+		}
+
+		log.Info("API key revoked", slog.Int64("key_id", id))
+	default:
+		fmt.Fprintln(os.Stderr, keysUsage)
+		return 2
+	}
+
+	return 0
+}
+
+func formatOptionalTime(t time.Time, zero string) string {
+	if t.IsZero() {
+		return zero
+	}
+
+	return t.Format(time.RFC3339)
+}
@@ -2,21 +2,56 @@ package main
 
 import (
 	"URL-shortener/internal/config"
+	"URL-shortener/internal/http-server/handlers/admin/keys"
 	"URL-shortener/internal/http-server/handlers/delete"
+	"URL-shortener/internal/http-server/handlers/health"
+	"URL-shortener/internal/http-server/handlers/preview"
 	"URL-shortener/internal/http-server/handlers/redirect"
+	"URL-shortener/internal/http-server/handlers/url/batch"
+	"URL-shortener/internal/http-server/handlers/url/history"
+	"URL-shortener/internal/http-server/handlers/url/link"
+	"URL-shortener/internal/http-server/handlers/url/list"
 	"URL-shortener/internal/http-server/handlers/url/save"
+	"URL-shortener/internal/http-server/handlers/url/stats"
+	"URL-shortener/internal/http-server/handlers/url/transfer"
+	"URL-shortener/internal/http-server/handlers/url/update"
+	"URL-shortener/internal/http-server/middleware/auth"
+	"URL-shortener/internal/http-server/middleware/deadline"
+	"URL-shortener/internal/http-server/middleware/idempotency"
+	"URL-shortener/internal/http-server/middleware/instrument"
+	"URL-shortener/internal/http-server/middleware/legacystatus"
 	"URL-shortener/internal/http-server/middleware/logger"
+	"URL-shortener/internal/http-server/middleware/tracing"
+	"URL-shortener/internal/lib/aliaspolicy"
+	"URL-shortener/internal/lib/apikey"
 	"URL-shortener/internal/lib/logger/handlers/slogpretty"
+	"URL-shortener/internal/lib/metrics"
+	"URL-shortener/internal/lib/telemetry"
 	storage "URL-shortener/internal/storage"
+	"URL-shortener/internal/storage/cache"
+	"URL-shortener/internal/storage/clicks"
+	"URL-shortener/internal/storage/janitor"
+	"URL-shortener/internal/storage/memory"
+	"URL-shortener/internal/storage/migrate"
+	"URL-shortener/internal/storage/sqlite"
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 )
 
 const (
@@ -32,44 +67,214 @@ func main() {
 	log.Info("Starting url-shortener", slog.String("env", cfg.Env))
 	log.Debug("Debug messages are enabled")
 
-	db, err := sql.Open("postgres", cfg.DB_DSN)
-	if err != nil {
-		log.Error("Failed to open database", slog.String("error", err.Error()))
-		os.Exit(1)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(runMigrate(cfg, log, os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "keys" {
+		os.Exit(runKeys(cfg, log, os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		os.Exit(runExport(cfg, log, os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(cfg, log, os.Args[2:]))
 	}
-	defer db.Close()
 
-	if err := db.Ping(); err != nil {
-		log.Error("Failed to ping database", slog.String("error", err.Error()))
-		os.Exit(1)
+	os.Exit(runServer(cfg, log))
+}
+
+// runServer serves HTTP until SIGINT or SIGTERM and returns the exit code.
+// On a signal it reports not ready, stops accepting connections, waits for
+// in-flight requests and background work, and only then closes the storage.
+func runServer(cfg *config.Config, log *slog.Logger) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	tp, err := telemetry.Setup(ctx, telemetry.Options{
+		Exporter:     cfg.Tracing.Exporter,
+		ServiceName:  cfg.Tracing.ServiceName,
+		SampleRatio:  cfg.Tracing.SampleRatio,
+		OTLPEndpoint: cfg.Tracing.OTLPEndpoint,
+		OTLPInsecure: cfg.Tracing.OTLPInsecure,
+	})
+	if err != nil {
+		log.Error("Failed to init tracing", slog.String("error", err.Error()))
+		return 1
 	}
+	// Shut down last, so spans of the final click flush are exported too.
+	defer func() {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.ShutdownTimeout)
+		defer cancel()
 
-	st, err := storage.New(db)
+		if err := tp.Shutdown(shutdownCtx); err != nil {
+			log.Error("Failed to flush traces", slog.String("error", err.Error()))
+		}
+	}()
+
+	st, db, closeStorage, err := setupStorage(cfg, log)
 	if err != nil {
 		log.Error("Failed to init storage", slog.String("error", err.Error()))
-		os.Exit(1)
+		return 1
 	}
+	defer func() {
+		if err := closeStorage(); err != nil {
+			log.Error("Failed to close storage", slog.String("error", err.Error()))
+		}
+	}()
 
 	log.Info("Database initialized successfully")
 
+	st = storage.WithTimeouts(st, storage.Timeouts{
+		Get:        cfg.DB.Timeouts.Get,
+		Save:       cfg.DB.Timeouts.Save,
+		Delete:     cfg.DB.Timeouts.Delete,
+		Stats:      cfg.DB.Timeouts.Stats,
+		Purge:      cfg.DB.Timeouts.Purge,
+		SaveClicks: cfg.DB.Timeouts.SaveClicks,
+	})
+	var redirectCache *cache.Cache
+	if cfg.Cache.Enabled {
+		redirectCache = cache.New(st, cfg.Cache.Size, cfg.Cache.TTL, cfg.Cache.NegativeTTL)
+		st = redirectCache
+	}
+
+	// Background work gets its own context, it is stopped only after
+	// the server has drained so in-flight requests still see it running.
+	bgCtx, cancelBg := context.WithCancel(context.Background())
+	var bg sync.WaitGroup
+	defer bg.Wait()
+	defer cancelBg()
+
+	if cfg.Janitor.Enabled {
+		j := janitor.New(log, st, cfg.Janitor.Interval, cfg.Janitor.BatchSize)
+
+		bg.Add(1)
+		go func() {
+			defer bg.Done()
+			j.Run(bgCtx)
+		}()
+	}
+
+	clickRecorder := clicks.NewRecorder(log, st, cfg.Clicks.BufferSize, cfg.Clicks.BatchSize, cfg.Clicks.FlushInterval)
+	defer clickRecorder.Close()
+
+	aliasPolicy, err := newAliasPolicy(cfg)
+	if err != nil {
+		log.Error("Failed to init alias policy", slog.String("error", err.Error()))
+		return 1
+	}
+
+	readiness := health.NewReadiness()
+
+	m := metrics.New()
+	if db != nil {
+		m.RegisterDB(db, cfg.Storage.Driver)
+	}
+	if redirectCache != nil {
+		m.RegisterCache(redirectCache)
+	}
+	m.RegisterDroppedClicks(clickRecorder.Dropped)
+
 	router := chi.NewRouter()
 	router.Use(middleware.RequestID)
+	router.Use(tracing.New(tp, telemetry.Propagator()))
 	router.Use(middleware.RealIP)
 	router.Use(middleware.Logger)
 	router.Use(logger.New(log))
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.URLFormat)
+	// The transfer routes set their own, longer deadline.
+	router.Use(middleware.Maybe(deadline.New(cfg.HTTPServer.Timeout), func(r *http.Request) bool {
+		return r.URL.Path != "/url/export" && r.URL.Path != "/url/import"
+	}))
+	if cfg.HTTPServer.LegacyStatusCodes {
+		log.Warn("Legacy status codes are enabled, this mode will be removed in the next release")
+		// Only the handlers that existed before 1.0 answer the old way.
+		router.Use(legacystatus.New(
+			http.MethodPost+" /url/",
+			http.MethodGet+" /{alias}",
+			http.MethodDelete+" /url/{alias}",
+		))
+	}
+	if cfg.Metrics.Enabled {
+		// Registered after legacystatus to count the real outcome.
+		router.Use(instrument.New(m, map[string]string{
+			http.MethodPost + " /url/":          "save",
+			http.MethodPost + " /url/batch":     "batch_save",
+			http.MethodPost + " /url/import":    "import",
+			http.MethodGet + " /{alias}":        "redirect",
+			http.MethodPatch + " /url/{alias}":  "update",
+			http.MethodDelete + " /url/{alias}": "delete",
+		}))
+	}
+
+	readyChecks, err := readinessChecks(cfg, db)
+	if err != nil {
+		log.Error("Failed to init readiness checks", slog.String("error", err.Error()))
+		return 1
+	}
+
+	router.Get("/healthz", health.Live())
+	router.Get("/readyz", health.Ready(log, readiness, cfg.Health.CheckTimeout, readyChecks))
+	router.Get("/version", health.Version())
+
+	var adminSrv *http.Server
+	if cfg.Metrics.Enabled {
+		if cfg.Metrics.AdminAddress == "" {
+			router.Method(http.MethodGet, cfg.Metrics.Path, m.Handler())
+		} else {
+			admin := chi.NewRouter()
+			admin.Method(http.MethodGet, cfg.Metrics.Path, m.Handler())
+
+			adminSrv = &http.Server{
+				Addr:        cfg.Metrics.AdminAddress,
+				Handler:     admin,
+				ReadTimeout: cfg.HTTPServer.Timeout,
+				IdleTimeout: cfg.HTTPServer.IdleTimeout,
+			}
+		}
+	}
+
+	authOpts, err := authOptions(cfg)
+	if err != nil {
+		log.Error("Failed to init authentication", slog.String("error", err.Error()))
+		return 1
+	}
+	authenticate := auth.New(log, st, authOpts)
 
 	router.Route("/url", func(r chi.Router) {
-		r.Use(middleware.BasicAuth("Url-shortener", map[string]string{
-			cfg.HTTPServer.User: cfg.HTTPServer.Password,
-		}))
+		r.Use(authenticate)
 
-		r.Post("/", save.New(log, st))
-		r.Delete("/{alias}", delete.New(log, st))
+		idempotent := idempotency.New(log, st, cfg.Idempotency.Window)
+
+		r.With(auth.RequireScope(apikey.ScopeCreate), idempotent).Post("/", save.New(log, st, aliasPolicy))
+		r.With(auth.RequireScope(apikey.ScopeCreate), idempotent).Post("/batch", batch.New(log, st, aliasPolicy))
+		r.With(auth.RequireScope(apikey.ScopeReadStats)).Get("/", list.New(log, st, aliasPolicy))
+		r.With(auth.RequireScope(apikey.ScopeReadStats), deadline.New(cfg.HTTPServer.TransferTimeout)).Get("/export", transfer.Export(log, st))
+		r.With(auth.RequireScope(apikey.ScopeCreate), deadline.New(cfg.HTTPServer.TransferTimeout)).Post("/import", transfer.Import(log, st, aliasPolicy))
+		r.With(auth.RequireScope(apikey.ScopeUpdate)).Patch("/{alias}", update.New(log, st, aliasPolicy))
+		r.With(auth.RequireScope(apikey.ScopeDelete)).Delete("/{alias}", delete.New(log, st, aliasPolicy))
+		r.With(auth.RequireScope(apikey.ScopeReadStats)).Get("/{alias}", link.New(log, st, aliasPolicy))
+		r.With(auth.RequireScope(apikey.ScopeReadStats)).Get("/{alias}/stats", stats.New(log, st, aliasPolicy))
+		r.With(auth.RequireScope(apikey.ScopeReadStats)).Get("/{alias}/history", history.New(log, st, aliasPolicy))
 	})
 
-	router.Get("/{alias}", redirect.New(log, st))
+	router.Route("/admin", func(r chi.Router) {
+		r.Use(authenticate)
+		r.Use(auth.RequireScope(apikey.ScopeAdmin))
+
+		r.Post("/keys", keys.Create(log, st))
+		r.Get("/keys", keys.List(log, st))
+		r.Delete("/keys/{id}", keys.Revoke(log, st))
+	})
+
+	router.Get("/{alias}", redirect.New(log, st, aliasPolicy, clickRecorder))
+	router.Get("/{alias}+", preview.New(log, st, aliasPolicy))
+
+	if err := checkReservedRoutes(router, aliasPolicy); err != nil {
+		log.Error("Route segments are not reserved", slog.String("error", err.Error()))
+		return 1
+	}
 
 	log.Info("Starting server", slog.String("address", cfg.HTTPServer.Address))
 
@@ -81,12 +286,122 @@ func main() {
 		IdleTimeout:  cfg.HTTPServer.IdleTimeout,
 	}
 
-	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	serveErr := make(chan error, 2)
+	go func() {
+		serveErr <- srv.ListenAndServe()
+	}()
+	if adminSrv != nil {
+		log.Info("Starting admin server", slog.String("address", adminSrv.Addr))
+
+		go func() {
+			serveErr <- adminSrv.ListenAndServe()
+		}()
+	}
+
+	readiness.SetReady(true)
+
+	select {
+	case err := <-serveErr:
 		log.Error("Failed to start server", slog.String("error", err.Error()))
-		os.Exit(1)
+		return 1
+	case <-ctx.Done():
+	}
+
+	// A second signal skips the graceful part.
+	stop()
+
+	log.Info("Shutting down", slog.Duration("delay", cfg.HTTPServer.ShutdownDelay), slog.Duration("timeout", cfg.HTTPServer.ShutdownTimeout))
+
+	// Give load balancers time to notice the instance is not ready
+	// before it stops accepting connections.
+	readiness.SetReady(false)
+	time.Sleep(cfg.HTTPServer.ShutdownDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTPServer.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Error("Failed to drain connections", slog.String("error", err.Error()))
+		return 1
 	}
+	if adminSrv != nil {
+		// Shut down last, so metrics can be scraped while draining.
+		if err := adminSrv.Shutdown(shutdownCtx); err != nil {
+			log.Error("Failed to stop admin server", slog.String("error", err.Error()))
+			return 1
+		}
+	}
+
+	log.Info("Server stopped", slog.String("address", cfg.HTTPServer.Address))
 
-	log.Error("Server stopped", slog.String("address", cfg.HTTPServer.Address))
+	return 0
+}
+
+// authOptions checks the auth mode and collects the BasicAuth credential.
+func authOptions(cfg *config.Config) (auth.Options, error) {
+	opts := auth.Options{Mode: cfg.Auth.Mode, Realm: "Url-shortener"}
+
+	switch cfg.Auth.Mode {
+	case auth.ModeAPIKey:
+		return opts, nil
+	case auth.ModeBasic, auth.ModeBoth:
+	default:
+		return auth.Options{}, fmt.Errorf("unknown auth mode %q", cfg.Auth.Mode)
+	}
+
+	if cfg.HTTPServer.User == "" || cfg.HTTPServer.Password == "" {
+		return auth.Options{}, fmt.Errorf("auth mode %q needs http_server.user and password", cfg.Auth.Mode)
+	}
+	opts.Credentials = map[string]string{cfg.HTTPServer.User: cfg.HTTPServer.Password}
+
+	return opts, nil
+}
+
+// routeSegments are the static top-level segments of the server routes,
+// besides the metrics path. They are reserved as aliases so a short link
+// can never shadow an endpoint, for links saved by the CLI as well.
+var routeSegments = []string{"url", "admin", "healthz", "readyz", "version"}
+
+// newAliasPolicy builds the alias policy from cfg.Alias, with the route
+// segments and the metrics path reserved.
+func newAliasPolicy(cfg *config.Config) (*aliaspolicy.Policy, error) {
+	policy, err := aliaspolicy.New(aliaspolicy.Options{
+		Length:             cfg.Alias.Length,
+		MaxGeneratedLength: cfg.Alias.MaxGeneratedLength,
+		Alphabet:           cfg.Alias.Alphabet,
+		MinLength:          cfg.Alias.MinLength,
+		MaxLength:          cfg.Alias.MaxLength,
+		Pattern:            cfg.Alias.Pattern,
+		CaseSensitive:      cfg.Alias.CaseSensitive,
+		Reserved:           cfg.Alias.Reserved,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	policy.Reserve(routeSegments...)
+	// Reserved even when metrics are off or served elsewhere, so turning
+	// them on later doesn't shadow existing links.
+	policy.Reserve(topSegment(cfg.Metrics.Path))
+
+	return policy, nil
+}
+
+// checkReservedRoutes makes sure the static top-level segment of every
+// route is reserved, i.e. that routeSegments is up to date.
+func checkReservedRoutes(router chi.Router, aliasPolicy *aliaspolicy.Policy) error {
+	return chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		segment := topSegment(route)
+		if segment != "" && !strings.Contains(segment, "{") && !aliasPolicy.IsReserved(segment) {
+			return fmt.Errorf("route %s %s: segment %q is missing from routeSegments", method, route, segment)
+		}
+		return nil
+	})
+}
+
+func topSegment(route string) string {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(route, "/"), "/")
+	return segment
 }
 
 func setupLogger(env string) *slog.Logger {
@@ -103,3 +418,123 @@ func setupLogger(env string) *slog.Logger {
 	}
 	return log
 }
+
+// setupStorage opens the backend selected by cfg.Storage.Driver, together
+// with its database connection, which is nil for the memory backend.
+// The returned func releases the backend and closes the database connection.
+func setupStorage(cfg *config.Config, log *slog.Logger) (storage.Backend, *sql.DB, func() error, error) {
+	if cfg.Storage.Driver == storage.DriverMemory {
+		st, err := memory.New(cfg.Storage.SnapshotPath)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		return st, nil, st.Close, nil
+	}
+
+	db, err := openStorageDB(cfg)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if cfg.Storage.AutoMigrate {
+		m, err := migrate.New(db, cfg.Storage.Driver)
+		if err != nil {
+			_ = db.Close()
+			return nil, nil, nil, err
+		}
+
+		applied, err := m.Up(context.Background())
+		if err != nil {
+			_ = db.Close()
+			return nil, nil, nil, err
+		}
+
+		log.Info("Migrations applied", slog.Int("count", applied), slog.Int("version", m.Latest()))
+	}
+
+	var st interface {
+		storage.Backend
+		Close() error
+	}
+	switch cfg.Storage.Driver {
+	case storage.DriverPostgres:
+		st, err = storage.New(db)
+	case storage.DriverSQLite:
+		st, err = sqlite.New(db)
+	}
+	if err != nil {
+		_ = db.Close()
+		return nil, nil, nil, err
+	}
+
+	closeStorage := func() error {
+		return errors.Join(st.Close(), db.Close())
+	}
+
+	return st, db, closeStorage, nil
+}
+
+// readinessChecks returns what /readyz verifies besides the readiness flag:
+// that the database answers and its schema is at the version this binary expects.
+func readinessChecks(cfg *config.Config, db *sql.DB) (map[string]health.CheckFunc, error) {
+	if db == nil {
+		return nil, nil
+	}
+
+	m, err := migrate.New(db, cfg.Storage.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]health.CheckFunc{
+		"database": db.PingContext,
+		"migrations": func(ctx context.Context) error {
+			version, err := m.Version(ctx)
+			if err != nil {
+				return err
+			}
+			if version != m.Latest() {
+				return fmt.Errorf("schema is at version %d, want %d", version, m.Latest())
+			}
+			return nil
+		},
+	}, nil
+}
+
+// openStorageDB connects to the SQL database of the configured driver.
+func openStorageDB(cfg *config.Config) (*sql.DB, error) {
+	switch cfg.Storage.Driver {
+	case storage.DriverPostgres:
+		return openDB("postgres", cfg.DB_DSN, cfg.DB)
+	case storage.DriverSQLite:
+		if err := os.MkdirAll(filepath.Dir(cfg.StoragePath), 0o755); err != nil {
+			return nil, fmt.Errorf("create storage dir: %w", err)
+		}
+
+		// Foreign keys are off by default in SQLite, clicks rely on them
+		// to be removed together with their link.
+		return openDB("sqlite3", cfg.StoragePath+"?_foreign_keys=on", cfg.DB)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Storage.Driver)
+	}
+}
+
+func openDB(driverName, dsn string, pool config.DB) (*sql.DB, error) {
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+
+	db.SetMaxOpenConns(pool.MaxOpenConns)
+	db.SetMaxIdleConns(pool.MaxIdleConns)
+	db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+
+	if err := db.Ping(); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("ping database: %w", err)
+	}
+
+	return db, nil
+}
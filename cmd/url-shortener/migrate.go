@@ -0,0 +1,83 @@
+package main
+
+import (
+	"URL-shortener/internal/config"
+	"URL-shortener/internal/storage/migrate"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = "usage: url-shortener migrate up|down|status"
+
+// runMigrate implements the "migrate" subcommand and returns the exit code.
+func runMigrate(cfg *config.Config, log *slog.Logger, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	db, err := openStorageDB(cfg)
+	if err != nil {
+		log.Error("Failed to open database", slog.String("error", err.Error()))
+		return 1
+	}
+	defer db.Close()
+
+	m, err := migrate.New(db, cfg.Storage.Driver)
+	if err != nil {
+		log.Error("Failed to init migrator", slog.String("error", err.Error()))
+		return 1
+	}
+
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		if err != nil {
+			log.Error("Failed to apply migrations", slog.String("error", err.Error()))
+			return 1
+		}
+
+		log.Info("Migrations applied", slog.Int("count", applied), slog.Int("version", m.Latest()))
+	case "down":
+		mig, err := m.Down(ctx)
+		if errors.Is(err, migrate.ErrNoMigrations) {
+			log.Info("Nothing to roll back")
+			return 0
+		}
+		if err != nil {
+			log.Error("Failed to roll back migration", slog.String("error", err.Error()))
+			return 1
+		}
+
+		log.Info("Migration rolled back", slog.Int("version", mig.Version), slog.String("name", mig.Name))
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			log.Error("Failed to get migration status", slog.String("error", err.Error()))
+			return 1
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, st := range statuses {
+			appliedAt := "pending"
+			if st.Applied {
+				appliedAt = st.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", st.Version, st.Name, appliedAt)
+		}
+		_ = w.Flush()
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	return 0
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"URL-shortener/internal/config"
+	"URL-shortener/internal/lib/linkfile"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+)
+
+const exportUsage = `usage: url-shortener export [-format csv|jsonl] [-creator OWNER] FILE
+
+The format defaults to csv for *.csv files and jsonl otherwise.
+-creator limits the export to the links of OWNER, like key:1 or basic:admin.`
+
+const importUsage = `usage: url-shortener import [-format csv|jsonl] [-on-conflict skip|overwrite|rename] [-dry-run] [-creator OWNER] FILE
+
+The format defaults to csv for *.csv files and jsonl otherwise.
+-creator sets the owner of the new links, without it only admins can manage them.`
+
+// runExport implements the "export" subcommand and returns the exit code.
+// Logs go to stdout, so the links are written to a file.
+func runExport(cfg *config.Config, log *slog.Logger, args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	format := fs.String("format", "", "")
+	creator := fs.String("creator", "", "")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, exportUsage)
+		return 2
+	}
+
+	path := fs.Arg(0)
+	if *format == "" {
+		*format = linkfile.FormatOf(path)
+	}
+	if *format != linkfile.FormatCSV && *format != linkfile.FormatJSONL {
+		fmt.Fprintf(os.Stderr, "%v\n\n%s\n", linkfile.ErrUnknownFormat, exportUsage)
+		return 2
+	}
+
+	st, _, closeStorage, err := setupStorage(cfg, log)
+	if err != nil {
+		log.Error("Failed to init storage", slog.String("error", err.Error()))
+		return 1
+	}
+	defer func() {
+		if err := closeStorage(); err != nil {
+			log.Error("Failed to close storage", slog.String("error", err.Error()))
+		}
+	}()
+
+	f, err := os.Create(path)
+	if err != nil {
+		log.Error("Failed to create file", slog.String("error", err.Error()))
+		return 1
+	}
+
+	written, err := export(st, f, *format, *creator)
+	err = errors.Join(err, f.Close())
+	if err != nil {
+		_ = os.Remove(path)
+		log.Error("Failed to export URLs", slog.String("error", err.Error()))
+		return 1
+	}
+
+	log.Info("URLs exported", slog.Int("written", written), slog.String("file", path), slog.String("format", *format))
+
+	return 0
+}
+
+func export(lister linkfile.Lister, w io.Writer, format, owner string) (int, error) {
+	enc, err := linkfile.NewEncoder(w, format)
+	if err != nil {
+		return 0, err
+	}
+
+	return linkfile.Export(context.Background(), lister, enc, owner, nil)
+}
+
+// runImport implements the "import" subcommand and returns the exit code.
+// It logs the progress after every chunk of rows and prints a summary,
+// with the rows that failed, at the end.
+func runImport(cfg *config.Config, log *slog.Logger, args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	format := fs.String("format", "", "")
+	onConflict := fs.String("on-conflict", linkfile.ConflictSkip, "")
+	dryRun := fs.Bool("dry-run", false, "")
+	creator := fs.String("creator", "", "")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, importUsage)
+		return 2
+	}
+
+	path := fs.Arg(0)
+	if *format == "" {
+		*format = linkfile.FormatOf(path)
+	}
+	if *format != linkfile.FormatCSV && *format != linkfile.FormatJSONL {
+		fmt.Fprintf(os.Stderr, "%v\n\n%s\n", linkfile.ErrUnknownFormat, importUsage)
+		return 2
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Error("Failed to open file", slog.String("error", err.Error()))
+		return 1
+	}
+	defer f.Close()
+
+	dec, err := linkfile.NewDecoder(f, *format)
+	if err != nil {
+		log.Error("Failed to read file", slog.String("error", err.Error()))
+		return 1
+	}
+
+	aliasPolicy, err := newAliasPolicy(cfg)
+	if err != nil {
+		log.Error("Failed to init alias policy", slog.String("error", err.Error()))
+		return 1
+	}
+
+	st, _, closeStorage, err := setupStorage(cfg, log)
+	if err != nil {
+		log.Error("Failed to init storage", slog.String("error", err.Error()))
+		return 1
+	}
+	defer func() {
+		if err := closeStorage(); err != nil {
+			log.Error("Failed to close storage", slog.String("error", err.Error()))
+		}
+	}()
+
+	summary, err := linkfile.Import(context.Background(), st, dec, aliasPolicy, linkfile.ImportOptions{
+		OnConflict: *onConflict,
+		DryRun:     *dryRun,
+		Creator:    *creator,
+		Progress: func(s linkfile.Summary) {
+			log.Info("Import progress", slog.Int("rows", s.Rows), slog.Int("failed", s.Failed))
+		},
+	})
+	if errors.Is(err, linkfile.ErrUnknownConflict) {
+		fmt.Fprintf(os.Stderr, "%v\n\n%s\n", linkfile.ErrUnknownConflict, importUsage)
+		return 2
+	}
+
+	printSummary(summary)
+
+	if err != nil {
+		log.Error("Import stopped", slog.String("error", err.Error()))
+		return 1
+	}
+
+	return 0
+}
+
+func printSummary(s linkfile.Summary) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DRY RUN\tROWS\tCREATED\tUPDATED\tRENAMED\tSKIPPED\tFAILED")
+	fmt.Fprintf(w, "%t\t%d\t%d\t%d\t%d\t%d\t%d\n", s.DryRun, s.Rows, s.Created, s.Updated, s.Renamed, s.Skipped, s.Failed)
+	_ = w.Flush()
+
+	if len(s.Renames) > 0 {
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "LINE\tALIAS\tRENAMED TO")
+		for _, rename := range s.Renames {
+			fmt.Fprintf(w, "%d\t%s\t%s\n", rename.Line, rename.From, rename.To)
+		}
+		_ = w.Flush()
+	}
+
+	if len(s.Errors) > 0 {
+		fmt.Println()
+		w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "LINE\tALIAS\tCODE\tERROR")
+		for _, rowErr := range s.Errors {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", rowErr.Line, rowErr.Alias, rowErr.Code, rowErr.Message)
+		}
+		_ = w.Flush()
+	}
+}
@@ -1,26 +1,177 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
 type Config struct {
-	Env         string     `yaml:"env" env-default:"local"`
-	StoragePath string     `yaml:"storage_path" env-required:"./storage"`
-	DB_DSN      string     `yaml:"db_dsn" env:"DB_DSN"`
-	HTTPServer  HTTPServer `yaml:"http_server"`
+	Env         string      `yaml:"env" env-default:"local"`
+	StoragePath string      `yaml:"storage_path" env-required:"./storage"`
+	DB_DSN      string      `yaml:"db_dsn" env:"DB_DSN"`
+	Storage     Storage     `yaml:"storage"`
+	DB          DB          `yaml:"db"`
+	HTTPServer  HTTPServer  `yaml:"http_server"`
+	Alias       Alias       `yaml:"alias"`
+	Janitor     Janitor     `yaml:"janitor"`
+	Clicks      Clicks      `yaml:"clicks"`
+	Cache       Cache       `yaml:"cache"`
+	Health      Health      `yaml:"health"`
+	Metrics     Metrics     `yaml:"metrics"`
+	Tracing     Tracing     `yaml:"tracing"`
+	Auth        Auth        `yaml:"auth"`
+	Idempotency Idempotency `yaml:"idempotency"`
+}
+
+type Storage struct {
+	// Driver selects the storage backend: memory, sqlite or postgres.
+	// The sqlite backend keeps its data in StoragePath, postgres connects to DB_DSN.
+	Driver string `yaml:"driver" env:"STORAGE_DRIVER" env-default:"postgres"`
+	// SnapshotPath is where the memory backend persists its data on shutdown.
+	// Leave empty to keep the data only for the lifetime of the process.
+	SnapshotPath string `yaml:"snapshot_path" env:"STORAGE_SNAPSHOT_PATH"`
+	// AutoMigrate applies pending schema migrations on start.
+	// Disable it to run "url-shortener migrate up" as a separate deploy step.
+	AutoMigrate bool `yaml:"auto_migrate" env:"STORAGE_AUTO_MIGRATE" env-default:"true"`
+}
+
+// DB sizes the connection pool of the sql backends.
+// Zero means no limit, see database/sql.
+type DB struct {
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" env-default:"25"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" env-default:"25"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" env-default:"30m"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" env-default:"5m"`
+	Timeouts        DBTimeouts    `yaml:"timeouts"`
+}
+
+// DBTimeouts bounds each storage operation. Requests are also cut
+// at HTTPServer.Timeout, whichever comes first wins.
+type DBTimeouts struct {
+	Get        time.Duration `yaml:"get" env:"DB_TIMEOUT_GET" env-default:"1s"`
+	Save       time.Duration `yaml:"save" env:"DB_TIMEOUT_SAVE" env-default:"2s"`
+	Delete     time.Duration `yaml:"delete" env:"DB_TIMEOUT_DELETE" env-default:"2s"`
+	Stats      time.Duration `yaml:"stats" env:"DB_TIMEOUT_STATS" env-default:"3s"`
+	Purge      time.Duration `yaml:"purge" env:"DB_TIMEOUT_PURGE" env-default:"30s"`
+	SaveClicks time.Duration `yaml:"save_clicks" env:"DB_TIMEOUT_SAVE_CLICKS" env-default:"5s"`
 }
 
 type HTTPServer struct {
 	Address     string        `yaml:"address" env-default:":8080"`
 	Timeout     time.Duration `yaml:"timeout" env-default:"4s"`
 	IdleTimeout time.Duration `yaml:"idle_timeout" env-default:"60s"`
-	User        string        `yaml:"user" env-required:"true"`
-	Password    string        `yaml:"password" env-required:"true" env:"HTTP_SERVER_PASSWORD"`
+	User        string        `yaml:"user"`
+	Password    string        `yaml:"password" env:"HTTP_SERVER_PASSWORD"`
+	// TransferTimeout replaces Timeout for /url/export and /url/import,
+	// which stream whole files.
+	TransferTimeout time.Duration `yaml:"transfer_timeout" env:"HTTP_SERVER_TRANSFER_TIMEOUT" env-default:"10m"`
+	// ShutdownDelay is how long the server keeps serving after a stop signal
+	// while reporting not ready, so load balancers stop sending traffic first.
+	ShutdownDelay time.Duration `yaml:"shutdown_delay" env:"HTTP_SERVER_SHUTDOWN_DELAY" env-default:"5s"`
+	// ShutdownTimeout bounds how long in-flight requests may take to finish.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"HTTP_SERVER_SHUTDOWN_TIMEOUT" env-default:"15s"`
+	// LegacyStatusCodes answers 200 OK for created links and the errors older releases answered with 200,
+	// on the routes those releases had: POST /url, GET /{alias} and DELETE /url/{alias}.
+	// Deprecated: will be removed in the next release.
+	LegacyStatusCodes bool `yaml:"legacy_status_codes" env:"HTTP_SERVER_LEGACY_STATUS_CODES" env-default:"false"`
+}
+
+type Alias struct {
+	// Length of generated aliases. It grows up to MaxGeneratedLength
+	// when generated aliases start colliding often. Alphabet needs
+	// 2 to 256 distinct characters, no whitespace and none of "/.+?#%".
+	// Custom aliases can't contain those characters either, whatever
+	// Pattern allows.
+	Length             int    `yaml:"length" env:"ALIAS_LENGTH" env-default:"6"`
+	MaxGeneratedLength int    `yaml:"max_generated_length" env:"ALIAS_MAX_GENERATED_LENGTH" env-default:"16"`
+	Alphabet           string `yaml:"alphabet" env:"ALIAS_ALPHABET" env-default:"ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"`
+	// MinLength, MaxLength and Pattern apply to custom aliases.
+	MinLength     int    `yaml:"min_length" env:"ALIAS_MIN_LENGTH" env-default:"3"`
+	MaxLength     int    `yaml:"max_length" env:"ALIAS_MAX_LENGTH" env-default:"64"`
+	Pattern       string `yaml:"pattern" env:"ALIAS_PATTERN" env-default:"^[A-Za-z0-9_-]+$"`
+	CaseSensitive bool   `yaml:"case_sensitive" env:"ALIAS_CASE_SENSITIVE" env-default:"true"`
+	// Reserved words can't be used as aliases. The top-level routes
+	// of the service are always reserved in addition to this list.
+	Reserved []string `yaml:"reserved" env:"ALIAS_RESERVED" env-separator:","`
+}
+
+// Janitor purges expired links in the background. Interval and
+// BatchSize must be positive while it is enabled.
+type Janitor struct {
+	Enabled   bool          `yaml:"enabled" env:"JANITOR_ENABLED" env-default:"true"`
+	Interval  time.Duration `yaml:"interval" env:"JANITOR_INTERVAL" env-default:"1m"`
+	BatchSize int           `yaml:"batch_size" env:"JANITOR_BATCH_SIZE" env-default:"1000"`
+}
+
+// Clicks configures how redirects are recorded for link analytics.
+// Clicks are buffered in memory and written in batches, when the buffer
+// is full new clicks are dropped instead of slowing redirects down.
+// All three settings must be positive.
+type Clicks struct {
+	BufferSize    int           `yaml:"buffer_size" env:"CLICKS_BUFFER_SIZE" env-default:"10000"`
+	BatchSize     int           `yaml:"batch_size" env:"CLICKS_BATCH_SIZE" env-default:"500"`
+	FlushInterval time.Duration `yaml:"flush_interval" env:"CLICKS_FLUSH_INTERVAL" env-default:"1s"`
+}
+
+// Cache keeps recently resolved aliases in memory to spare the storage
+// a query on every redirect. Links changed on another replica are picked up
+// after TTL, missing aliases are remembered for NegativeTTL.
+type Cache struct {
+	Enabled     bool          `yaml:"enabled" env:"CACHE_ENABLED" env-default:"true"`
+	Size        int           `yaml:"size" env:"CACHE_SIZE" env-default:"10000"`
+	TTL         time.Duration `yaml:"ttl" env:"CACHE_TTL" env-default:"5m"`
+	NegativeTTL time.Duration `yaml:"negative_ttl" env:"CACHE_NEGATIVE_TTL" env-default:"30s"`
+}
+
+// Health configures the /healthz, /readyz and /version probes.
+type Health struct {
+	// CheckTimeout bounds the dependency checks of /readyz.
+	CheckTimeout time.Duration `yaml:"check_timeout" env:"HEALTH_CHECK_TIMEOUT" env-default:"2s"`
+}
+
+// Metrics configures the Prometheus endpoint.
+type Metrics struct {
+	Enabled bool   `yaml:"enabled" env:"METRICS_ENABLED" env-default:"true"`
+	Path    string `yaml:"path" env:"METRICS_PATH" env-default:"/metrics"`
+	// AdminAddress serves the metrics on a separate listener, so they can be
+	// kept off the public port. Leave empty to serve them on HTTPServer.Address.
+	AdminAddress string `yaml:"admin_address" env:"METRICS_ADMIN_ADDRESS"`
+}
+
+// Tracing exports OpenTelemetry spans of requests and storage queries.
+type Tracing struct {
+	// Exporter is otlp, stdout or none. With none spans are not exported,
+	// but logs still carry trace IDs.
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER" env-default:"none"`
+	ServiceName string  `yaml:"service_name" env:"OTEL_SERVICE_NAME" env-default:"url-shortener"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" env-default:"1"`
+	// OTLPEndpoint is the host:port of the OTLP/HTTP collector. Leave empty
+	// to use the standard OTEL_EXPORTER_OTLP_* variables.
+	OTLPEndpoint string `yaml:"otlp_endpoint" env:"TRACING_OTLP_ENDPOINT"`
+	OTLPInsecure bool   `yaml:"otlp_insecure" env:"TRACING_OTLP_INSECURE" env-default:"false"`
+}
+
+// Auth selects how the /url and /admin routes authenticate.
+type Auth struct {
+	// Mode is api_key, basic or both. BasicAuth checks HTTPServer.User and
+	// Password, which grant every scope. With both, API keys are tried first.
+	Mode string `yaml:"mode" env:"AUTH_MODE" env-default:"both"`
+}
+
+// Idempotency configures the Idempotency-Key header of POST /url.
+type Idempotency struct {
+	// Window is how long a stored response is replayed for the same key.
+	// Zero disables the header.
+	Window time.Duration `yaml:"window" env:"IDEMPOTENCY_WINDOW" env-default:"24h"`
 }
 
 func MustLoad() *Config {
@@ -44,11 +195,134 @@ func MustLoad() *Config {
 		log.Fatal("CONFIG_PATH does not exist: ", configPath)
 	}
 
+	cfg, err := Load(configPath)
+	if err != nil {
+		log.Fatal("Can not read config: ", err)
+	}
+
+	return cfg
+}
+
+// Load reads the config file at path, then the environment, which wins.
+// Zeros and false set in a YAML file are kept, env-default only fills in
+// what the file and the environment leave out.
+func Load(path string) (*Config, error) {
 	var cfg Config
 
-	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil {
-		log.Fatal("Can not read config: ", err)
+	if err := cleanenv.ReadConfig(path, &cfg); err != nil {
+		return nil, err
+	}
+
+	if ext := filepath.Ext(path); ext == ".yml" || ext == ".yaml" {
+		if err := restoreFileZeros(path, &cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// validate rejects values the service can't run with, such as a zero
+// interval that would make a ticker panic. Zeros that turn a feature
+// off or lift a limit are documented on their fields and pass.
+func (c *Config) validate() error {
+	errs := []error{
+		positiveDuration("http_server.timeout", c.HTTPServer.Timeout),
+		positiveDuration("http_server.transfer_timeout", c.HTTPServer.TransferTimeout),
+		positiveDuration("http_server.shutdown_timeout", c.HTTPServer.ShutdownTimeout),
+		positiveDuration("health.check_timeout", c.Health.CheckTimeout),
+		positiveSize("clicks.buffer_size", c.Clicks.BufferSize),
+		positiveSize("clicks.batch_size", c.Clicks.BatchSize),
+		positiveDuration("clicks.flush_interval", c.Clicks.FlushInterval),
+	}
+	if c.Janitor.Enabled {
+		errs = append(errs,
+			positiveDuration("janitor.interval", c.Janitor.Interval),
+			positiveSize("janitor.batch_size", c.Janitor.BatchSize),
+		)
+	}
+
+	return errors.Join(errs...)
+}
+
+func positiveDuration(name string, d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("%s must be positive, got %s", name, d)
+	}
+
+	return nil
+}
+
+func positiveSize(name string, n int) error {
+	if n <= 0 {
+		return fmt.Errorf("%s must be positive, got %d", name, n)
+	}
+
+	return nil
+}
+
+// restoreFileZeros undoes env-default for fields the file sets to their
+// zero value on purpose. cleanenv can't tell those from missing ones.
+func restoreFileZeros(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	var (
+		file Config
+		keys map[string]any
+	)
+	if err := cleanenv.ParseYAML(bytes.NewReader(data), &file); err != nil {
+		return fmt.Errorf("parse config file: %w", err)
+	}
+	if err := cleanenv.ParseYAML(bytes.NewReader(data), &keys); err != nil {
+		return fmt.Errorf("parse config file: %w", err)
+	}
+
+	restoreZeros(reflect.ValueOf(cfg).Elem(), reflect.ValueOf(file), keys)
+
+	return nil
+}
+
+func restoreZeros(dst, file reflect.Value, keys map[string]any) {
+	for i := 0; i < dst.NumField(); i++ {
+		field := dst.Type().Field(i)
+
+		name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		value, ok := keys[name]
+		if name == "" || !ok {
+			continue
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			if nested, ok := value.(map[string]any); ok {
+				restoreZeros(dst.Field(i), file.Field(i), nested)
+			}
+			continue
+		}
+
+		if !file.Field(i).IsZero() || envSet(field.Tag.Get("env")) {
+			continue
+		}
+		dst.Field(i).Set(file.Field(i))
+	}
+}
+
+// envSet reports whether any of the comma-separated variables is set.
+func envSet(names string) bool {
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if _, ok := os.LookupEnv(name); ok {
+			return true
+		}
 	}
 
-	return &cfg
+	return false
 }
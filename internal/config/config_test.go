@@ -0,0 +1,204 @@
+package config_test
+
+import (
+	"URL-shortener/internal/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, err := config.Load(writeConfig(t, "storage_path: ./storage.db\n"))
+	require.NoError(t, err)
+
+	require.True(t, cfg.Storage.AutoMigrate)
+	require.Equal(t, 25, cfg.DB.MaxOpenConns)
+	require.Equal(t, 5*time.Second, cfg.HTTPServer.ShutdownDelay)
+	require.True(t, cfg.Alias.CaseSensitive)
+	require.True(t, cfg.Janitor.Enabled)
+	require.True(t, cfg.Cache.Enabled)
+	require.True(t, cfg.Metrics.Enabled)
+	require.Equal(t, 24*time.Hour, cfg.Idempotency.Window)
+}
+
+func TestLoad_ExplicitZeros(t *testing.T) {
+	cfg, err := config.Load(writeConfig(t, `
+storage_path: ./storage.db
+storage:
+  auto_migrate: false
+db:
+  max_open_conns: 0
+  max_idle_conns: 10
+http_server:
+  shutdown_delay: 0s
+alias:
+  case_sensitive: false
+janitor:
+  enabled: false
+cache:
+  enabled: false
+metrics:
+  enabled: false
+idempotency:
+  window: 0s
+`))
+	require.NoError(t, err)
+
+	require.False(t, cfg.Storage.AutoMigrate)
+	require.Zero(t, cfg.DB.MaxOpenConns)
+	require.Equal(t, 10, cfg.DB.MaxIdleConns)
+	require.Zero(t, cfg.HTTPServer.ShutdownDelay)
+	require.False(t, cfg.Alias.CaseSensitive)
+	require.False(t, cfg.Janitor.Enabled)
+	require.False(t, cfg.Cache.Enabled)
+	require.False(t, cfg.Metrics.Enabled)
+	require.Zero(t, cfg.Idempotency.Window)
+
+	// Untouched fields keep their defaults.
+	require.Equal(t, 30*time.Minute, cfg.DB.ConnMaxLifetime)
+}
+
+func TestLoad_EnvOverridesFile(t *testing.T) {
+	t.Setenv("JANITOR_ENABLED", "true")
+	t.Setenv("IDEMPOTENCY_WINDOW", "1h")
+
+	cfg, err := config.Load(writeConfig(t, `
+storage_path: ./storage.db
+janitor:
+  enabled: false
+idempotency:
+  window: 0s
+`))
+	require.NoError(t, err)
+
+	require.True(t, cfg.Janitor.Enabled)
+	require.Equal(t, time.Hour, cfg.Idempotency.Window)
+}
+
+func TestLoad_InvalidJanitor(t *testing.T) {
+	cases := []struct {
+		name        string
+		janitor     string
+		expectedErr string
+	}{
+		{
+			name:        "Zero interval",
+			janitor:     "interval: 0s",
+			expectedErr: "janitor.interval must be positive",
+		},
+		{
+			name:        "Negative interval",
+			janitor:     "interval: -1m",
+			expectedErr: "janitor.interval must be positive",
+		},
+		{
+			name:        "Zero batch size",
+			janitor:     "batch_size: 0",
+			expectedErr: "janitor.batch_size must be positive",
+		},
+		{
+			name:        "Negative batch size",
+			janitor:     "batch_size: -5",
+			expectedErr: "janitor.batch_size must be positive",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := config.Load(writeConfig(t, "storage_path: ./storage.db\njanitor:\n  "+tc.janitor+"\n"))
+			require.ErrorContains(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestLoad_DisabledJanitor(t *testing.T) {
+	cfg, err := config.Load(writeConfig(t, `
+storage_path: ./storage.db
+janitor:
+  enabled: false
+  interval: 0s
+  batch_size: 0
+`))
+	require.NoError(t, err)
+	require.False(t, cfg.Janitor.Enabled)
+}
+
+func TestLoad_InvalidSettings(t *testing.T) {
+	cases := []struct {
+		name        string
+		config      string
+		env         map[string]string
+		expectedErr string
+	}{
+		{
+			name:        "Zero request timeout",
+			config:      "http_server:\n  timeout: 0s",
+			expectedErr: "http_server.timeout must be positive",
+		},
+		{
+			name:        "Zero transfer timeout",
+			config:      "http_server:\n  transfer_timeout: 0s",
+			expectedErr: "http_server.transfer_timeout must be positive",
+		},
+		{
+			name:        "Zero shutdown timeout",
+			config:      "http_server:\n  shutdown_timeout: 0s",
+			expectedErr: "http_server.shutdown_timeout must be positive",
+		},
+		{
+			name:        "Zero check timeout",
+			config:      "health:\n  check_timeout: 0s",
+			expectedErr: "health.check_timeout must be positive",
+		},
+		{
+			name:        "Zero click buffer",
+			config:      "clicks:\n  buffer_size: 0",
+			expectedErr: "clicks.buffer_size must be positive",
+		},
+		{
+			name:        "Zero click batch",
+			config:      "clicks:\n  batch_size: 0",
+			expectedErr: "clicks.batch_size must be positive",
+		},
+		{
+			name:        "Zero flush interval",
+			config:      "clicks:\n  flush_interval: 0s",
+			expectedErr: "clicks.flush_interval must be positive",
+		},
+		{
+			name:        "Zero from the environment",
+			config:      "clicks:\n  flush_interval: 1s",
+			env:         map[string]string{"CLICKS_FLUSH_INTERVAL": "0s"},
+			expectedErr: "clicks.flush_interval must be positive",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+
+			_, err := config.Load(writeConfig(t, "storage_path: ./storage.db\n"+tc.config+"\n"))
+			require.ErrorContains(t, err, tc.expectedErr)
+		})
+	}
+}
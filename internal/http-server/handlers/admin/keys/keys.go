@@ -0,0 +1,222 @@
+package keys
+
+import (
+	"URL-shortener/internal/lib/api/response"
+	"URL-shortener/internal/lib/apikey"
+	"URL-shortener/internal/lib/logger/sl"
+	"URL-shortener/internal/lib/telemetry"
+	"URL-shortener/internal/storage"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+type CreateRequest struct {
+	Name   string   `json:"name" validate:"required,max=100"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=create update delete read-stats admin"`
+}
+
+// Key describes an API key without its secret part.
+type Key struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+type CreateResponse struct {
+	response.Response
+	Key
+	// Secret is the full key. It is only ever shown in this response.
+	Secret string `json:"key"`
+}
+
+type ListResponse struct {
+	response.Response
+	Keys []Key `json:"keys"`
+}
+
+var validate = response.NewValidator()
+
+//go:generate go run github.com/vektra/mockery/v2@v2.43.0 --name KeySaver
+type KeySaver interface {
+	SaveAPIKey(ctx context.Context, key storage.APIKey) (int64, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.43.0 --name KeyLister
+type KeyLister interface {
+	ListAPIKeys(ctx context.Context) ([]storage.APIKey, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.43.0 --name KeyRevoker
+type KeyRevoker interface {
+	RevokeAPIKey(ctx context.Context, id int64) error
+}
+
+// Create issues a new API key with the requested scopes.
+func Create(log *slog.Logger, keySaver KeySaver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.keys.Create"
+
+		log := requestLogger(log, op, r)
+
+		var req CreateRequest
+
+		if err := render.DecodeJSON(r.Body, &req); err != nil {
+			log.Error("Failed to decode request body", sl.Err(err))
+
+			response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest, "Failed to decode request"))
+
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			validateErr, ok := err.(validator.ValidationErrors)
+			if !ok {
+				log.Error("Invalid request", sl.Err(err))
+				response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest, "Failed to validate request"))
+				return
+			}
+
+			log.Info("Invalid request", sl.Err(err))
+			response.RenderError(w, r, http.StatusUnprocessableEntity, response.ValidationError(validateErr))
+			return
+		}
+
+		secret, prefix, hash, err := apikey.Generate()
+		if err != nil {
+			log.Error("Failed to generate API key", sl.Err(err))
+			response.RenderError(w, r, http.StatusInternalServerError, response.Error(response.CodeInternal, "Failed to create API key"))
+			return
+		}
+
+		key := storage.APIKey{
+			Name:      req.Name,
+			Prefix:    prefix,
+			Hash:      hash,
+			Scopes:    req.Scopes,
+			CreatedAt: time.Now().UTC(),
+		}
+
+		key.ID, err = keySaver.SaveAPIKey(r.Context(), key)
+		if status, resp, ok := response.ContextError(err); ok {
+			log.Warn("Saving API key interrupted", sl.Err(err))
+			response.RenderError(w, r, status, resp)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to save API key", sl.Err(err))
+			response.RenderError(w, r, http.StatusInternalServerError, response.Error(response.CodeInternal, "Failed to create API key"))
+			return
+		}
+
+		log.Info("API key created", slog.Int64("key_id", key.ID), slog.String("name", key.Name), slog.Any("scopes", key.Scopes))
+
+		render.Status(r, http.StatusCreated)
+		render.JSON(w, r, CreateResponse{Response: response.OK(), Key: toKey(key), Secret: secret})
+	}
+}
+
+// List returns every API key, revoked ones included.
+func List(log *slog.Logger, keyLister KeyLister) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.keys.List"
+
+		log := requestLogger(log, op, r)
+
+		keys, err := keyLister.ListAPIKeys(r.Context())
+		if status, resp, ok := response.ContextError(err); ok {
+			log.Warn("Listing API keys interrupted", sl.Err(err))
+			response.RenderError(w, r, status, resp)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to list API keys", sl.Err(err))
+			response.RenderError(w, r, http.StatusInternalServerError, response.Error(response.CodeInternal, "Failed to list API keys"))
+			return
+		}
+
+		resp := ListResponse{Response: response.OK(), Keys: make([]Key, 0, len(keys))}
+		for _, key := range keys {
+			resp.Keys = append(resp.Keys, toKey(key))
+		}
+
+		render.JSON(w, r, resp)
+	}
+}
+
+// Revoke disables an API key. It stays in the listing.
+func Revoke(log *slog.Logger, keyRevoker KeyRevoker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.admin.keys.Revoke"
+
+		log := requestLogger(log, op, r)
+
+		id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+		if err != nil || id < 1 {
+			log.Info("Invalid key id", slog.String("id", chi.URLParam(r, "id")))
+
+			response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest, "Invalid key id"))
+
+			return
+		}
+
+		err = keyRevoker.RevokeAPIKey(r.Context(), id)
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			log.Info("API key not found", slog.Int64("key_id", id))
+			response.RenderError(w, r, http.StatusNotFound, response.Error(response.CodeNotFound, "API key not found"))
+			return
+		}
+		if status, resp, ok := response.ContextError(err); ok {
+			log.Warn("Revoking API key interrupted", sl.Err(err))
+			response.RenderError(w, r, status, resp)
+			return
+		}
+		if err != nil {
+			log.Error("Failed to revoke API key", sl.Err(err))
+			response.RenderError(w, r, http.StatusInternalServerError, response.Error(response.CodeInternal, "Failed to revoke API key"))
+			return
+		}
+
+		log.Info("API key revoked", slog.Int64("key_id", id))
+
+		render.JSON(w, r, response.OK())
+	}
+}
+
+func requestLogger(log *slog.Logger, op string, r *http.Request) *slog.Logger {
+	return log.With(
+		slog.String("operation", op),
+		slog.String("request_id", middleware.GetReqID(r.Context())),
+		slog.String("trace_id", telemetry.TraceID(r.Context())),
+	)
+}
+
+func toKey(key storage.APIKey) Key {
+	k := Key{
+		ID:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+	}
+	if !key.LastUsedAt.IsZero() {
+		k.LastUsedAt = &key.LastUsedAt
+	}
+	if !key.RevokedAt.IsZero() {
+		k.RevokedAt = &key.RevokedAt
+	}
+
+	return k
+}
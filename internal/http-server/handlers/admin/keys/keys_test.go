@@ -0,0 +1,200 @@
+package keys_test
+
+import (
+	"URL-shortener/internal/http-server/handlers/admin/keys"
+	"URL-shortener/internal/http-server/handlers/admin/keys/mocks"
+	"URL-shortener/internal/lib/apikey"
+	"URL-shortener/internal/lib/logger/handlers/slogdiscard"
+	"URL-shortener/internal/storage"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreate(t *testing.T) {
+	cases := []struct {
+		name         string
+		body         string
+		setupMock    func(*mocks.KeySaver)
+		expectedCode int
+		expectedErr  string
+	}{
+		{
+			name: "Success",
+			body: `{"name": "ci", "scopes": ["create", "read-stats"]}`,
+			setupMock: func(m *mocks.KeySaver) {
+				m.On("SaveAPIKey", mock.Anything, mock.MatchedBy(func(key storage.APIKey) bool {
+					return key.Name == "ci" && key.Hash != "" && strings.HasPrefix(key.Prefix, "usk_") &&
+						len(key.Scopes) == 2 && !key.CreatedAt.IsZero()
+				})).Return(int64(3), nil).Once()
+			},
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name:         "Unknown scope",
+			body:         `{"name": "ci", "scopes": ["everything"]}`,
+			expectedCode: http.StatusUnprocessableEntity,
+			expectedErr:  "Field Scopes[0] is not valid",
+		},
+		{
+			name:         "No scopes",
+			body:         `{"name": "ci", "scopes": []}`,
+			expectedCode: http.StatusUnprocessableEntity,
+			expectedErr:  "Field Scopes is not valid",
+		},
+		{
+			name:         "Missing name",
+			body:         `{"scopes": ["create"]}`,
+			expectedCode: http.StatusUnprocessableEntity,
+			expectedErr:  "Field 'Name' is required",
+		},
+		{
+			name:         "Invalid JSON",
+			body:         `{`,
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "Failed to decode request",
+		},
+		{
+			name: "Storage error",
+			body: `{"name": "ci", "scopes": ["admin"]}`,
+			setupMock: func(m *mocks.KeySaver) {
+				m.On("SaveAPIKey", mock.Anything, mock.Anything).Return(int64(0), errors.New("database error")).Once()
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedErr:  "Failed to create API key",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			keySaverMock := mocks.NewKeySaver(t)
+			if tc.setupMock != nil {
+				tc.setupMock(keySaverMock)
+			}
+
+			handler := keys.Create(slogdiscard.NewDiscardLogger(), keySaverMock)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/keys", bytes.NewReader([]byte(tc.body))))
+
+			require.Equal(t, tc.expectedCode, rr.Code)
+
+			var resp keys.CreateResponse
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+			require.Equal(t, tc.expectedErr, resp.Error)
+
+			if tc.expectedErr == "" {
+				require.Equal(t, int64(3), resp.ID)
+				require.True(t, strings.HasPrefix(resp.Secret, resp.Prefix))
+				require.Equal(t, []string{apikey.ScopeCreate, apikey.ScopeReadStats}, resp.Scopes)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	usedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	keyListerMock := mocks.NewKeyLister(t)
+	keyListerMock.On("ListAPIKeys", mock.Anything).Return([]storage.APIKey{
+		{ID: 1, Name: "ci", Prefix: "usk_abcdefgh", Hash: "secret-hash", Scopes: []string{"create"}, LastUsedAt: usedAt},
+		{ID: 2, Name: "old", Prefix: "usk_ijklmnop", Hash: "other-hash", Scopes: []string{"admin"}, RevokedAt: usedAt},
+	}, nil).Once()
+
+	rr := httptest.NewRecorder()
+	keys.List(slogdiscard.NewDiscardLogger(), keyListerMock).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/keys", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NotContains(t, rr.Body.String(), "hash")
+
+	var resp keys.ListResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Len(t, resp.Keys, 2)
+	require.Equal(t, usedAt, *resp.Keys[0].LastUsedAt)
+	require.Nil(t, resp.Keys[0].RevokedAt)
+	require.Equal(t, usedAt, *resp.Keys[1].RevokedAt)
+}
+
+func TestRevoke(t *testing.T) {
+	cases := []struct {
+		name         string
+		id           string
+		setupMock    func(*mocks.KeyRevoker)
+		expectedCode int
+		expectedErr  string
+	}{
+		{
+			name: "Success",
+			id:   "1",
+			setupMock: func(m *mocks.KeyRevoker) {
+				m.On("RevokeAPIKey", mock.Anything, int64(1)).Return(nil).Once()
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "Not found",
+			id:   "2",
+			setupMock: func(m *mocks.KeyRevoker) {
+				m.On("RevokeAPIKey", mock.Anything, int64(2)).Return(storage.ErrAPIKeyNotFound).Once()
+			},
+			expectedCode: http.StatusNotFound,
+			expectedErr:  "API key not found",
+		},
+		{
+			name:         "Invalid id",
+			id:           "abc",
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "Invalid key id",
+		},
+		{
+			name: "Request canceled",
+			id:   "3",
+			setupMock: func(m *mocks.KeyRevoker) {
+				m.On("RevokeAPIKey", mock.Anything, int64(3)).Return(context.Canceled).Once()
+			},
+			expectedCode: http.StatusServiceUnavailable,
+			expectedErr:  "Request canceled",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			keyRevokerMock := mocks.NewKeyRevoker(t)
+			if tc.setupMock != nil {
+				tc.setupMock(keyRevokerMock)
+			}
+
+			r := chi.NewRouter()
+			r.Delete("/admin/keys/{id}", keys.Revoke(slogdiscard.NewDiscardLogger(), keyRevokerMock))
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodDelete, "/admin/keys/"+tc.id, nil))
+
+			require.Equal(t, tc.expectedCode, rr.Code)
+
+			var resp struct {
+				Error string `json:"error"`
+			}
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+			require.Equal(t, tc.expectedErr, resp.Error)
+		})
+	}
+}
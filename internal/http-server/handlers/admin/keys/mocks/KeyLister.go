@@ -0,0 +1,45 @@
+package mocks
+
+import (
+	storage "URL-shortener/internal/storage"
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+type KeyLister struct {
+	mock.Mock
+}
+
+func (_m *KeyLister) ListAPIKeys(ctx context.Context) ([]storage.APIKey, error) {
+	ret := _m.Called(ctx)
+
+	var r0 []storage.APIKey
+	var r1 error
+
+	if rf, ok := ret.Get(0).(func(context.Context) ([]storage.APIKey, error)); ok {
+		return rf(ctx)
+	}
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]storage.APIKey)
+	}
+
+	r1 = ret.Error(1)
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewKeyLister interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+func NewKeyLister(t mockConstructorTestingTNewKeyLister) *KeyLister {
+	mock := &KeyLister{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,58 @@
+package mocks
+
+import (
+	"URL-shortener/internal/storage"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestKeyLister_ListAPIKeys(t *testing.T) {
+	tests := []struct {
+		name         string
+		setupMock    func(*KeyLister)
+		expectedKeys []storage.APIKey
+		expectedErr  error
+	}{
+		{
+			name: "successful list",
+			setupMock: func(m *KeyLister) {
+				m.On("ListAPIKeys", mock.Anything).Return([]storage.APIKey{{ID: 1}}, nil)
+			},
+			expectedKeys: []storage.APIKey{{ID: 1}},
+		},
+		{
+			name: "internal error",
+			setupMock: func(m *KeyLister) {
+				m.On("ListAPIKeys", mock.Anything).Return(nil, errors.New("database error"))
+			},
+			expectedErr: errors.New("database error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockKeyLister := NewKeyLister(t)
+			tt.setupMock(mockKeyLister)
+
+			keys, err := mockKeyLister.ListAPIKeys(context.Background())
+
+			assert.Equal(t, tt.expectedKeys, keys)
+			if tt.expectedErr != nil {
+				assert.EqualError(t, err, tt.expectedErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockKeyLister.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNewKeyLister(t *testing.T) {
+	mock := NewKeyLister(t)
+	assert.NotNil(t, mock)
+}
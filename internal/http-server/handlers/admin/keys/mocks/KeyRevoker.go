@@ -0,0 +1,39 @@
+package mocks
+
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+type KeyRevoker struct {
+	mock.Mock
+}
+
+func (_m *KeyRevoker) RevokeAPIKey(ctx context.Context, id int64) error {
+	ret := _m.Called(ctx, id)
+
+	var r0 error
+
+	if rf, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		return rf(ctx, id)
+	}
+
+	r0 = ret.Error(0)
+
+	return r0
+}
+
+type mockConstructorTestingTNewKeyRevoker interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+func NewKeyRevoker(t mockConstructorTestingTNewKeyRevoker) *KeyRevoker {
+	mock := &KeyRevoker{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
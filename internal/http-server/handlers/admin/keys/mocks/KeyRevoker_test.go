@@ -0,0 +1,57 @@
+package mocks
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestKeyRevoker_RevokeAPIKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		id          int64
+		setupMock   func(*KeyRevoker)
+		expectedErr error
+	}{
+		{
+			name: "successful revoke",
+			id:   1,
+			setupMock: func(m *KeyRevoker) {
+				m.On("RevokeAPIKey", mock.Anything, int64(1)).Return(nil)
+			},
+		},
+		{
+			name: "key not found",
+			id:   2,
+			setupMock: func(m *KeyRevoker) {
+				m.On("RevokeAPIKey", mock.Anything, int64(2)).Return(errors.New("API key not found"))
+			},
+			expectedErr: errors.New("API key not found"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockKeyRevoker := NewKeyRevoker(t)
+			tt.setupMock(mockKeyRevoker)
+
+			err := mockKeyRevoker.RevokeAPIKey(context.Background(), tt.id)
+
+			if tt.expectedErr != nil {
+				assert.EqualError(t, err, tt.expectedErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockKeyRevoker.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNewKeyRevoker(t *testing.T) {
+	mock := NewKeyRevoker(t)
+	assert.NotNil(t, mock)
+}
@@ -0,0 +1,45 @@
+package mocks
+
+import (
+	storage "URL-shortener/internal/storage"
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+type KeySaver struct {
+	mock.Mock
+}
+
+func (_m *KeySaver) SaveAPIKey(ctx context.Context, key storage.APIKey) (int64, error) {
+	ret := _m.Called(ctx, key)
+
+	var r0 int64
+	var r1 error
+
+	if rf, ok := ret.Get(0).(func(context.Context, storage.APIKey) (int64, error)); ok {
+		return rf(ctx, key)
+	}
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(int64)
+	}
+
+	r1 = ret.Error(1)
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewKeySaver interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+func NewKeySaver(t mockConstructorTestingTNewKeySaver) *KeySaver {
+	mock := &KeySaver{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,58 @@
+package mocks
+
+import (
+	"URL-shortener/internal/storage"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestKeySaver_SaveAPIKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		setupMock   func(*KeySaver)
+		expectedID  int64
+		expectedErr error
+	}{
+		{
+			name: "successful save",
+			setupMock: func(m *KeySaver) {
+				m.On("SaveAPIKey", mock.Anything, mock.Anything).Return(int64(1), nil)
+			},
+			expectedID: 1,
+		},
+		{
+			name: "internal error",
+			setupMock: func(m *KeySaver) {
+				m.On("SaveAPIKey", mock.Anything, mock.Anything).Return(int64(0), errors.New("database error"))
+			},
+			expectedErr: errors.New("database error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockKeySaver := NewKeySaver(t)
+			tt.setupMock(mockKeySaver)
+
+			id, err := mockKeySaver.SaveAPIKey(context.Background(), storage.APIKey{Name: "ci"})
+
+			assert.Equal(t, tt.expectedID, id)
+			if tt.expectedErr != nil {
+				assert.EqualError(t, err, tt.expectedErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockKeySaver.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNewKeySaver(t *testing.T) {
+	mock := NewKeySaver(t)
+	assert.NotNil(t, mock)
+}
@@ -1,9 +1,12 @@
 package delete
 
 import (
+	"URL-shortener/internal/http-server/middleware/auth"
 	"URL-shortener/internal/lib/api/response"
 	"URL-shortener/internal/lib/logger/sl"
+	"URL-shortener/internal/lib/telemetry"
 	"URL-shortener/internal/storage"
+	"context"
 	"errors"
 	"log/slog"
 	"net/http"
@@ -15,33 +18,57 @@ import (
 
 //go:generate go run github.com/vektra/mockery/v2@v2.43.0 --name URLDeleter --dir ../../../../.. --output ./mocks --filename mock_url_deleter.go --with-expecter
 type URLDeleter interface {
-	DeleteURL(alias string) error
+	DeleteURL(ctx context.Context, alias string, owner string) error
 }
 
-func New(log *slog.Logger, urlDeleter URLDeleter) http.HandlerFunc {
+// AliasNormalizer maps an alias to the form it is stored in.
+type AliasNormalizer interface {
+	Normalize(alias string) string
+}
+
+func New(log *slog.Logger, urlDeleter URLDeleter, aliasNormalizer AliasNormalizer) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.url.delete.New"
 
 		log := log.With(
 			slog.String("operation", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", telemetry.TraceID(r.Context())),
 		)
 
 		alias := chi.URLParam(r, "alias")
 		if alias == "" {
 			log.Info("Alias is empty")
 
-			render.JSON(w, r, response.Error("Invalid request"))
+			response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest, "Invalid request"))
 
 			return
 		}
 
-		err := urlDeleter.DeleteURL(alias)
+		alias = aliasNormalizer.Normalize(alias)
+
+		err := urlDeleter.DeleteURL(r.Context(), alias, auth.OwnerFilter(r.Context()))
 
 		if err != nil && errors.Is(err, storage.ErrURLNotFound) {
 			log.Info("URL not found", slog.String("alias", alias))
 
-			render.JSON(w, r, response.Error("URL not found"))
+			response.RenderError(w, r, http.StatusNotFound, response.Error(response.CodeNotFound, "URL not found"))
+
+			return
+		}
+
+		if errors.Is(err, storage.ErrNotOwner) {
+			log.Info("URL belongs to another owner", slog.String("alias", alias))
+
+			response.RenderError(w, r, http.StatusForbidden, response.Error(response.CodeForbidden, "URL belongs to another owner"))
+
+			return
+		}
+
+		if status, resp, ok := response.ContextError(err); ok {
+			log.Warn("Deleting URL interrupted", sl.Err(err))
+
+			response.RenderError(w, r, status, resp)
 
 			return
 		}
@@ -49,7 +76,7 @@ func New(log *slog.Logger, urlDeleter URLDeleter) http.HandlerFunc {
 		if err != nil {
 			log.Error("Failed to delete URL", sl.Err(err))
 
-			render.JSON(w, r, response.Error("Failed to delete URL"))
+			response.RenderError(w, r, http.StatusInternalServerError, response.Error(response.CodeInternal, "Failed to delete URL"))
 
 			return
 		}
@@ -3,8 +3,13 @@ package delete_test
 import (
 	"URL-shortener/internal/http-server/handlers/delete"
 	"URL-shortener/internal/http-server/handlers/delete/mocks"
+	"URL-shortener/internal/http-server/middleware/auth"
+	"URL-shortener/internal/lib/aliaspolicy"
+	"URL-shortener/internal/lib/apikey"
 	"URL-shortener/internal/lib/logger/handlers/slogdiscard"
+	"URL-shortener/internal/lib/random"
 	"URL-shortener/internal/storage"
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -12,6 +17,7 @@ import (
 	"testing"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -19,6 +25,7 @@ func TestDeleteHandler(t *testing.T) {
 	cases := []struct {
 		name         string
 		alias        string
+		principal    *auth.Principal
 		setupMock    func(*mocks.URLDeleter)
 		expectedCode int
 		checkBody    bool
@@ -28,19 +35,50 @@ func TestDeleteHandler(t *testing.T) {
 			name:  "Success delete",
 			alias: "test_alias",
 			setupMock: func(m *mocks.URLDeleter) {
-				m.On("DeleteURL", "test_alias").Return(nil).Once()
+				m.On("DeleteURL", mock.Anything, "test_alias", "").Return(nil).Once()
 			},
 			expectedCode: http.StatusOK,
 			checkBody:    true,
 			expectedErr:  "",
 		},
+		{
+			name:      "Owner delete",
+			alias:     "test_alias",
+			principal: &auth.Principal{KeyID: 7, Scopes: []string{apikey.ScopeDelete}},
+			setupMock: func(m *mocks.URLDeleter) {
+				m.On("DeleteURL", mock.Anything, "test_alias", "key:7").Return(nil).Once()
+			},
+			expectedCode: http.StatusOK,
+			checkBody:    true,
+		},
+		{
+			name:      "Admin delete",
+			alias:     "test_alias",
+			principal: &auth.Principal{KeyID: 1, Scopes: []string{apikey.ScopeAdmin}},
+			setupMock: func(m *mocks.URLDeleter) {
+				m.On("DeleteURL", mock.Anything, "test_alias", "").Return(nil).Once()
+			},
+			expectedCode: http.StatusOK,
+			checkBody:    true,
+		},
+		{
+			name:      "Another owner",
+			alias:     "test_alias",
+			principal: &auth.Principal{KeyID: 8, Scopes: []string{apikey.ScopeDelete}},
+			setupMock: func(m *mocks.URLDeleter) {
+				m.On("DeleteURL", mock.Anything, "test_alias", "key:8").Return(storage.ErrNotOwner).Once()
+			},
+			expectedCode: http.StatusForbidden,
+			checkBody:    true,
+			expectedErr:  "URL belongs to another owner",
+		},
 		{
 			name:  "URL not found",
 			alias: "nonexistent",
 			setupMock: func(m *mocks.URLDeleter) {
-				m.On("DeleteURL", "nonexistent").Return(storage.ErrURLNotFound).Once()
+				m.On("DeleteURL", mock.Anything, "nonexistent", "").Return(storage.ErrURLNotFound).Once()
 			},
-			expectedCode: http.StatusOK,
+			expectedCode: http.StatusNotFound,
 			checkBody:    true,
 			expectedErr:  "URL not found",
 		},
@@ -48,24 +86,42 @@ func TestDeleteHandler(t *testing.T) {
 			name:  "Internal error",
 			alias: "test_error",
 			setupMock: func(m *mocks.URLDeleter) {
-				m.On("DeleteURL", "test_error").Return(errors.New("database error")).Once()
+				m.On("DeleteURL", mock.Anything, "test_error", "").Return(errors.New("database error")).Once()
 			},
-			expectedCode: http.StatusOK,
+			expectedCode: http.StatusInternalServerError,
 			checkBody:    true,
 			expectedErr:  "Failed to delete URL",
 		},
+		{
+			name:  "Request canceled",
+			alias: "test_canceled",
+			setupMock: func(m *mocks.URLDeleter) {
+				m.On("DeleteURL", mock.Anything, "test_canceled", "").Return(context.Canceled).Once()
+			},
+			expectedCode: http.StatusServiceUnavailable,
+			checkBody:    true,
+			expectedErr:  "Request canceled",
+		},
 		{
 			name:  "Empty alias in URL param",
 			alias: " ",
 			setupMock: func(m *mocks.URLDeleter) {
-				m.On("DeleteURL", " ").Return(storage.ErrURLNotFound).Maybe()
+				m.On("DeleteURL", mock.Anything, " ", "").Return(storage.ErrURLNotFound).Maybe()
 			},
-			expectedCode: http.StatusOK,
+			expectedCode: http.StatusNotFound,
 			checkBody:    true,
 			expectedErr:  "URL not found",
 		},
 	}
 
+	aliasPolicy, err := aliaspolicy.New(aliaspolicy.Options{
+		Length:        6,
+		Alphabet:      random.Alphabet,
+		Pattern:       ".*",
+		CaseSensitive: true,
+	})
+	require.NoError(t, err)
+
 	for _, tc := range cases {
 		tc := tc
 
@@ -75,13 +131,16 @@ func TestDeleteHandler(t *testing.T) {
 			urlDeleterMock := mocks.NewURLDeleter(t)
 			tc.setupMock(urlDeleterMock)
 
-			handler := delete.New(slogdiscard.NewDiscardLogger(), urlDeleterMock)
+			handler := delete.New(slogdiscard.NewDiscardLogger(), urlDeleterMock, aliasPolicy)
 
 			r := chi.NewRouter()
 			r.Delete("/url/{alias}", handler)
 
 			req, err := http.NewRequest(http.MethodDelete, "/url/"+tc.alias, nil)
 			require.NoError(t, err)
+			if tc.principal != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), *tc.principal))
+			}
 
 			rr := httptest.NewRecorder()
 			r.ServeHTTP(rr, req)
@@ -1,6 +1,8 @@
 package mocks
 
 import (
+	"context"
+
 	"github.com/stretchr/testify/mock"
 )
 
@@ -8,13 +10,13 @@ type URLDeleter struct {
 	mock.Mock
 }
 
-func (_m *URLDeleter) DeleteURL(alias string) error {
-	ret := _m.Called(alias)
+func (_m *URLDeleter) DeleteURL(ctx context.Context, alias string, owner string) error {
+	ret := _m.Called(ctx, alias, owner)
 
 	var r0 error
 
-	if rf, ok := ret.Get(0).(func(string) error); ok {
-		return rf(alias)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		return rf(ctx, alias, owner)
 	}
 
 	r0 = ret.Error(0)
@@ -1,10 +1,12 @@
 package mocks
 
 import (
+	"context"
 	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestURLDeleter_DeleteURL(t *testing.T) {
@@ -18,7 +20,7 @@ func TestURLDeleter_DeleteURL(t *testing.T) {
 			name:  "successful delete",
 			alias: "test123",
 			setupMock: func(m *URLDeleter) {
-				m.On("DeleteURL", "test123").Return(nil)
+				m.On("DeleteURL", mock.Anything, "test123", "").Return(nil)
 			},
 			expectedErr: nil,
 		},
@@ -26,7 +28,7 @@ func TestURLDeleter_DeleteURL(t *testing.T) {
 			name:  "URL not found",
 			alias: "nonexistent",
 			setupMock: func(m *URLDeleter) {
-				m.On("DeleteURL", "nonexistent").Return(errors.New("url not found"))
+				m.On("DeleteURL", mock.Anything, "nonexistent", "").Return(errors.New("url not found"))
 			},
 			expectedErr: errors.New("url not found"),
 		},
@@ -34,7 +36,7 @@ func TestURLDeleter_DeleteURL(t *testing.T) {
 			name:  "internal error",
 			alias: "test456",
 			setupMock: func(m *URLDeleter) {
-				m.On("DeleteURL", "test456").Return(errors.New("database error"))
+				m.On("DeleteURL", mock.Anything, "test456", "").Return(errors.New("database error"))
 			},
 			expectedErr: errors.New("database error"),
 		},
@@ -45,7 +47,7 @@ func TestURLDeleter_DeleteURL(t *testing.T) {
 			mockURLDeleter := NewURLDeleter(t)
 			tt.setupMock(mockURLDeleter)
 
-			err := mockURLDeleter.DeleteURL(tt.alias)
+			err := mockURLDeleter.DeleteURL(context.Background(), tt.alias, "")
 
 			if tt.expectedErr != nil {
 				assert.Error(t, err)
@@ -0,0 +1,133 @@
+package health
+
+import (
+	"URL-shortener/internal/lib/api/response"
+	"URL-shortener/internal/lib/buildinfo"
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/render"
+)
+
+type ReadyResponse struct {
+	response.Response
+	// Checks maps every dependency to "ok" or the reason it failed.
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+type VersionResponse struct {
+	response.Response
+	buildinfo.Info
+}
+
+// Readiness tells whether the instance should receive traffic.
+// It starts out not ready. It is safe for concurrent use.
+type Readiness struct {
+	ready atomic.Bool
+}
+
+func NewReadiness() *Readiness {
+	return &Readiness{}
+}
+
+func (r *Readiness) SetReady(ready bool) {
+	r.ready.Store(ready)
+}
+
+func (r *Readiness) Ready() bool {
+	return r.ready.Load()
+}
+
+type ReadinessChecker interface {
+	Ready() bool
+}
+
+// CheckFunc reports whether a dependency the instance needs is usable.
+type CheckFunc func(ctx context.Context) error
+
+// Live answers 200 as long as the process is able to serve requests at all.
+func Live() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, response.OK())
+	}
+}
+
+// Ready answers 200 when the instance accepts traffic and every check passes
+// within timeout, and 503 while it is starting up, draining connections
+// before shutdown, or one of its dependencies is down.
+func Ready(log *slog.Logger, readiness ReadinessChecker, timeout time.Duration, checks map[string]CheckFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.health.Ready"
+
+		log := log.With(slog.String("operation", op))
+
+		if !readiness.Ready() {
+			log.Debug("Not ready")
+
+			response.RenderError(w, r, http.StatusServiceUnavailable, response.Error(response.CodeUnavailable, "Not ready"))
+
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		resp := ReadyResponse{Response: response.OK(), Checks: runChecks(ctx, checks)}
+		for name, result := range resp.Checks {
+			if result != checkOK {
+				log.Warn("Readiness check failed", slog.String("check", name), slog.String("error", result))
+
+				resp.Response = response.Error(response.CodeUnavailable, "Dependency check failed")
+			}
+		}
+
+		if resp.Status != response.StatusOK {
+			render.Status(r, http.StatusServiceUnavailable)
+		}
+		render.JSON(w, r, resp)
+	}
+}
+
+// Version answers with the build information of the running binary.
+func Version() http.HandlerFunc {
+	info := buildinfo.Get()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, VersionResponse{Response: response.OK(), Info: info})
+	}
+}
+
+const checkOK = "ok"
+
+// runChecks runs all checks concurrently, so one slow dependency
+// doesn't eat up the time budget of the others.
+func runChecks(ctx context.Context, checks map[string]CheckFunc) map[string]string {
+	results := make(map[string]string, len(checks))
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check CheckFunc) {
+			defer wg.Done()
+
+			result := checkOK
+			if err := check(ctx); err != nil {
+				result = err.Error()
+			}
+
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,116 @@
+package health_test
+
+import (
+	"URL-shortener/internal/http-server/handlers/health"
+	"URL-shortener/internal/lib/logger/handlers/slogdiscard"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLive(t *testing.T) {
+	rr := httptest.NewRecorder()
+	health.Live().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.JSONEq(t, `{"status":"OK"}`, rr.Body.String())
+}
+
+func TestReady(t *testing.T) {
+	ok := func(context.Context) error { return nil }
+	failing := func(context.Context) error { return errors.New("connection refused") }
+	slow := func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	cases := []struct {
+		name           string
+		ready          bool
+		checks         map[string]health.CheckFunc
+		expectedCode   int
+		expectedStatus string
+		expectedChecks map[string]string
+	}{
+		{
+			name:           "Starting or draining",
+			ready:          false,
+			checks:         map[string]health.CheckFunc{"database": ok},
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedStatus: "ERROR",
+		},
+		{
+			name:           "Ready",
+			ready:          true,
+			checks:         map[string]health.CheckFunc{"database": ok, "migrations": ok},
+			expectedCode:   http.StatusOK,
+			expectedStatus: "OK",
+			expectedChecks: map[string]string{"database": "ok", "migrations": "ok"},
+		},
+		{
+			name:           "Check failed",
+			ready:          true,
+			checks:         map[string]health.CheckFunc{"database": failing, "migrations": ok},
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedStatus: "ERROR",
+			expectedChecks: map[string]string{"database": "connection refused", "migrations": "ok"},
+		},
+		{
+			name:           "Check timed out",
+			ready:          true,
+			checks:         map[string]health.CheckFunc{"database": slow},
+			expectedCode:   http.StatusServiceUnavailable,
+			expectedStatus: "ERROR",
+			expectedChecks: map[string]string{"database": context.DeadlineExceeded.Error()},
+		},
+		{
+			name:           "No checks",
+			ready:          true,
+			expectedCode:   http.StatusOK,
+			expectedStatus: "OK",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			readiness := health.NewReadiness()
+			readiness.SetReady(tc.ready)
+
+			handler := health.Ready(slogdiscard.NewDiscardLogger(), readiness, 10*time.Millisecond, tc.checks)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			require.Equal(t, tc.expectedCode, rr.Code)
+
+			var resp health.ReadyResponse
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+			require.Equal(t, tc.expectedStatus, resp.Status)
+			require.Equal(t, tc.expectedChecks, resp.Checks)
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+	rr := httptest.NewRecorder()
+	health.Version().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp health.VersionResponse
+	require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+	require.Equal(t, "OK", resp.Status)
+	require.Equal(t, runtime.Version(), resp.GoVersion)
+	require.NotEmpty(t, resp.Version)
+}
@@ -0,0 +1,61 @@
+package mocks
+
+import (
+	"URL-shortener/internal/storage"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestLinkGetter_GetLink(t *testing.T) {
+	tests := []struct {
+		name         string
+		alias        string
+		setupMock    func(*LinkGetter)
+		expectedLink storage.Link
+		expectedErr  error
+	}{
+		{
+			name:  "successful get",
+			alias: "test123",
+			setupMock: func(m *LinkGetter) {
+				m.On("GetLink", mock.Anything, "test123", "").Return(storage.Link{ID: 1, Alias: "test123"}, nil)
+			},
+			expectedLink: storage.Link{ID: 1, Alias: "test123"},
+		},
+		{
+			name:  "internal error",
+			alias: "test456",
+			setupMock: func(m *LinkGetter) {
+				m.On("GetLink", mock.Anything, "test456", "").Return(storage.Link{}, errors.New("database error"))
+			},
+			expectedErr: errors.New("database error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockLinkGetter := NewLinkGetter(t)
+			tt.setupMock(mockLinkGetter)
+
+			link, err := mockLinkGetter.GetLink(context.Background(), tt.alias, "")
+
+			assert.Equal(t, tt.expectedLink, link)
+			if tt.expectedErr != nil {
+				assert.EqualError(t, err, tt.expectedErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockLinkGetter.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNewLinkGetter(t *testing.T) {
+	mock := NewLinkGetter(t)
+	assert.NotNil(t, mock)
+}
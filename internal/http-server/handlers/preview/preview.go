@@ -0,0 +1,103 @@
+package preview
+
+import (
+	"URL-shortener/internal/lib/api/response"
+	"URL-shortener/internal/lib/logger/sl"
+	"URL-shortener/internal/lib/telemetry"
+	"URL-shortener/internal/storage"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// Response shows where a link leads. It leaves out the owner and
+// the click count, which only authenticated callers may see.
+type Response struct {
+	response.Response
+	Alias     string     `json:"alias"`
+	URL       string     `json:"url"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.43.0 --name LinkGetter
+type LinkGetter interface {
+	GetLink(ctx context.Context, alias string, owner string) (storage.Link, error)
+}
+
+// AliasNormalizer maps an alias to the form it is stored in.
+type AliasNormalizer interface {
+	Normalize(alias string) string
+}
+
+// New serves the public "/{alias}+" preview: the destination of a link
+// without redirecting to it. It answers like the redirect would for
+// missing and expired links, and doesn't count as a click.
+func New(log *slog.Logger, linkGetter LinkGetter, aliasNormalizer AliasNormalizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.preview.New"
+
+		log := log.With(
+			slog.String("operation", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", telemetry.TraceID(r.Context())),
+		)
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.Info("Alias is empty")
+
+			response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest, "Invalid request"))
+
+			return
+		}
+
+		alias = aliasNormalizer.Normalize(alias)
+
+		link, err := linkGetter.GetLink(r.Context(), alias, "")
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("URL not found", slog.String("alias", alias))
+
+			response.RenderError(w, r, http.StatusNotFound, response.Error(response.CodeNotFound, "URL not found"))
+
+			return
+		}
+		if status, resp, ok := response.ContextError(err); ok {
+			log.Warn("Getting link interrupted", sl.Err(err))
+
+			response.RenderError(w, r, status, resp)
+
+			return
+		}
+		if err != nil {
+			log.Error("Failed to get link", sl.Err(err))
+
+			response.RenderError(w, r, http.StatusInternalServerError, response.Error(response.CodeInternal, "Internal error"))
+
+			return
+		}
+		if link.Expired(time.Now()) {
+			log.Info("URL expired", slog.String("alias", alias))
+
+			response.RenderError(w, r, http.StatusGone, response.Error(response.CodeExpired, "URL expired"))
+
+			return
+		}
+
+		resp := Response{
+			Response: response.OK(),
+			Alias:    link.Alias,
+			URL:      link.URL,
+		}
+		if !link.ExpiresAt.IsZero() {
+			resp.ExpiresAt = &link.ExpiresAt
+		}
+
+		render.JSON(w, r, resp)
+	}
+}
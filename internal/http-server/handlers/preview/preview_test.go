@@ -0,0 +1,105 @@
+package preview_test
+
+import (
+	"URL-shortener/internal/http-server/handlers/preview"
+	"URL-shortener/internal/http-server/handlers/preview/mocks"
+	"URL-shortener/internal/lib/aliaspolicy"
+	"URL-shortener/internal/lib/logger/handlers/slogdiscard"
+	"URL-shortener/internal/lib/random"
+	"URL-shortener/internal/storage"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewHandler(t *testing.T) {
+	cases := []struct {
+		name         string
+		mockLink     storage.Link
+		mockError    error
+		expectedCode int
+		expectedErr  string
+	}{
+		{
+			name:         "Success",
+			mockLink:     storage.Link{Alias: "test_alias", URL: "https://google.com", Owner: "key:7", Clicks: 5},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Not expired yet",
+			mockLink:     storage.Link{Alias: "test_alias", URL: "https://google.com", ExpiresAt: time.Now().Add(time.Hour)},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Expired",
+			mockLink:     storage.Link{Alias: "test_alias", URL: "https://google.com", ExpiresAt: time.Now().Add(-time.Hour)},
+			expectedCode: http.StatusGone,
+			expectedErr:  "URL expired",
+		},
+		{
+			name:         "URL not found",
+			mockError:    storage.ErrURLNotFound,
+			expectedCode: http.StatusNotFound,
+			expectedErr:  "URL not found",
+		},
+		{
+			name:         "Internal error",
+			mockError:    errors.New("database error"),
+			expectedCode: http.StatusInternalServerError,
+			expectedErr:  "Internal error",
+		},
+	}
+
+	aliasPolicy, err := aliaspolicy.New(aliaspolicy.Options{
+		Length:        6,
+		Alphabet:      random.Alphabet,
+		Pattern:       ".*",
+		CaseSensitive: true,
+	})
+	require.NoError(t, err)
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			linkGetterMock := mocks.NewLinkGetter(t)
+			linkGetterMock.On("GetLink", mock.Anything, "test_alias", "").Return(tc.mockLink, tc.mockError).Once()
+
+			r := chi.NewRouter()
+			r.Get("/{alias}", func(w http.ResponseWriter, r *http.Request) {
+				t.Error("redirect route must not match a preview")
+			})
+			r.Get("/{alias}+", preview.New(slogdiscard.NewDiscardLogger(), linkGetterMock, aliasPolicy))
+
+			req, err := http.NewRequest(http.MethodGet, "/test_alias+", nil)
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedCode, rr.Code)
+
+			var resp preview.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			if tc.expectedErr != "" {
+				require.Contains(t, resp.Error, tc.expectedErr)
+				return
+			}
+
+			require.Equal(t, "OK", resp.Status)
+			require.Equal(t, tc.mockLink.URL, resp.URL)
+			require.Equal(t, tc.mockLink.ExpiresAt.IsZero(), resp.ExpiresAt == nil)
+			require.NotContains(t, rr.Body.String(), "key:7")
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package mocks
+
+import (
+	storage "URL-shortener/internal/storage"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+type ClickRecorder struct {
+	mock.Mock
+}
+
+func (_m *ClickRecorder) Record(click storage.Click) {
+	_m.Called(click)
+}
+
+type mockConstructorTestingTNewClickRecorder interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+func NewClickRecorder(t mockConstructorTestingTNewClickRecorder) *ClickRecorder {
+	mock := &ClickRecorder{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,24 @@
+package mocks
+
+import (
+	"URL-shortener/internal/storage"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClickRecorder_Record(t *testing.T) {
+	mockClickRecorder := NewClickRecorder(t)
+
+	click := storage.Click{Alias: "test123", Referrer: "https://example.com"}
+	mockClickRecorder.On("Record", click).Once()
+
+	mockClickRecorder.Record(click)
+
+	mockClickRecorder.AssertExpectations(t)
+}
+
+func TestNewClickRecorder(t *testing.T) {
+	mock := NewClickRecorder(t)
+	assert.NotNil(t, mock)
+}
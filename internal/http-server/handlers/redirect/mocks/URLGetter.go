@@ -1,19 +1,23 @@
 package mocks
 
-import mock "github.com/stretchr/testify/mock"
+import (
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
 
 type URLGetter struct {
 	mock.Mock
 }
 
-func (_m *URLGetter) GetURL(alias string) (string, error) {
-	ret := _m.Called(alias)
+func (_m *URLGetter) GetURL(ctx context.Context, alias string) (string, error) {
+	ret := _m.Called(ctx, alias)
 
 	var r0 string
 	var r1 error
 
-	if rf, ok := ret.Get(0).(func(string) (string, error)); ok {
-		return rf(alias)
+	if rf, ok := ret.Get(0).(func(context.Context, string) (string, error)); ok {
+		return rf(ctx, alias)
 	}
 
 	if ret.Get(0) != nil {
@@ -1,10 +1,12 @@
 package mocks
 
 import (
+	"context"
 	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestURLGetter_GetURL(t *testing.T) {
@@ -19,7 +21,7 @@ func TestURLGetter_GetURL(t *testing.T) {
 			name:  "successful get",
 			alias: "test123",
 			setupMock: func(m *URLGetter) {
-				m.On("GetURL", "test123").Return("https://example.com", nil)
+				m.On("GetURL", mock.Anything, "test123").Return("https://example.com", nil)
 			},
 			expectedURL: "https://example.com",
 			expectedErr: nil,
@@ -28,7 +30,7 @@ func TestURLGetter_GetURL(t *testing.T) {
 			name:  "URL not found",
 			alias: "nonexistent",
 			setupMock: func(m *URLGetter) {
-				m.On("GetURL", "nonexistent").Return("", errors.New("url not found"))
+				m.On("GetURL", mock.Anything, "nonexistent").Return("", errors.New("url not found"))
 			},
 			expectedURL: "",
 			expectedErr: errors.New("url not found"),
@@ -37,7 +39,7 @@ func TestURLGetter_GetURL(t *testing.T) {
 			name:  "internal error",
 			alias: "test456",
 			setupMock: func(m *URLGetter) {
-				m.On("GetURL", "test456").Return("", errors.New("database error"))
+				m.On("GetURL", mock.Anything, "test456").Return("", errors.New("database error"))
 			},
 			expectedURL: "",
 			expectedErr: errors.New("database error"),
@@ -49,7 +51,7 @@ func TestURLGetter_GetURL(t *testing.T) {
 			mockURLGetter := NewURLGetter(t)
 			tt.setupMock(mockURLGetter)
 
-			url, err := mockURLGetter.GetURL(tt.alias)
+			url, err := mockURLGetter.GetURL(context.Background(), tt.alias)
 
 			assert.Equal(t, tt.expectedURL, url)
 			if tt.expectedErr != nil {
@@ -3,44 +3,75 @@ package redirect
 import (
 	"URL-shortener/internal/lib/api/response"
 	"URL-shortener/internal/lib/logger/sl"
+	"URL-shortener/internal/lib/telemetry"
 	"URL-shortener/internal/storage"
+	"context"
 	"errors"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/render"
 )
 
 //go:generate go run github.com/vektra/mockery/v2@v2.43.0 --name URLGetter
 type URLGetter interface {
-	GetURL(alias string) (string, error)
+	GetURL(ctx context.Context, alias string) (string, error)
 }
 
-func New(log *slog.Logger, urlGetter URLGetter) http.HandlerFunc {
+// AliasNormalizer maps an alias to the form it is stored in.
+type AliasNormalizer interface {
+	Normalize(alias string) string
+}
+
+// ClickRecorder records successful redirects. Record must not block.
+type ClickRecorder interface {
+	Record(click storage.Click)
+}
+
+func New(log *slog.Logger, urlGetter URLGetter, aliasNormalizer AliasNormalizer, clickRecorder ClickRecorder) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.url.redirect.New"
 
 		log := log.With(slog.String("Operation", op),
 			slog.String("Request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", telemetry.TraceID(r.Context())),
 		)
 
 		alias := chi.URLParam(r, "alias")
 		if alias == "" {
 			log.Info("Alias is empty")
 
-			render.JSON(w, r, response.Error("Invalid request"))
+			response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest, "Invalid request"))
 
 			return
 		}
 
-		resURL, err := urlGetter.GetURL(alias)
+		alias = aliasNormalizer.Normalize(alias)
+
+		resURL, err := urlGetter.GetURL(r.Context(), alias)
 
 		if err != nil && errors.Is(err, storage.ErrURLNotFound) {
 			log.Info("URL not found", slog.String("alias", alias))
 
-			render.JSON(w, r, response.Error("URL not found"))
+			response.RenderError(w, r, http.StatusNotFound, response.Error(response.CodeNotFound, "URL not found"))
+
+			return
+		}
+
+		if errors.Is(err, storage.ErrURLExpired) {
+			log.Info("URL expired", slog.String("alias", alias))
+
+			response.RenderError(w, r, http.StatusGone, response.Error(response.CodeExpired, "URL expired"))
+
+			return
+		}
+
+		if status, resp, ok := response.ContextError(err); ok {
+			log.Warn("Getting URL interrupted", sl.Err(err))
+
+			response.RenderError(w, r, status, resp)
 
 			return
 		}
@@ -48,13 +79,21 @@ func New(log *slog.Logger, urlGetter URLGetter) http.HandlerFunc {
 		if err != nil {
 			log.Error("Failed to get URL", sl.Err(err))
 
-			render.JSON(w, r, response.Error("Internal error"))
+			response.RenderError(w, r, http.StatusInternalServerError, response.Error(response.CodeInternal, "Internal error"))
 
 			return
 		}
 
 		log.Info("Got URL", slog.String("URL", resURL))
 
+		clickRecorder.Record(storage.Click{
+			Alias:     alias,
+			ClickedAt: time.Now(),
+			Referrer:  r.Referer(),
+			UserAgent: r.UserAgent(),
+			RequestID: middleware.GetReqID(r.Context()),
+		})
+
 		http.Redirect(w, r, resURL, http.StatusFound)
 	}
 }
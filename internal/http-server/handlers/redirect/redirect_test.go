@@ -3,15 +3,20 @@ package redirect_test
 import (
 	"URL-shortener/internal/http-server/handlers/redirect"
 	"URL-shortener/internal/http-server/handlers/redirect/mocks"
+	"URL-shortener/internal/lib/aliaspolicy"
 	"URL-shortener/internal/lib/logger/handlers/slogdiscard"
+	"URL-shortener/internal/lib/random"
 	"URL-shortener/internal/storage"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
@@ -28,7 +33,7 @@ func TestRedirectHandler(t *testing.T) {
 			name:  "Success redirect",
 			alias: "test_alias",
 			setupMock: func(m *mocks.URLGetter) {
-				m.On("GetURL", "test_alias").Return("https://google.com", nil).Once()
+				m.On("GetURL", mock.Anything, "test_alias").Return("https://google.com", nil).Once()
 			},
 			expectedCode: http.StatusFound,
 			checkBody:    false,
@@ -37,35 +42,63 @@ func TestRedirectHandler(t *testing.T) {
 			name:  "URL not found",
 			alias: "nonexistent",
 			setupMock: func(m *mocks.URLGetter) {
-				m.On("GetURL", "nonexistent").Return("", storage.ErrURLNotFound).Once()
+				m.On("GetURL", mock.Anything, "nonexistent").Return("", storage.ErrURLNotFound).Once()
 			},
-			expectedCode: http.StatusOK,
+			expectedCode: http.StatusNotFound,
 			checkBody:    true,
 			expectedErr:  "URL not found",
 		},
+		{
+			name:  "URL expired",
+			alias: "expired",
+			setupMock: func(m *mocks.URLGetter) {
+				m.On("GetURL", mock.Anything, "expired").Return("", storage.ErrURLExpired).Once()
+			},
+			expectedCode: http.StatusGone,
+			checkBody:    true,
+			expectedErr:  "URL expired",
+		},
 		{
 			name:  "Internal error",
 			alias: "test_error",
 			setupMock: func(m *mocks.URLGetter) {
-				m.On("GetURL", "test_error").Return("", errors.New("database error")).Once()
+				m.On("GetURL", mock.Anything, "test_error").Return("", errors.New("database error")).Once()
 			},
-			expectedCode: http.StatusOK,
+			expectedCode: http.StatusInternalServerError,
 			checkBody:    true,
 			expectedErr:  "Internal error",
 		},
+		{
+			name:  "Storage timeout",
+			alias: "slow",
+			setupMock: func(m *mocks.URLGetter) {
+				m.On("GetURL", mock.Anything, "slow").Return("", fmt.Errorf("storage.GetURL: %w", context.DeadlineExceeded)).Once()
+			},
+			expectedCode: http.StatusGatewayTimeout,
+			checkBody:    true,
+			expectedErr:  "Request timed out",
+		},
 		{
 			name:  "Empty alias in URL param",
 			alias: " ", // Space to test empty after trim, but chi will treat this as valid param
 			setupMock: func(m *mocks.URLGetter) {
 				// This will call GetURL with space, which should fail validation or return error
-				m.On("GetURL", " ").Return("", storage.ErrURLNotFound).Maybe()
+				m.On("GetURL", mock.Anything, " ").Return("", storage.ErrURLNotFound).Maybe()
 			},
-			expectedCode: http.StatusOK,
+			expectedCode: http.StatusNotFound,
 			checkBody:    true,
 			expectedErr:  "URL not found",
 		},
 	}
 
+	aliasPolicy, err := aliaspolicy.New(aliaspolicy.Options{
+		Length:        6,
+		Alphabet:      random.Alphabet,
+		Pattern:       ".*",
+		CaseSensitive: true,
+	})
+	require.NoError(t, err)
+
 	for _, tc := range cases {
 		tc := tc
 
@@ -75,13 +108,21 @@ func TestRedirectHandler(t *testing.T) {
 			urlGetterMock := mocks.NewURLGetter(t)
 			tc.setupMock(urlGetterMock)
 
-			handler := redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock)
+			clickRecorderMock := mocks.NewClickRecorder(t)
+			if tc.expectedCode == http.StatusFound {
+				clickRecorderMock.On("Record", mock.MatchedBy(func(c storage.Click) bool {
+					return c.Alias == tc.alias && c.Referrer == "https://referrer.com" && !c.ClickedAt.IsZero()
+				})).Once()
+			}
+
+			handler := redirect.New(slogdiscard.NewDiscardLogger(), urlGetterMock, aliasPolicy, clickRecorderMock)
 
 			r := chi.NewRouter()
 			r.Get("/{alias}", handler)
 
 			req, err := http.NewRequest(http.MethodGet, "/"+tc.alias, nil)
 			require.NoError(t, err)
+			req.Header.Set("Referer", "https://referrer.com")
 
 			rr := httptest.NewRecorder()
 			r.ServeHTTP(rr, req)
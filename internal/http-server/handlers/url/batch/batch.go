@@ -0,0 +1,295 @@
+package batch
+
+import (
+	"URL-shortener/internal/http-server/handlers/url/save"
+	"URL-shortener/internal/http-server/middleware/auth"
+	"URL-shortener/internal/lib/api/response"
+	"URL-shortener/internal/lib/logger/sl"
+	"URL-shortener/internal/lib/telemetry"
+	"URL-shortener/internal/storage"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+// Result is the outcome of the request item at the same position.
+type Result struct {
+	response.Response
+	Alias     string     `json:"alias,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type Response struct {
+	response.Response
+	Created int      `json:"created"`
+	Failed  int      `json:"failed"`
+	Results []Result `json:"results"`
+}
+
+// Values of ?mode=.
+const (
+	// ModeBestEffort saves every valid item whose alias is free.
+	ModeBestEffort = "best_effort"
+	// ModeAtomic saves all items or none.
+	ModeAtomic = "atomic"
+)
+
+const (
+	maxItems     = 1000
+	maxBodyBytes = 4 << 20
+	// maxGenerateAttempts matches the single save, see handlers/url/save.
+	maxGenerateAttempts = 5
+)
+
+//go:generate go run github.com/vektra/mockery/v2@v2.43.0 --name BatchSaver
+type BatchSaver interface {
+	SaveURLs(ctx context.Context, items []storage.BatchItem, atomic bool) ([]storage.BatchResult, error)
+}
+
+// New shortens a JSON array of save requests in one round trip and
+// answers with a result per item, in input order. Items are validated
+// like single saves. The answer is 201 if every item was saved, 207 if
+// some of a best-effort batch failed, and 422 or 409 if an atomic
+// batch was rolled back.
+func New(log *slog.Logger, batchSaver BatchSaver, aliasPolicy save.AliasPolicy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.batch.New"
+
+		log := log.With(
+			slog.String("operation", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", telemetry.TraceID(r.Context())),
+		)
+
+		var atomic bool
+		switch mode := r.URL.Query().Get("mode"); mode {
+		case "", ModeBestEffort:
+		case ModeAtomic:
+			atomic = true
+		default:
+			log.Info("Invalid mode parameter", slog.String("mode", mode))
+
+			response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest, "Parameter mode must be atomic or best_effort"))
+
+			return
+		}
+
+		var reqs []save.Request
+
+		err := render.DecodeJSON(http.MaxBytesReader(w, r.Body, maxBodyBytes), &reqs)
+		if err != nil {
+			log.Error("Failed to decode request body", sl.Err(err))
+
+			response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest, "Failed to decode request"))
+
+			return
+		}
+		if len(reqs) == 0 || len(reqs) > maxItems {
+			log.Info("Invalid batch size", slog.Int("items", len(reqs)))
+
+			response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest, "Batch must hold 1 to 1000 items"))
+
+			return
+		}
+
+		log.Info("Request body decoded", slog.Int("items", len(reqs)), slog.Bool("atomic", atomic))
+
+		var owner string
+		if principal, ok := auth.FromContext(r.Context()); ok {
+			owner = principal.Subject()
+		}
+
+		resp := Response{Results: make([]Result, len(reqs))}
+
+		now := time.Now()
+		items := make([]storage.BatchItem, 0, len(reqs))
+		// positions maps items back to the requests they came from.
+		positions := make([]int, 0, len(reqs))
+		for i, req := range reqs {
+			link, itemResp, ok := save.Prepare(req, aliasPolicy, now)
+			if !ok {
+				resp.Results[i] = Result{Response: itemResp}
+				continue
+			}
+
+			link.Opts.Owner = owner
+			items = append(items, storage.BatchItem{URL: link.URL, Alias: link.Alias, Opts: link.Opts})
+			positions = append(positions, i)
+		}
+
+		if atomic && len(items) < len(reqs) {
+			log.Info("Invalid items in atomic batch", slog.Int("invalid", len(reqs)-len(items)))
+
+			renderAborted(w, r, resp, http.StatusUnprocessableEntity)
+
+			return
+		}
+
+		results, err := saveItems(r.Context(), log, batchSaver, aliasPolicy, items, atomic)
+		if status, errResp, ok := response.ContextError(err); ok {
+			log.Warn("Saving batch interrupted", sl.Err(err))
+
+			response.RenderError(w, r, status, errResp)
+
+			return
+		}
+		if err != nil {
+			log.Error("Failed to save batch", sl.Err(err))
+
+			response.RenderError(w, r, http.StatusInternalServerError, response.Error(response.CodeInternal, "Failed to save URLs"))
+
+			return
+		}
+
+		conflict := false
+		for j, result := range results {
+			item := items[j]
+			switch {
+			case result.Err == nil:
+				resp.Results[positions[j]] = Result{Response: response.OK(), Alias: item.Alias, ExpiresAt: timeOrNil(item.Opts.ExpiresAt)}
+			case errors.Is(result.Err, storage.ErrBatchAborted):
+				// Filled in by renderAborted.
+			case errors.Is(result.Err, storage.ErrURLExists) && reqs[positions[j]].Alias != "":
+				conflict = true
+				resp.Results[positions[j]] = Result{Response: response.Error(response.CodeAliasTaken, "Alias already exists")}
+			default:
+				// Only generated aliases are left, and all attempts were taken.
+				resp.Results[positions[j]] = Result{Response: response.Error(response.CodeInternal, "Failed to save URL")}
+			}
+		}
+
+		failed := countFailed(resp.Results)
+		if atomic && failed > 0 {
+			log.Info("Atomic batch rolled back", slog.Int("failed", failed))
+
+			status := http.StatusInternalServerError
+			if conflict {
+				status = http.StatusConflict
+			}
+			renderAborted(w, r, resp, status)
+
+			return
+		}
+
+		log.Info("Batch saved", slog.Int("created", len(reqs)-failed), slog.Int("failed", failed))
+
+		resp.Response = response.OK()
+		resp.Created = len(reqs) - failed
+		resp.Failed = failed
+
+		status := http.StatusCreated
+		if failed > 0 {
+			status = http.StatusMultiStatus
+		}
+		render.Status(r, status)
+		render.JSON(w, r, resp)
+	}
+}
+
+// saveItems saves items, generating aliases for the ones without,
+// and tries new aliases for generated ones that turn out to be taken.
+// An atomic batch is retried as a whole, as long as nothing else failed.
+func saveItems(ctx context.Context, log *slog.Logger, batchSaver BatchSaver, aliasPolicy save.AliasPolicy, items []storage.BatchItem, atomic bool) ([]storage.BatchResult, error) {
+	generated := make([]bool, len(items))
+	pending := make([]int, len(items))
+	for i := range items {
+		if items[i].Alias == "" {
+			alias, err := aliasPolicy.Generate()
+			if err != nil {
+				return nil, err
+			}
+			items[i].Alias = alias
+			generated[i] = true
+		}
+		pending[i] = i
+	}
+
+	results := make([]storage.BatchResult, len(items))
+
+	for attempt := 1; ; attempt++ {
+		batch := make([]storage.BatchItem, len(pending))
+		for j, i := range pending {
+			batch[j] = items[i]
+		}
+
+		saved, err := batchSaver.SaveURLs(ctx, batch, atomic)
+		if err != nil {
+			return nil, err
+		}
+
+		var collided []int
+		otherFailed := false
+		for j, i := range pending {
+			results[i] = saved[j]
+			switch {
+			case !errors.Is(saved[j].Err, storage.ErrURLExists):
+			case generated[i]:
+				collided = append(collided, i)
+			default:
+				otherFailed = true
+			}
+		}
+
+		if atomic && otherFailed {
+			// The batch fails anyway, collisions are not worth a retry.
+			for _, i := range collided {
+				results[i].Err = storage.ErrBatchAborted
+			}
+			return results, nil
+		}
+		if len(collided) == 0 || attempt == maxGenerateAttempts {
+			return results, nil
+		}
+
+		for _, i := range collided {
+			aliasPolicy.Collided()
+			log.Warn("Generated alias is taken, retrying", slog.String("alias", items[i].Alias), slog.Int("attempt", attempt))
+			alias, err := aliasPolicy.Generate()
+			if err != nil {
+				return nil, err
+			}
+			items[i].Alias = alias
+		}
+		if !atomic {
+			pending = collided
+		}
+	}
+}
+
+// renderAborted answers that nothing of an atomic batch was saved,
+// marking the items that did not fail themselves.
+func renderAborted(w http.ResponseWriter, r *http.Request, resp Response, status int) {
+	for i := range resp.Results {
+		if resp.Results[i].Status == "" || resp.Results[i].Status == response.StatusOK {
+			resp.Results[i] = Result{Response: response.Error(response.CodeBatchAborted, "Not saved, another item of the batch failed")}
+		}
+	}
+
+	resp.Response = response.Error(response.CodeBatchAborted, "Batch rolled back, no URLs saved")
+	resp.Failed = len(resp.Results)
+
+	render.Status(r, status)
+	render.JSON(w, r, resp)
+}
+
+func countFailed(results []Result) int {
+	failed := 0
+	for _, result := range results {
+		if result.Status != response.StatusOK {
+			failed++
+		}
+	}
+	return failed
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
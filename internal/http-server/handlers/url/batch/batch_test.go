@@ -0,0 +1,274 @@
+package batch_test
+
+import (
+	"URL-shortener/internal/http-server/handlers/url/batch"
+	"URL-shortener/internal/http-server/handlers/url/batch/mocks"
+	"URL-shortener/internal/http-server/middleware/auth"
+	"URL-shortener/internal/lib/aliaspolicy"
+	"URL-shortener/internal/lib/apikey"
+	"URL-shortener/internal/lib/logger/handlers/slogdiscard"
+	"URL-shortener/internal/lib/random"
+	"URL-shortener/internal/storage"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newAliasPolicy(t *testing.T) *aliaspolicy.Policy {
+	t.Helper()
+
+	policy, err := aliaspolicy.New(aliaspolicy.Options{
+		Length:             6,
+		MaxGeneratedLength: 16,
+		Alphabet:           random.Alphabet,
+		MinLength:          3,
+		MaxLength:          64,
+		Pattern:            "^[A-Za-z0-9_-]+$",
+		CaseSensitive:      true,
+		Reserved:           []string{"url"},
+	})
+	require.NoError(t, err)
+
+	return policy
+}
+
+// saveAll answers a SaveURLs call, taking the aliases in taken.
+func saveAll(atomic bool, taken ...string) func(context.Context, []storage.BatchItem, bool) ([]storage.BatchResult, error) {
+	return func(_ context.Context, items []storage.BatchItem, _ bool) ([]storage.BatchResult, error) {
+		results := make([]storage.BatchResult, len(items))
+		failed := false
+		for i, item := range items {
+			results[i].ID = int64(i + 1)
+			for _, alias := range taken {
+				if item.Alias == alias {
+					results[i] = storage.BatchResult{Err: storage.ErrURLExists}
+					failed = true
+				}
+			}
+		}
+		if failed && atomic {
+			storage.AbortBatch(results)
+		}
+		return results, nil
+	}
+}
+
+func TestBatchHandler(t *testing.T) {
+	cases := []struct {
+		name          string
+		query         string
+		body          string
+		atomic        bool
+		taken         []string
+		saves         bool
+		mockError     error
+		expectedCode  int
+		expectedErr   string
+		expectedCodes []string
+	}{
+		{
+			name:          "All saved",
+			body:          `[{"url": "https://google.com", "alias": "first"}, {"url": "https://example.com", "tags": ["promo"]}]`,
+			expectedCode:  http.StatusCreated,
+			expectedCodes: []string{"", ""},
+			saves:         true,
+		},
+		{
+			name:          "Best effort",
+			query:         "?mode=best_effort",
+			body:          `[{"url": "https://google.com", "alias": "first"}, {"url": "not a url"}, {"url": "https://example.com", "alias": "taken"}]`,
+			taken:         []string{"taken"},
+			expectedCode:  http.StatusMultiStatus,
+			expectedCodes: []string{"", "validation_failed", "alias_taken"},
+			saves:         true,
+		},
+		{
+			name:          "Atomic with invalid item",
+			query:         "?mode=atomic",
+			body:          `[{"url": "https://google.com", "alias": "first"}, {"url": "https://google.com", "ttl": "-1h"}]`,
+			atomic:        true,
+			expectedCode:  http.StatusUnprocessableEntity,
+			expectedCodes: []string{"batch_aborted", "invalid_expiry"},
+		},
+		{
+			name:          "Atomic with taken alias",
+			query:         "?mode=atomic",
+			body:          `[{"url": "https://google.com", "alias": "first"}, {"url": "https://example.com", "alias": "taken"}]`,
+			atomic:        true,
+			taken:         []string{"taken"},
+			expectedCode:  http.StatusConflict,
+			expectedCodes: []string{"batch_aborted", "alias_taken"},
+			saves:         true,
+		},
+		{
+			name:         "Invalid mode",
+			query:        "?mode=all",
+			body:         `[{"url": "https://google.com"}]`,
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "Parameter mode must be atomic or best_effort",
+		},
+		{
+			name:         "Empty batch",
+			body:         `[]`,
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "Batch must hold 1 to 1000 items",
+		},
+		{
+			name:         "Too many items",
+			body:         "[" + strings.Repeat(`{"url": "https://google.com"},`, 1000) + `{"url": "https://google.com"}]`,
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "Batch must hold 1 to 1000 items",
+		},
+		{
+			name:         "Not an array",
+			body:         `{"url": "https://google.com"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "Failed to decode request",
+		},
+		{
+			name:         "Internal error",
+			body:         `[{"url": "https://google.com"}]`,
+			mockError:    errors.New("database error"),
+			expectedCode: http.StatusInternalServerError,
+			expectedErr:  "Failed to save URLs",
+		},
+		{
+			name:         "Timeout",
+			body:         `[{"url": "https://google.com"}]`,
+			mockError:    context.DeadlineExceeded,
+			expectedCode: http.StatusGatewayTimeout,
+			expectedErr:  "Request timed out",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			batchSaverMock := mocks.NewBatchSaver(t)
+			switch {
+			case tc.mockError != nil:
+				batchSaverMock.On("SaveURLs", mock.Anything, mock.Anything, tc.atomic).Return(nil, tc.mockError).Once()
+			case tc.saves:
+				batchSaverMock.On("SaveURLs", mock.Anything, mock.Anything, tc.atomic).Return(saveAll(tc.atomic, tc.taken...)).Once()
+			}
+
+			handler := batch.New(slogdiscard.NewDiscardLogger(), batchSaverMock, newAliasPolicy(t))
+
+			req, err := http.NewRequest(http.MethodPost, "/url/batch"+tc.query, bytes.NewReader([]byte(tc.body)))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedCode, rr.Code)
+
+			var resp batch.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			if tc.expectedErr != "" {
+				require.Contains(t, resp.Error, tc.expectedErr)
+				return
+			}
+
+			require.Len(t, resp.Results, len(tc.expectedCodes))
+			failed := 0
+			for i, code := range tc.expectedCodes {
+				require.Equal(t, code, resp.Results[i].Code, "item %d", i)
+				if code == "" {
+					require.NotEmpty(t, resp.Results[i].Alias)
+				} else {
+					failed++
+				}
+			}
+			require.Equal(t, failed, resp.Failed)
+			require.Equal(t, len(tc.expectedCodes)-failed, resp.Created)
+		})
+	}
+}
+
+func TestBatchHandler_GeneratedAliasCollision(t *testing.T) {
+	cases := []struct {
+		name   string
+		query  string
+		atomic bool
+	}{
+		{name: "Best effort", query: ""},
+		{name: "Atomic", query: "?mode=atomic", atomic: true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			var firstAlias string
+
+			batchSaverMock := mocks.NewBatchSaver(t)
+			batchSaverMock.On("SaveURLs", mock.Anything, mock.Anything, tc.atomic).
+				Return(func(ctx context.Context, items []storage.BatchItem, atomic bool) ([]storage.BatchResult, error) {
+					require.Len(t, items, 2)
+					firstAlias = items[1].Alias
+					return saveAll(atomic, firstAlias)(ctx, items, atomic)
+				}).Once()
+
+			// Best effort retries the collided item alone, atomic the whole batch.
+			retried := 1
+			if tc.atomic {
+				retried = 2
+			}
+			batchSaverMock.On("SaveURLs", mock.Anything, mock.Anything, tc.atomic).
+				Return(func(ctx context.Context, items []storage.BatchItem, atomic bool) ([]storage.BatchResult, error) {
+					require.Len(t, items, retried)
+					require.NotEqual(t, firstAlias, items[retried-1].Alias)
+					return saveAll(atomic)(ctx, items, atomic)
+				}).Once()
+
+			handler := batch.New(slogdiscard.NewDiscardLogger(), batchSaverMock, newAliasPolicy(t))
+
+			body := `[{"url": "https://google.com", "alias": "custom"}, {"url": "https://example.com"}]`
+			req, err := http.NewRequest(http.MethodPost, "/url/batch"+tc.query, bytes.NewReader([]byte(body)))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, http.StatusCreated, rr.Code)
+
+			var resp batch.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, 2, resp.Created)
+			require.Equal(t, "custom", resp.Results[0].Alias)
+			require.NotEqual(t, firstAlias, resp.Results[1].Alias)
+		})
+	}
+}
+
+func TestBatchHandler_Owner(t *testing.T) {
+	batchSaverMock := mocks.NewBatchSaver(t)
+	batchSaverMock.On("SaveURLs", mock.Anything,
+		mock.MatchedBy(func(items []storage.BatchItem) bool { return items[0].Opts.Owner == "key:7" }), false).
+		Return(saveAll(false)).Once()
+
+	handler := batch.New(slogdiscard.NewDiscardLogger(), batchSaverMock, newAliasPolicy(t))
+
+	req, err := http.NewRequest(http.MethodPost, "/url/batch", bytes.NewReader([]byte(`[{"url": "https://google.com"}]`)))
+	require.NoError(t, err)
+	req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{KeyID: 7, Scopes: []string{apikey.ScopeCreate}}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusCreated, rr.Code)
+}
@@ -0,0 +1,45 @@
+package mocks
+
+import (
+	storage "URL-shortener/internal/storage"
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+type BatchSaver struct {
+	mock.Mock
+}
+
+func (_m *BatchSaver) SaveURLs(ctx context.Context, items []storage.BatchItem, atomic bool) ([]storage.BatchResult, error) {
+	ret := _m.Called(ctx, items, atomic)
+
+	var r0 []storage.BatchResult
+	var r1 error
+
+	if rf, ok := ret.Get(0).(func(context.Context, []storage.BatchItem, bool) ([]storage.BatchResult, error)); ok {
+		return rf(ctx, items, atomic)
+	}
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]storage.BatchResult)
+	}
+
+	r1 = ret.Error(1)
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewBatchSaver interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+func NewBatchSaver(t mockConstructorTestingTNewBatchSaver) *BatchSaver {
+	mock := &BatchSaver{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,61 @@
+package mocks
+
+import (
+	"URL-shortener/internal/storage"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestBatchSaver_SaveURLs(t *testing.T) {
+	tests := []struct {
+		name            string
+		items           []storage.BatchItem
+		setupMock       func(*BatchSaver)
+		expectedResults []storage.BatchResult
+		expectedErr     error
+	}{
+		{
+			name:  "successful save",
+			items: []storage.BatchItem{{URL: "https://google.com", Alias: "test123"}},
+			setupMock: func(m *BatchSaver) {
+				m.On("SaveURLs", mock.Anything, mock.Anything, true).Return([]storage.BatchResult{{ID: 1}}, nil)
+			},
+			expectedResults: []storage.BatchResult{{ID: 1}},
+		},
+		{
+			name:  "internal error",
+			items: []storage.BatchItem{{URL: "https://google.com", Alias: "test456"}},
+			setupMock: func(m *BatchSaver) {
+				m.On("SaveURLs", mock.Anything, mock.Anything, true).Return(nil, errors.New("database error"))
+			},
+			expectedErr: errors.New("database error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSaver := NewBatchSaver(t)
+			tt.setupMock(mockSaver)
+
+			results, err := mockSaver.SaveURLs(context.Background(), tt.items, true)
+
+			assert.Equal(t, tt.expectedResults, results)
+			if tt.expectedErr != nil {
+				assert.EqualError(t, err, tt.expectedErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockSaver.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNewBatchSaver(t *testing.T) {
+	mock := NewBatchSaver(t)
+	assert.NotNil(t, mock)
+}
@@ -0,0 +1,113 @@
+package history
+
+import (
+	"URL-shortener/internal/http-server/middleware/auth"
+	"URL-shortener/internal/lib/api/response"
+	"URL-shortener/internal/lib/logger/sl"
+	"URL-shortener/internal/lib/telemetry"
+	"URL-shortener/internal/storage"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	response.Response
+	Alias   string     `json:"alias"`
+	History []Revision `json:"history"`
+}
+
+// Revision is a destination the link pointed to before an update.
+type Revision struct {
+	URL       string    `json:"url"`
+	Version   int64     `json:"version"`
+	ChangedBy string    `json:"changed_by,omitempty"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.43.0 --name URLHistoryGetter
+type URLHistoryGetter interface {
+	URLHistory(ctx context.Context, alias string, owner string) ([]storage.Revision, error)
+}
+
+// AliasNormalizer maps an alias to the form it is stored in.
+type AliasNormalizer interface {
+	Normalize(alias string) string
+}
+
+// New lists the previous destinations of a link, newest first.
+func New(log *slog.Logger, historyGetter URLHistoryGetter, aliasNormalizer AliasNormalizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.history.New"
+
+		log := log.With(
+			slog.String("operation", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", telemetry.TraceID(r.Context())),
+		)
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.Info("Alias is empty")
+
+			response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest, "Invalid request"))
+
+			return
+		}
+
+		alias = aliasNormalizer.Normalize(alias)
+
+		history, err := historyGetter.URLHistory(r.Context(), alias, auth.OwnerFilter(r.Context()))
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("URL not found", slog.String("alias", alias))
+
+			response.RenderError(w, r, http.StatusNotFound, response.Error(response.CodeNotFound, "URL not found"))
+
+			return
+		}
+		if errors.Is(err, storage.ErrNotOwner) {
+			log.Info("URL belongs to another owner", slog.String("alias", alias))
+
+			response.RenderError(w, r, http.StatusForbidden, response.Error(response.CodeForbidden, "URL belongs to another owner"))
+
+			return
+		}
+		if status, resp, ok := response.ContextError(err); ok {
+			log.Warn("Getting URL history interrupted", sl.Err(err))
+
+			response.RenderError(w, r, status, resp)
+
+			return
+		}
+
+		if err != nil {
+			log.Error("Failed to get URL history", sl.Err(err))
+
+			response.RenderError(w, r, http.StatusInternalServerError, response.Error(response.CodeInternal, "Failed to get URL history"))
+
+			return
+		}
+
+		resp := Response{
+			Response: response.OK(),
+			Alias:    alias,
+			History:  make([]Revision, 0, len(history)),
+		}
+		for _, rev := range history {
+			resp.History = append(resp.History, Revision{
+				URL:       rev.URL,
+				Version:   rev.Version,
+				ChangedBy: rev.ChangedBy,
+				ChangedAt: rev.ChangedAt,
+			})
+		}
+
+		render.JSON(w, r, resp)
+	}
+}
@@ -0,0 +1,129 @@
+package history_test
+
+import (
+	"URL-shortener/internal/http-server/handlers/url/history"
+	"URL-shortener/internal/http-server/handlers/url/history/mocks"
+	"URL-shortener/internal/http-server/middleware/auth"
+	"URL-shortener/internal/lib/aliaspolicy"
+	"URL-shortener/internal/lib/apikey"
+	"URL-shortener/internal/lib/logger/handlers/slogdiscard"
+	"URL-shortener/internal/lib/random"
+	"URL-shortener/internal/storage"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistoryHandler(t *testing.T) {
+	changedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name         string
+		alias        string
+		principal    *auth.Principal
+		owner        string
+		mockHistory  []storage.Revision
+		mockError    error
+		expected     []history.Revision
+		expectedCode int
+		expectedErr  string
+	}{
+		{
+			name:  "Success",
+			alias: "test_alias",
+			mockHistory: []storage.Revision{
+				{URL: "https://example.com", Version: 2, ChangedBy: "key:1", ChangedAt: changedAt.Add(time.Hour)},
+				{URL: "https://google.com", Version: 1, ChangedAt: changedAt},
+			},
+			expected: []history.Revision{
+				{URL: "https://example.com", Version: 2, ChangedBy: "key:1", ChangedAt: changedAt.Add(time.Hour)},
+				{URL: "https://google.com", Version: 1, ChangedAt: changedAt},
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Never updated",
+			alias:        "test_alias",
+			mockHistory:  []storage.Revision{},
+			expected:     []history.Revision{},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "URL not found",
+			alias:        "nonexistent",
+			mockError:    storage.ErrURLNotFound,
+			expectedCode: http.StatusNotFound,
+			expectedErr:  "URL not found",
+		},
+		{
+			name:         "Another owner",
+			alias:        "test_alias",
+			principal:    &auth.Principal{KeyID: 8, Scopes: []string{apikey.ScopeReadStats}},
+			owner:        "key:8",
+			mockError:    storage.ErrNotOwner,
+			expectedCode: http.StatusForbidden,
+			expectedErr:  "URL belongs to another owner",
+		},
+		{
+			name:         "Internal error",
+			alias:        "test_error",
+			mockError:    errors.New("database error"),
+			expectedCode: http.StatusInternalServerError,
+			expectedErr:  "Failed to get URL history",
+		},
+	}
+
+	aliasPolicy, err := aliaspolicy.New(aliaspolicy.Options{
+		Length:        6,
+		Alphabet:      random.Alphabet,
+		Pattern:       ".*",
+		CaseSensitive: true,
+	})
+	require.NoError(t, err)
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			historyGetterMock := mocks.NewURLHistoryGetter(t)
+			historyGetterMock.On("URLHistory", mock.Anything, tc.alias, tc.owner).Return(tc.mockHistory, tc.mockError).Once()
+
+			handler := history.New(slogdiscard.NewDiscardLogger(), historyGetterMock, aliasPolicy)
+
+			r := chi.NewRouter()
+			r.Get("/url/{alias}/history", handler)
+
+			req, err := http.NewRequest(http.MethodGet, "/url/"+tc.alias+"/history", nil)
+			require.NoError(t, err)
+			if tc.principal != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), *tc.principal))
+			}
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedCode, rr.Code)
+
+			var resp history.Response
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+
+			if tc.expectedErr != "" {
+				require.Contains(t, resp.Error, tc.expectedErr)
+				return
+			}
+
+			require.Equal(t, "OK", resp.Status)
+			require.Equal(t, tc.alias, resp.Alias)
+			require.Equal(t, tc.expected, resp.History)
+		})
+	}
+}
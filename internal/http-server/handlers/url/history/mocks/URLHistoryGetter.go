@@ -0,0 +1,45 @@
+package mocks
+
+import (
+	storage "URL-shortener/internal/storage"
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+type URLHistoryGetter struct {
+	mock.Mock
+}
+
+func (_m *URLHistoryGetter) URLHistory(ctx context.Context, alias string, owner string) ([]storage.Revision, error) {
+	ret := _m.Called(ctx, alias, owner)
+
+	var r0 []storage.Revision
+	var r1 error
+
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) ([]storage.Revision, error)); ok {
+		return rf(ctx, alias, owner)
+	}
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]storage.Revision)
+	}
+
+	r1 = ret.Error(1)
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewURLHistoryGetter interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+func NewURLHistoryGetter(t mockConstructorTestingTNewURLHistoryGetter) *URLHistoryGetter {
+	mock := &URLHistoryGetter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,61 @@
+package mocks
+
+import (
+	"URL-shortener/internal/storage"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestURLHistoryGetter_URLHistory(t *testing.T) {
+	tests := []struct {
+		name            string
+		alias           string
+		setupMock       func(*URLHistoryGetter)
+		expectedHistory []storage.Revision
+		expectedErr     error
+	}{
+		{
+			name:  "successful get",
+			alias: "test123",
+			setupMock: func(m *URLHistoryGetter) {
+				m.On("URLHistory", mock.Anything, "test123", "").Return([]storage.Revision{{URL: "https://google.com", Version: 1}}, nil)
+			},
+			expectedHistory: []storage.Revision{{URL: "https://google.com", Version: 1}},
+		},
+		{
+			name:  "internal error",
+			alias: "test456",
+			setupMock: func(m *URLHistoryGetter) {
+				m.On("URLHistory", mock.Anything, "test456", "").Return(nil, errors.New("database error"))
+			},
+			expectedErr: errors.New("database error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockHistoryGetter := NewURLHistoryGetter(t)
+			tt.setupMock(mockHistoryGetter)
+
+			history, err := mockHistoryGetter.URLHistory(context.Background(), tt.alias, "")
+
+			assert.Equal(t, tt.expectedHistory, history)
+			if tt.expectedErr != nil {
+				assert.EqualError(t, err, tt.expectedErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockHistoryGetter.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNewURLHistoryGetter(t *testing.T) {
+	mock := NewURLHistoryGetter(t)
+	assert.NotNil(t, mock)
+}
@@ -0,0 +1,123 @@
+package link
+
+import (
+	"URL-shortener/internal/http-server/middleware/auth"
+	"URL-shortener/internal/lib/api/etag"
+	"URL-shortener/internal/lib/api/response"
+	"URL-shortener/internal/lib/logger/sl"
+	"URL-shortener/internal/lib/telemetry"
+	"URL-shortener/internal/storage"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	response.Response
+	ID      int64    `json:"id"`
+	Alias   string   `json:"alias"`
+	URL     string   `json:"url"`
+	Creator string   `json:"creator,omitempty"`
+	Version int64    `json:"version"`
+	Clicks  int64    `json:"clicks"`
+	Tags    []string `json:"tags"`
+	// Timestamps are omitted when unknown or unset.
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Expired   bool       `json:"expired"`
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.43.0 --name LinkGetter
+type LinkGetter interface {
+	GetLink(ctx context.Context, alias string, owner string) (storage.Link, error)
+}
+
+// AliasNormalizer maps an alias to the form it is stored in.
+type AliasNormalizer interface {
+	Normalize(alias string) string
+}
+
+// New describes a link without following it. Expired links are
+// described too, and the ETag can be used for a conditional update.
+func New(log *slog.Logger, linkGetter LinkGetter, aliasNormalizer AliasNormalizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.link.New"
+
+		log := log.With(
+			slog.String("operation", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", telemetry.TraceID(r.Context())),
+		)
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.Info("Alias is empty")
+
+			response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest, "Invalid request"))
+
+			return
+		}
+
+		alias = aliasNormalizer.Normalize(alias)
+
+		link, err := linkGetter.GetLink(r.Context(), alias, auth.OwnerFilter(r.Context()))
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("URL not found", slog.String("alias", alias))
+
+			response.RenderError(w, r, http.StatusNotFound, response.Error(response.CodeNotFound, "URL not found"))
+
+			return
+		}
+		if errors.Is(err, storage.ErrNotOwner) {
+			log.Info("URL belongs to another owner", slog.String("alias", alias))
+
+			response.RenderError(w, r, http.StatusForbidden, response.Error(response.CodeForbidden, "URL belongs to another owner"))
+
+			return
+		}
+		if status, resp, ok := response.ContextError(err); ok {
+			log.Warn("Getting link interrupted", sl.Err(err))
+
+			response.RenderError(w, r, status, resp)
+
+			return
+		}
+		if err != nil {
+			log.Error("Failed to get link", sl.Err(err))
+
+			response.RenderError(w, r, http.StatusInternalServerError, response.Error(response.CodeInternal, "Failed to get link"))
+
+			return
+		}
+
+		w.Header().Set("ETag", etag.Format(link.Version))
+		render.JSON(w, r, Response{
+			Response:  response.OK(),
+			ID:        link.ID,
+			Alias:     link.Alias,
+			URL:       link.URL,
+			Creator:   link.Owner,
+			Version:   link.Version,
+			Clicks:    link.Clicks,
+			Tags:      link.Tags,
+			CreatedAt: timeOrNil(link.CreatedAt),
+			UpdatedAt: timeOrNil(link.UpdatedAt),
+			ExpiresAt: timeOrNil(link.ExpiresAt),
+			Expired:   link.Expired(time.Now()),
+		})
+	}
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,139 @@
+package link_test
+
+import (
+	"URL-shortener/internal/http-server/handlers/url/link"
+	"URL-shortener/internal/http-server/handlers/url/link/mocks"
+	"URL-shortener/internal/http-server/middleware/auth"
+	"URL-shortener/internal/lib/aliaspolicy"
+	"URL-shortener/internal/lib/api/etag"
+	"URL-shortener/internal/lib/apikey"
+	"URL-shortener/internal/lib/logger/handlers/slogdiscard"
+	"URL-shortener/internal/lib/random"
+	"URL-shortener/internal/storage"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinkHandler(t *testing.T) {
+	createdAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+
+	cases := []struct {
+		name         string
+		principal    *auth.Principal
+		owner        string
+		mockLink     storage.Link
+		mockError    error
+		expectedCode int
+		expectedErr  string
+		expired      bool
+	}{
+		{
+			name: "Success",
+			mockLink: storage.Link{
+				ID: 1, Alias: "test_alias", URL: "https://google.com", Owner: "key:7",
+				Version: 2, Clicks: 5, CreatedAt: createdAt, UpdatedAt: createdAt,
+				Tags: []string{"docs", "promo"},
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:      "Owner",
+			principal: &auth.Principal{KeyID: 7, Scopes: []string{apikey.ScopeReadStats}},
+			owner:     "key:7",
+			mockLink: storage.Link{
+				ID: 1, Alias: "test_alias", URL: "https://google.com", Owner: "key:7", Version: 1,
+			},
+			expectedCode: http.StatusOK,
+		},
+		{
+			name: "Expired",
+			mockLink: storage.Link{
+				ID: 1, Alias: "test_alias", URL: "https://google.com", Version: 1, ExpiresAt: createdAt,
+			},
+			expectedCode: http.StatusOK,
+			expired:      true,
+		},
+		{
+			name:         "Another owner",
+			principal:    &auth.Principal{KeyID: 8, Scopes: []string{apikey.ScopeReadStats}},
+			owner:        "key:8",
+			mockError:    storage.ErrNotOwner,
+			expectedCode: http.StatusForbidden,
+			expectedErr:  "URL belongs to another owner",
+		},
+		{
+			name:         "URL not found",
+			mockError:    storage.ErrURLNotFound,
+			expectedCode: http.StatusNotFound,
+			expectedErr:  "URL not found",
+		},
+		{
+			name:         "Internal error",
+			mockError:    errors.New("database error"),
+			expectedCode: http.StatusInternalServerError,
+			expectedErr:  "Failed to get link",
+		},
+	}
+
+	aliasPolicy, err := aliaspolicy.New(aliaspolicy.Options{
+		Length:        6,
+		Alphabet:      random.Alphabet,
+		Pattern:       ".*",
+		CaseSensitive: true,
+	})
+	require.NoError(t, err)
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			linkGetterMock := mocks.NewLinkGetter(t)
+			linkGetterMock.On("GetLink", mock.Anything, "test_alias", tc.owner).Return(tc.mockLink, tc.mockError).Once()
+
+			handler := link.New(slogdiscard.NewDiscardLogger(), linkGetterMock, aliasPolicy)
+
+			r := chi.NewRouter()
+			r.Get("/url/{alias}", handler)
+
+			req, err := http.NewRequest(http.MethodGet, "/url/test_alias", nil)
+			require.NoError(t, err)
+			if tc.principal != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), *tc.principal))
+			}
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedCode, rr.Code)
+
+			var resp link.Response
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+
+			if tc.expectedErr != "" {
+				require.Contains(t, resp.Error, tc.expectedErr)
+				return
+			}
+
+			require.Equal(t, "OK", resp.Status)
+			require.Equal(t, tc.mockLink.ID, resp.ID)
+			require.Equal(t, tc.mockLink.URL, resp.URL)
+			require.Equal(t, tc.mockLink.Owner, resp.Creator)
+			require.Equal(t, tc.mockLink.Clicks, resp.Clicks)
+			require.Equal(t, tc.mockLink.Tags, resp.Tags)
+			require.Equal(t, tc.expired, resp.Expired)
+			require.Equal(t, tc.mockLink.CreatedAt.IsZero(), resp.CreatedAt == nil)
+			require.Equal(t, tc.mockLink.ExpiresAt.IsZero(), resp.ExpiresAt == nil)
+			require.Equal(t, etag.Format(tc.mockLink.Version), rr.Header().Get("ETag"))
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package mocks
+
+import (
+	storage "URL-shortener/internal/storage"
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+type LinkGetter struct {
+	mock.Mock
+}
+
+func (_m *LinkGetter) GetLink(ctx context.Context, alias string, owner string) (storage.Link, error) {
+	ret := _m.Called(ctx, alias, owner)
+
+	var r0 storage.Link
+	var r1 error
+
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (storage.Link, error)); ok {
+		return rf(ctx, alias, owner)
+	}
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.Link)
+	}
+
+	r1 = ret.Error(1)
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewLinkGetter interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+func NewLinkGetter(t mockConstructorTestingTNewLinkGetter) *LinkGetter {
+	mock := &LinkGetter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
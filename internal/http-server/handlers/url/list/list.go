@@ -0,0 +1,242 @@
+package list
+
+import (
+	"URL-shortener/internal/http-server/middleware/auth"
+	"URL-shortener/internal/lib/api/response"
+	"URL-shortener/internal/lib/logger/sl"
+	"URL-shortener/internal/lib/tags"
+	"URL-shortener/internal/lib/telemetry"
+	"URL-shortener/internal/storage"
+	"context"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	response.Response
+	Links []Link `json:"links"`
+	// NextCursor is omitted on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+type Link struct {
+	ID      int64    `json:"id"`
+	Alias   string   `json:"alias"`
+	URL     string   `json:"url"`
+	Creator string   `json:"creator,omitempty"`
+	Version int64    `json:"version"`
+	Clicks  int64    `json:"clicks"`
+	Tags    []string `json:"tags"`
+	// Timestamps are omitted when unknown or unset.
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Expired   bool       `json:"expired"`
+}
+
+// Pages hold ?limit=N links.
+const (
+	defaultLimit = 50
+	maxLimit     = 100
+	maxQuery     = 200
+)
+
+// Values of ?sort=, by creation order.
+const (
+	SortNewest = "newest"
+	SortOldest = "oldest"
+)
+
+//go:generate go run github.com/vektra/mockery/v2@v2.43.0 --name URLLister
+type URLLister interface {
+	ListURLs(ctx context.Context, opts storage.ListOptions) ([]storage.Link, error)
+}
+
+// AliasNormalizer maps an alias to the form it is stored in.
+type AliasNormalizer interface {
+	Normalize(alias string) string
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// New lists links page by page. Pages continue after the link the
+// cursor points at, so links created meanwhile don't shift them.
+// Non-admins only see their own links.
+func New(log *slog.Logger, urlLister URLLister, aliasNormalizer AliasNormalizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.list.New"
+
+		log := log.With(
+			slog.String("operation", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", telemetry.TraceID(r.Context())),
+		)
+
+		opts, msg := listOptions(r.URL.Query(), aliasNormalizer)
+		if msg != "" {
+			log.Info("Invalid list parameters", slog.String("query", r.URL.RawQuery), slog.String("reason", msg))
+
+			response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest, msg))
+
+			return
+		}
+
+		owner := auth.OwnerFilter(r.Context())
+		if owner != "" && opts.Owner != "" && opts.Owner != owner {
+			log.Info("Listing links of another creator", slog.String("creator", opts.Owner))
+
+			response.RenderError(w, r, http.StatusForbidden, response.Error(response.CodeForbidden, "Only admins may list links of other creators"))
+
+			return
+		}
+		if owner != "" {
+			opts.Owner = owner
+		}
+
+		// One extra link tells whether there is a next page.
+		limit := opts.Limit
+		opts.Limit++
+
+		links, err := urlLister.ListURLs(r.Context(), opts)
+		if status, resp, ok := response.ContextError(err); ok {
+			log.Warn("Listing URLs interrupted", sl.Err(err))
+
+			response.RenderError(w, r, status, resp)
+
+			return
+		}
+		if err != nil {
+			log.Error("Failed to list URLs", sl.Err(err))
+
+			response.RenderError(w, r, http.StatusInternalServerError, response.Error(response.CodeInternal, "Failed to list URLs"))
+
+			return
+		}
+
+		resp := Response{Response: response.OK()}
+		if len(links) > limit {
+			links = links[:limit]
+			resp.NextCursor = encodeCursor(links[limit-1].ID)
+		}
+
+		now := time.Now()
+		resp.Links = make([]Link, 0, len(links))
+		for _, link := range links {
+			resp.Links = append(resp.Links, Link{
+				ID:        link.ID,
+				Alias:     link.Alias,
+				URL:       link.URL,
+				Creator:   link.Owner,
+				Version:   link.Version,
+				Clicks:    link.Clicks,
+				Tags:      link.Tags,
+				CreatedAt: timeOrNil(link.CreatedAt),
+				UpdatedAt: timeOrNil(link.UpdatedAt),
+				ExpiresAt: timeOrNil(link.ExpiresAt),
+				Expired:   link.Expired(now),
+			})
+		}
+
+		render.JSON(w, r, resp)
+	}
+}
+
+// listOptions parses the query parameters, returning why they
+// are invalid if they are.
+func listOptions(query url.Values, aliasNormalizer AliasNormalizer) (storage.ListOptions, string) {
+	opts := storage.ListOptions{
+		Limit:  defaultLimit,
+		Owner:  query.Get("creator"),
+		Domain: query.Get("domain"),
+		Query:  query.Get("q"),
+	}
+
+	if raw := query.Get("cursor"); raw != "" {
+		after, err := decodeCursor(raw)
+		if err != nil {
+			return opts, "Parameter cursor is not valid"
+		}
+		opts.After = after
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxLimit {
+			return opts, "Parameter limit must be between 1 and 100"
+		}
+		opts.Limit = n
+	}
+
+	switch query.Get("sort") {
+	case "", SortNewest:
+	case SortOldest:
+		opts.Ascending = true
+	default:
+		return opts, "Parameter sort must be newest or oldest"
+	}
+
+	for name, dst := range map[string]*time.Time{
+		"created_after":  &opts.CreatedAfter,
+		"created_before": &opts.CreatedBefore,
+	} {
+		if raw := query.Get(name); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				return opts, "Parameter " + name + " must be an RFC 3339 time"
+			}
+			*dst = t
+		}
+	}
+
+	if raw := query.Get("alias_prefix"); raw != "" {
+		opts.AliasPrefix = aliasNormalizer.Normalize(raw)
+	}
+
+	if raw := query.Get("tag"); raw != "" {
+		tag, err := tags.Normalize(raw)
+		if err != nil {
+			return opts, "Parameter tag is not valid: " + err.Error()
+		}
+		opts.Tag = tag
+	}
+
+	if len(opts.Query) > maxQuery {
+		return opts, "Parameter q must be at most 200 bytes"
+	}
+
+	return opts, ""
+}
+
+// encodeCursor hides the id so clients don't build cursors themselves.
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, errInvalidCursor
+	}
+
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errInvalidCursor
+	}
+
+	return id, nil
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
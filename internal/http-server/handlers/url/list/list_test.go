@@ -0,0 +1,221 @@
+package list_test
+
+import (
+	"URL-shortener/internal/http-server/handlers/url/list"
+	"URL-shortener/internal/http-server/handlers/url/list/mocks"
+	"URL-shortener/internal/http-server/middleware/auth"
+	"URL-shortener/internal/lib/aliaspolicy"
+	"URL-shortener/internal/lib/apikey"
+	"URL-shortener/internal/lib/logger/handlers/slogdiscard"
+	"URL-shortener/internal/lib/random"
+	"URL-shortener/internal/storage"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListHandler(t *testing.T) {
+	createdAfter := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	cases := []struct {
+		name         string
+		query        string
+		principal    *auth.Principal
+		expectedOpts storage.ListOptions
+		mockLinks    []storage.Link
+		mockError    error
+		expectedCode int
+		expectedErr  string
+		expectedIDs  []int64
+		nextPage     bool
+	}{
+		{
+			name:         "Defaults",
+			expectedOpts: storage.ListOptions{Limit: 51},
+			mockLinks:    []storage.Link{{ID: 2, Alias: "b", Tags: []string{}}, {ID: 1, Alias: "a", Tags: []string{"docs"}}},
+			expectedCode: http.StatusOK,
+			expectedIDs:  []int64{2, 1},
+		},
+		{
+			name:         "Next page",
+			query:        "?limit=2",
+			expectedOpts: storage.ListOptions{Limit: 3},
+			mockLinks:    []storage.Link{{ID: 5}, {ID: 4}, {ID: 3}},
+			expectedCode: http.StatusOK,
+			expectedIDs:  []int64{5, 4},
+			nextPage:     true,
+		},
+		{
+			name:  "Filters",
+			query: "?sort=oldest&creator=key:7&created_after=2026-01-02T03:04:05Z&domain=Example.com&alias_prefix=Promo&tag=Docs&q=utm",
+			expectedOpts: storage.ListOptions{
+				Limit: 51, Ascending: true, Owner: "key:7", CreatedAfter: createdAfter,
+				Domain: "Example.com", AliasPrefix: "promo", Tag: "docs", Query: "utm",
+			},
+			expectedCode: http.StatusOK,
+			expectedIDs:  []int64{},
+		},
+		{
+			name:         "Owner filter",
+			principal:    &auth.Principal{KeyID: 7, Scopes: []string{apikey.ScopeReadStats}},
+			expectedOpts: storage.ListOptions{Limit: 51, Owner: "key:7"},
+			expectedCode: http.StatusOK,
+			expectedIDs:  []int64{},
+		},
+		{
+			name:         "Own creator",
+			query:        "?creator=key:7",
+			principal:    &auth.Principal{KeyID: 7, Scopes: []string{apikey.ScopeReadStats}},
+			expectedOpts: storage.ListOptions{Limit: 51, Owner: "key:7"},
+			expectedCode: http.StatusOK,
+			expectedIDs:  []int64{},
+		},
+		{
+			name:         "Another creator",
+			query:        "?creator=key:8",
+			principal:    &auth.Principal{KeyID: 7, Scopes: []string{apikey.ScopeReadStats}},
+			expectedCode: http.StatusForbidden,
+			expectedErr:  "Only admins may list links of other creators",
+		},
+		{
+			name:         "Admin lists another creator",
+			query:        "?creator=key:8",
+			principal:    &auth.Principal{KeyID: 1, Scopes: []string{apikey.ScopeAdmin}},
+			expectedOpts: storage.ListOptions{Limit: 51, Owner: "key:8"},
+			expectedCode: http.StatusOK,
+			expectedIDs:  []int64{},
+		},
+		{
+			name:         "Invalid cursor",
+			query:        "?cursor=!!",
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "Parameter cursor is not valid",
+		},
+		{
+			name:         "Limit out of range",
+			query:        "?limit=101",
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "Parameter limit must be between 1 and 100",
+		},
+		{
+			name:         "Invalid sort",
+			query:        "?sort=alias",
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "Parameter sort must be newest or oldest",
+		},
+		{
+			name:         "Invalid time",
+			query:        "?created_before=yesterday",
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "Parameter created_before must be an RFC 3339 time",
+		},
+		{
+			name:         "Invalid tag",
+			query:        "?tag=a,b",
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "Parameter tag is not valid",
+		},
+		{
+			name:         "Internal error",
+			expectedOpts: storage.ListOptions{Limit: 51},
+			mockError:    errors.New("database error"),
+			expectedCode: http.StatusInternalServerError,
+			expectedErr:  "Failed to list URLs",
+		},
+	}
+
+	aliasPolicy := newAliasPolicy(t)
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			urlListerMock := mocks.NewURLLister(t)
+			if tc.expectedCode != http.StatusBadRequest && tc.expectedCode != http.StatusForbidden {
+				urlListerMock.On("ListURLs", mock.Anything, tc.expectedOpts).Return(tc.mockLinks, tc.mockError).Once()
+			}
+
+			handler := list.New(slogdiscard.NewDiscardLogger(), urlListerMock, aliasPolicy)
+
+			req, err := http.NewRequest(http.MethodGet, "/url"+tc.query, nil)
+			require.NoError(t, err)
+			if tc.principal != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), *tc.principal))
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedCode, rr.Code)
+
+			var resp list.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			if tc.expectedErr != "" {
+				require.Contains(t, resp.Error, tc.expectedErr)
+				return
+			}
+
+			ids := []int64{}
+			for _, link := range resp.Links {
+				ids = append(ids, link.ID)
+			}
+			require.Equal(t, tc.expectedIDs, ids)
+			require.Equal(t, tc.nextPage, resp.NextCursor != "")
+		})
+	}
+}
+
+func TestListHandler_Cursor(t *testing.T) {
+	urlListerMock := mocks.NewURLLister(t)
+	urlListerMock.On("ListURLs", mock.Anything, storage.ListOptions{Limit: 2}).
+		Return([]storage.Link{{ID: 9}, {ID: 7}}, nil).Once()
+	urlListerMock.On("ListURLs", mock.Anything, storage.ListOptions{After: 9, Limit: 2}).
+		Return([]storage.Link{{ID: 7}}, nil).Once()
+
+	handler := list.New(slogdiscard.NewDiscardLogger(), urlListerMock, newAliasPolicy(t))
+
+	get := func(query string) list.Response {
+		req, err := http.NewRequest(http.MethodGet, "/url?limit=1"+query, nil)
+		require.NoError(t, err)
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var resp list.Response
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+		return resp
+	}
+
+	first := get("")
+	require.Len(t, first.Links, 1)
+	require.NotEmpty(t, first.NextCursor)
+
+	second := get("&cursor=" + first.NextCursor)
+	require.Len(t, second.Links, 1)
+	require.Equal(t, int64(7), second.Links[0].ID)
+	require.Empty(t, second.NextCursor)
+}
+
+func newAliasPolicy(t *testing.T) *aliaspolicy.Policy {
+	t.Helper()
+
+	aliasPolicy, err := aliaspolicy.New(aliaspolicy.Options{
+		Length:   6,
+		Alphabet: random.Alphabet,
+		Pattern:  ".*",
+	})
+	require.NoError(t, err)
+
+	return aliasPolicy
+}
@@ -0,0 +1,45 @@
+package mocks
+
+import (
+	storage "URL-shortener/internal/storage"
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+type URLLister struct {
+	mock.Mock
+}
+
+func (_m *URLLister) ListURLs(ctx context.Context, opts storage.ListOptions) ([]storage.Link, error) {
+	ret := _m.Called(ctx, opts)
+
+	var r0 []storage.Link
+	var r1 error
+
+	if rf, ok := ret.Get(0).(func(context.Context, storage.ListOptions) ([]storage.Link, error)); ok {
+		return rf(ctx, opts)
+	}
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]storage.Link)
+	}
+
+	r1 = ret.Error(1)
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewURLLister interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+func NewURLLister(t mockConstructorTestingTNewURLLister) *URLLister {
+	mock := &URLLister{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
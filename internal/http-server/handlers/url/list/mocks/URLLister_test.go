@@ -0,0 +1,61 @@
+package mocks
+
+import (
+	"URL-shortener/internal/storage"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestURLLister_ListURLs(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          storage.ListOptions
+		setupMock     func(*URLLister)
+		expectedLinks []storage.Link
+		expectedErr   error
+	}{
+		{
+			name: "successful list",
+			opts: storage.ListOptions{Limit: 2},
+			setupMock: func(m *URLLister) {
+				m.On("ListURLs", mock.Anything, storage.ListOptions{Limit: 2}).Return([]storage.Link{{ID: 1, Alias: "test123"}}, nil)
+			},
+			expectedLinks: []storage.Link{{ID: 1, Alias: "test123"}},
+		},
+		{
+			name: "internal error",
+			opts: storage.ListOptions{Limit: 3},
+			setupMock: func(m *URLLister) {
+				m.On("ListURLs", mock.Anything, storage.ListOptions{Limit: 3}).Return(nil, errors.New("database error"))
+			},
+			expectedErr: errors.New("database error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockLister := NewURLLister(t)
+			tt.setupMock(mockLister)
+
+			links, err := mockLister.ListURLs(context.Background(), tt.opts)
+
+			assert.Equal(t, tt.expectedLinks, links)
+			if tt.expectedErr != nil {
+				assert.EqualError(t, err, tt.expectedErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockLister.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNewURLLister(t *testing.T) {
+	mock := NewURLLister(t)
+	assert.NotNil(t, mock)
+}
@@ -1,6 +1,9 @@
 package mocks
 
 import (
+	storage "URL-shortener/internal/storage"
+	context "context"
+
 	"github.com/stretchr/testify/mock"
 )
 
@@ -8,13 +11,13 @@ type URLSaver struct {
 	mock.Mock
 }
 
-func (_m *URLSaver) SaveURL(urlToSave string, alias string) (int64, error) {
-	ret := _m.Called(urlToSave, alias)
+func (_m *URLSaver) SaveURL(ctx context.Context, urlToSave string, alias string, opts storage.SaveOptions) (int64, error) {
+	ret := _m.Called(ctx, urlToSave, alias, opts)
 
 	var r0 int64
 	var r1 error
-	if rf, ok := ret.Get(0).(func(string, string) (int64, error)); ok {
-		r0, r1 = rf(urlToSave, alias)
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, storage.SaveOptions) (int64, error)); ok {
+		r0, r1 = rf(ctx, urlToSave, alias, opts)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(int64)
@@ -25,6 +28,23 @@ func (_m *URLSaver) SaveURL(urlToSave string, alias string) (int64, error) {
 	return r0, r1
 }
 
+func (_m *URLSaver) FindURL(ctx context.Context, owner string, rawURL string) (storage.Link, error) {
+	ret := _m.Called(ctx, owner, rawURL)
+
+	var r0 storage.Link
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (storage.Link, error)); ok {
+		r0, r1 = rf(ctx, owner, rawURL)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(storage.Link)
+		}
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 type mockConstructorTestingTNewURLSaver interface {
 	mock.TestingT
 	Cleanup(func())
@@ -1,10 +1,13 @@
 package mocks
 
 import (
+	"URL-shortener/internal/storage"
+	"context"
 	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestURLSaver_SaveURL(t *testing.T) {
@@ -21,7 +24,7 @@ func TestURLSaver_SaveURL(t *testing.T) {
 			urlToSave: "https://example.com",
 			alias:     "test123",
 			setupMock: func(m *URLSaver) {
-				m.On("SaveURL", "https://example.com", "test123").Return(int64(1), nil)
+				m.On("SaveURL", mock.Anything, "https://example.com", "test123", storage.SaveOptions{}).Return(int64(1), nil)
 			},
 			expectedID:  1,
 			expectedErr: nil,
@@ -31,7 +34,7 @@ func TestURLSaver_SaveURL(t *testing.T) {
 			urlToSave: "https://example.com",
 			alias:     "duplicate",
 			setupMock: func(m *URLSaver) {
-				m.On("SaveURL", "https://example.com", "duplicate").Return(int64(0), errors.New("unique violation"))
+				m.On("SaveURL", mock.Anything, "https://example.com", "duplicate", storage.SaveOptions{}).Return(int64(0), errors.New("unique violation"))
 			},
 			expectedID:  0,
 			expectedErr: errors.New("unique violation"),
@@ -43,7 +46,7 @@ func TestURLSaver_SaveURL(t *testing.T) {
 			mockURLSaver := NewURLSaver(t)
 			tt.setupMock(mockURLSaver)
 
-			id, err := mockURLSaver.SaveURL(tt.urlToSave, tt.alias)
+			id, err := mockURLSaver.SaveURL(context.Background(), tt.urlToSave, tt.alias, storage.SaveOptions{})
 
 			assert.Equal(t, tt.expectedID, id)
 			if tt.expectedErr != nil {
@@ -58,6 +61,19 @@ func TestURLSaver_SaveURL(t *testing.T) {
 	}
 }
 
+func TestURLSaver_FindURL(t *testing.T) {
+	mockURLSaver := NewURLSaver(t)
+	mockURLSaver.On("FindURL", mock.Anything, "key:1", "https://example.com").Return(storage.Link{Alias: "test123"}, nil).Once()
+	mockURLSaver.On("FindURL", mock.Anything, "key:2", "https://example.com").Return(storage.Link{}, storage.ErrURLNotFound).Once()
+
+	link, err := mockURLSaver.FindURL(context.Background(), "key:1", "https://example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "test123", link.Alias)
+
+	_, err = mockURLSaver.FindURL(context.Background(), "key:2", "https://example.com")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
 func TestNewURLSaver(t *testing.T) {
 	mock := NewURLSaver(t)
 	assert.NotNil(t, mock)
@@ -1,11 +1,20 @@
 package save
 
 import (
+	"URL-shortener/internal/http-server/middleware/auth"
+	"URL-shortener/internal/lib/aliaspolicy"
+	"URL-shortener/internal/lib/api/etag"
 	"URL-shortener/internal/lib/api/response"
 	"URL-shortener/internal/lib/logger/sl"
-	"URL-shortener/internal/lib/random"
+	"URL-shortener/internal/lib/tags"
+	"URL-shortener/internal/lib/telemetry"
+	"URL-shortener/internal/storage"
+	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/render"
@@ -15,28 +24,62 @@ import (
 type Request struct {
 	URL   string `json:"url" validate:"required,url"`
 	Alias string `json:"alias,omitempty"`
+	// ExpiresAt and TTL are mutually exclusive ways to limit the link lifetime.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	TTL       string     `json:"ttl,omitempty"`
+	// Tags label the link for listing, see handlers/url/list.
+	Tags []string `json:"tags,omitempty"`
+	// Dedupe returns the link the owner already has for the same URL,
+	// if any, instead of creating another one. Only links without
+	// expiry and tags are reused, so it is ignored when Alias, an
+	// expiry or Tags are set. Only honored by New.
+	Dedupe bool `json:"dedupe,omitempty"`
 }
 
 type Response struct {
 	response.Response
-	Alias string `json:"alias,omitempty"`
+	Alias     string     `json:"alias,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Deduplicated is set when an existing link is returned, see Request.Dedupe.
+	Deduplicated bool `json:"deduplicated,omitempty"`
 }
 
-// TODO: move to config
-const aliasLength = 6
+var (
+	errExpiryConflict = errors.New("only one of expires_at and ttl may be set")
+	errInvalidTTL     = errors.New("ttl must be a positive duration such as 90m or 24h")
+	errExpiryInPast   = errors.New("expires_at must be in the future")
+)
+
+// maxGenerateAttempts limits how many random aliases are tried
+// before giving up on a request without a custom alias.
+const maxGenerateAttempts = 5
+
+var validate = response.NewValidator()
 
 //go:generate go run github.com/vektra/mockery/v2@v2.43.0 --name URLSaver --dir ../../../../.. --output ./mocks --filename mock_url_saver.go --with-expecter
 type URLSaver interface {
-	SaveURL(urlToSave string, alias string) (int64, error)
+	SaveURL(ctx context.Context, urlToSave string, alias string, opts storage.SaveOptions) (int64, error)
+	FindURL(ctx context.Context, owner string, rawURL string) (storage.Link, error)
 }
 
-func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
+// AliasPolicy validates custom aliases and generates random ones
+// for requests without a custom alias.
+type AliasPolicy interface {
+	Validate(alias string) error
+	Normalize(alias string) string
+	Generate() (string, error)
+	// Collided reports that a generated alias was already taken.
+	Collided()
+}
+
+func New(log *slog.Logger, urlSaver URLSaver, aliasPolicy AliasPolicy) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		const op = "handlers.url.save.New"
 
-		log = log.With(
+		log := log.With(
 			slog.String("operation", op),
 			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", telemetry.TraceID(r.Context())),
 		)
 
 		var req Request
@@ -45,47 +88,228 @@ func New(log *slog.Logger, urlSaver URLSaver) http.HandlerFunc {
 		if err != nil {
 			log.Error("Failed to decode request body", sl.Err(err))
 
-			render.JSON(w, r, response.Error("Failed to decode request"))
+			response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest, "Failed to decode request"))
 
 			return
 		}
 
 		log.Info("Request body decoded", slog.Any("request", req))
 
-		if err := validator.New().Struct(req); err != nil {
-			validateErr, ok := err.(validator.ValidationErrors)
-			if !ok {
-				log.Error("Invalid request", sl.Err(err))
-				render.JSON(w, r, response.Error("Failed to validate request"))
+		link, resp, ok := Prepare(req, aliasPolicy, time.Now())
+		if !ok {
+			log.Info("Invalid request", slog.String("error", resp.Error))
+			response.RenderError(w, r, http.StatusUnprocessableEntity, resp)
+			return
+		}
+		if principal, ok := auth.FromContext(r.Context()); ok {
+			link.Opts.Owner = principal.Subject()
+		}
+
+		if req.Dedupe && link.Alias == "" && link.Opts.ExpiresAt.IsZero() && len(link.Opts.Tags) == 0 {
+			existing, err := urlSaver.FindURL(r.Context(), link.Opts.Owner, link.URL)
+			if err == nil {
+				log.Info("URL already shortened", slog.String("alias", existing.Alias))
+				responseExisting(w, r, existing)
+				return
+			}
+			if status, resp, ok := response.ContextError(err); ok {
+				log.Warn("Looking up URL interrupted", sl.Err(err))
+				response.RenderError(w, r, status, resp)
+				return
+			}
+			if !errors.Is(err, storage.ErrURLNotFound) {
+				log.Error("Failed to look up URL", sl.Err(err))
+				response.RenderError(w, r, http.StatusInternalServerError, response.Error(response.CodeInternal, "Failed to save URL"))
 				return
 			}
+		}
+
+		alias := link.Alias
 
-			log.Error("Invalid request", sl.Err(err))
-			render.JSON(w, r, response.ValidationError(validateErr))
+		var id int64
+		if alias != "" {
+			id, err = urlSaver.SaveURL(r.Context(), link.URL, alias, link.Opts)
+		} else {
+			alias, id, err = saveWithGeneratedAlias(r.Context(), log, urlSaver, aliasPolicy, link.URL, link.Opts)
+		}
+		if errors.Is(err, storage.ErrURLExists) && req.Alias != "" {
+			log.Info("Alias already exists", slog.String("alias", alias))
+			response.RenderError(w, r, http.StatusConflict, response.Error(response.CodeAliasTaken, "Alias already exists"))
 			return
 		}
-
-		alias := req.Alias
-		if alias == "" {
-			alias = random.NewRandomString(aliasLength)
+		if status, resp, ok := response.ContextError(err); ok {
+			log.Warn("Saving URL interrupted", sl.Err(err))
+			response.RenderError(w, r, status, resp)
+			return
 		}
-
-		id, err := urlSaver.SaveURL(req.URL, alias)
 		if err != nil {
 			log.Error("Failed to save URL", sl.Err(err))
-			render.JSON(w, r, response.Error("Failed to save URL"))
+			response.RenderError(w, r, http.StatusInternalServerError, response.Error(response.CodeInternal, "Failed to save URL"))
 			return
 		}
 
 		log.Info("URL saved", slog.Int64("id", id))
 
-		responseOK(w, r, alias)
+		responseOK(w, r, alias, link.Opts.ExpiresAt)
+	}
+}
+
+// Link is a validated request, ready to be saved.
+type Link struct {
+	URL string
+	// Alias is normalized, empty means a random one is generated.
+	Alias string
+	Opts  storage.SaveOptions
+}
+
+// Prepare validates req against the alias policy. If req is invalid,
+// it returns false and the response describing why.
+// The owner is left for the caller to set.
+func Prepare(req Request, aliasPolicy AliasPolicy, now time.Time) (Link, response.Response, bool) {
+	if err := validate.Struct(req); err != nil {
+		validateErr, ok := err.(validator.ValidationErrors)
+		if !ok {
+			return Link{}, response.Error(response.CodeBadRequest, "Failed to validate request"), false
+		}
+		return Link{}, response.ValidationError(validateErr), false
+	}
+
+	expiresAt, err := req.expiration(now)
+	if err != nil {
+		return Link{}, expiryError(err), false
+	}
+
+	linkTags, err := tags.NormalizeAll(req.Tags)
+	if err != nil {
+		return Link{}, tagsError(err), false
+	}
+
+	alias := req.Alias
+	if alias != "" {
+		if err := aliasPolicy.Validate(alias); err != nil {
+			return Link{}, aliasError(err), false
+		}
+		alias = aliasPolicy.Normalize(alias)
+	}
+
+	return Link{
+		URL:   req.URL,
+		Alias: alias,
+		Opts:  storage.SaveOptions{ExpiresAt: expiresAt, Tags: linkTags},
+	}, response.Response{}, true
+}
+
+// expiration returns when the requested link should expire, zero means never.
+func (req Request) expiration(now time.Time) (time.Time, error) {
+	switch {
+	case req.ExpiresAt != nil && req.TTL != "":
+		return time.Time{}, errExpiryConflict
+	case req.ExpiresAt != nil:
+		if !req.ExpiresAt.After(now) {
+			return time.Time{}, errExpiryInPast
+		}
+		return *req.ExpiresAt, nil
+	case req.TTL != "":
+		ttl, err := time.ParseDuration(req.TTL)
+		if err != nil || ttl <= 0 {
+			return time.Time{}, errInvalidTTL
+		}
+		return now.Add(ttl), nil
+	}
+
+	return time.Time{}, nil
+}
+
+// saveWithGeneratedAlias saves the URL under a random alias,
+// generating a new one each time the previous alias turns out to be taken.
+func saveWithGeneratedAlias(ctx context.Context, log *slog.Logger, urlSaver URLSaver, aliasPolicy AliasPolicy, urlToSave string, opts storage.SaveOptions) (string, int64, error) {
+	var err error
+
+	for attempt := 1; attempt <= maxGenerateAttempts; attempt++ {
+		var alias string
+		alias, err = aliasPolicy.Generate()
+		if err != nil {
+			return "", 0, err
+		}
+
+		var id int64
+		id, err = urlSaver.SaveURL(ctx, urlToSave, alias, opts)
+		if !errors.Is(err, storage.ErrURLExists) {
+			return alias, id, err
+		}
+
+		aliasPolicy.Collided()
+		log.Warn("Generated alias is taken, retrying", slog.String("alias", alias), slog.Int("attempt", attempt))
+	}
+
+	return "", 0, fmt.Errorf("no free alias after %d attempts: %w", maxGenerateAttempts, err)
+}
+
+// aliasError describes why a custom alias was rejected by the policy.
+func aliasError(err error) response.Response {
+	code, fieldCode := response.CodeInvalidAlias, response.FieldCodeInvalid
+	if errors.Is(err, aliaspolicy.ErrReserved) {
+		code, fieldCode = response.CodeAliasReserved, response.FieldCodeReserved
+	}
+
+	msg := fmt.Sprintf("Field Alias is not valid: %s", err)
+
+	resp := response.Error(code, msg)
+	resp.Fields = []response.FieldError{{Field: "alias", Code: fieldCode, Message: msg}}
+
+	return resp
+}
+
+// expiryError describes why the requested expiration was rejected.
+func expiryError(err error) response.Response {
+	field := "expires_at"
+	if errors.Is(err, errInvalidTTL) {
+		field = "ttl"
 	}
+
+	resp := response.Error(response.CodeInvalidExpiry, err.Error())
+	resp.Fields = []response.FieldError{{Field: field, Code: response.FieldCodeInvalid, Message: err.Error()}}
+
+	return resp
+}
+
+// tagsError describes why the requested tags were rejected.
+func tagsError(err error) response.Response {
+	resp := response.Error(response.CodeValidationFailed, err.Error())
+	resp.Fields = []response.FieldError{{Field: "tags", Code: response.FieldCodeInvalid, Message: err.Error()}}
+
+	return resp
 }
 
-func responseOK(w http.ResponseWriter, r *http.Request, alias string) {
-	render.JSON(w, r, Response{
+func responseOK(w http.ResponseWriter, r *http.Request, alias string, expiresAt time.Time) {
+	resp := Response{
 		Response: response.OK(),
 		Alias:    alias,
-	})
+	}
+	if !expiresAt.IsZero() {
+		resp.ExpiresAt = &expiresAt
+	}
+
+	w.Header().Set("Location", "/"+alias)
+	// New links start at version 1, see handlers/url/update.
+	w.Header().Set("ETag", etag.Format(1))
+	render.Status(r, http.StatusCreated)
+	render.JSON(w, r, resp)
+}
+
+// responseExisting answers a deduplicated request with the link
+// the owner already has.
+func responseExisting(w http.ResponseWriter, r *http.Request, link storage.Link) {
+	resp := Response{
+		Response:     response.OK(),
+		Alias:        link.Alias,
+		Deduplicated: true,
+	}
+	if !link.ExpiresAt.IsZero() {
+		resp.ExpiresAt = &link.ExpiresAt
+	}
+
+	w.Header().Set("Location", "/"+link.Alias)
+	w.Header().Set("ETag", etag.Format(link.Version))
+	render.JSON(w, r, resp)
 }
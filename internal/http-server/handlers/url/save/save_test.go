@@ -3,25 +3,53 @@ package save_test
 import (
 	"URL-shortener/internal/http-server/handlers/url/save"
 	"URL-shortener/internal/http-server/handlers/url/save/mocks"
+	"URL-shortener/internal/http-server/middleware/auth"
+	"URL-shortener/internal/lib/aliaspolicy"
+	"URL-shortener/internal/lib/apikey"
 	"URL-shortener/internal/lib/logger/handlers/slogdiscard"
+	"URL-shortener/internal/lib/random"
+	"URL-shortener/internal/storage"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
+func newAliasPolicy(t *testing.T) *aliaspolicy.Policy {
+	t.Helper()
+
+	policy, err := aliaspolicy.New(aliaspolicy.Options{
+		Length:             6,
+		MaxGeneratedLength: 16,
+		Alphabet:           random.Alphabet,
+		MinLength:          3,
+		MaxLength:          64,
+		Pattern:            "^[A-Za-z0-9_-]+$",
+		CaseSensitive:      true,
+		Reserved:           []string{"url"},
+	})
+	require.NoError(t, err)
+
+	return policy
+}
+
 func TestSaveHandler(t *testing.T) {
 	cases := []struct {
 		name      string
 		alias     string
 		url       string
 		respError string
+		respCode  string
+		status    int
 		mockError error
 		setupMock bool
 	}{
@@ -30,6 +58,7 @@ func TestSaveHandler(t *testing.T) {
 			alias:     "test_alias",
 			url:       "https://google.com",
 			respError: "",
+			status:    http.StatusCreated,
 			setupMock: true,
 		},
 		{
@@ -37,6 +66,7 @@ func TestSaveHandler(t *testing.T) {
 			alias:     "",
 			url:       "https://google.com",
 			respError: "",
+			status:    http.StatusCreated,
 			setupMock: true,
 		},
 		{
@@ -44,6 +74,8 @@ func TestSaveHandler(t *testing.T) {
 			url:       "",
 			alias:     "some_alias",
 			respError: "Field 'URL' is required",
+			respCode:  "validation_failed",
+			status:    http.StatusUnprocessableEntity,
 			setupMock: false,
 		},
 		{
@@ -51,6 +83,8 @@ func TestSaveHandler(t *testing.T) {
 			url:       "Some invalid URL",
 			alias:     "some_alias",
 			respError: "Field URL is not a valid URL",
+			respCode:  "validation_failed",
+			status:    http.StatusUnprocessableEntity,
 			setupMock: false,
 		},
 		{
@@ -59,8 +93,57 @@ func TestSaveHandler(t *testing.T) {
 			url:       "https://google.com",
 			respError: "Failed to save URL",
 			mockError: errors.New("database error"),
+			respCode:  "internal_error",
+			status:    http.StatusInternalServerError,
+			setupMock: true,
+		},
+		{
+			name:      "SaveURL timeout",
+			alias:     "test_alias",
+			url:       "https://google.com",
+			respError: "Request timed out",
+			mockError: context.DeadlineExceeded,
+			respCode:  "timeout",
+			status:    http.StatusGatewayTimeout,
+			setupMock: true,
+		},
+		{
+			name:      "Alias exists",
+			alias:     "taken",
+			url:       "https://google.com",
+			respError: "Alias already exists",
+			respCode:  "alias_taken",
+			status:    http.StatusConflict,
+			mockError: storage.ErrURLExists,
 			setupMock: true,
 		},
+		{
+			name:      "Reserved alias",
+			alias:     "URL",
+			url:       "https://google.com",
+			respError: "Field Alias is not valid: alias is reserved",
+			respCode:  "alias_reserved",
+			status:    http.StatusUnprocessableEntity,
+			setupMock: false,
+		},
+		{
+			name:      "Alias too short",
+			alias:     "ab",
+			url:       "https://google.com",
+			respError: "alias is too short",
+			respCode:  "invalid_alias",
+			status:    http.StatusUnprocessableEntity,
+			setupMock: false,
+		},
+		{
+			name:      "Alias with slash",
+			alias:     "a/b/c",
+			url:       "https://google.com",
+			respError: "alias contains invalid characters",
+			respCode:  "invalid_alias",
+			status:    http.StatusUnprocessableEntity,
+			setupMock: false,
+		},
 	}
 
 	for _, tc := range cases {
@@ -73,15 +156,15 @@ func TestSaveHandler(t *testing.T) {
 
 			if tc.setupMock {
 				if tc.mockError != nil {
-					urlSaverMock.On("SaveURL", tc.url, mock.AnythingOfType("string")).
+					urlSaverMock.On("SaveURL", mock.Anything, tc.url, mock.AnythingOfType("string"), mock.Anything).
 						Return(int64(0), tc.mockError).Once()
 				} else {
-					urlSaverMock.On("SaveURL", tc.url, mock.AnythingOfType("string")).
+					urlSaverMock.On("SaveURL", mock.Anything, tc.url, mock.AnythingOfType("string"), mock.Anything).
 						Return(int64(1), nil).Once()
 				}
 			}
 
-			handler := save.New(slogdiscard.NewDiscardLogger(), urlSaverMock)
+			handler := save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, newAliasPolicy(t))
 
 			input := fmt.Sprintf(`{"url": "%s", "alias": "%s"}`, tc.url, tc.alias)
 
@@ -91,7 +174,7 @@ func TestSaveHandler(t *testing.T) {
 			rr := httptest.NewRecorder()
 			handler.ServeHTTP(rr, req)
 
-			require.Equal(t, http.StatusOK, rr.Code)
+			require.Equal(t, tc.status, rr.Code)
 
 			body := rr.Body.String()
 
@@ -101,9 +184,360 @@ func TestSaveHandler(t *testing.T) {
 
 			if tc.respError == "" {
 				require.Empty(t, resp.Error)
+				require.Equal(t, "/"+resp.Alias, rr.Header().Get("Location"))
 			} else {
 				require.Contains(t, resp.Error, tc.respError)
 			}
+			require.Equal(t, tc.respCode, resp.Code)
+		})
+	}
+}
+
+func TestSaveHandler_GeneratedAliasCollision(t *testing.T) {
+	cases := []struct {
+		name       string
+		collisions int
+		status     int
+	}{
+		{
+			name:       "Retry succeeds",
+			collisions: 2,
+			status:     http.StatusCreated,
+		},
+		{
+			name:       "Attempts exhausted",
+			collisions: 5,
+			status:     http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			urlSaverMock := mocks.NewURLSaver(t)
+			urlSaverMock.On("SaveURL", mock.Anything, "https://google.com", mock.AnythingOfType("string"), mock.Anything).
+				Return(int64(0), storage.ErrURLExists).Times(tc.collisions)
+			if tc.status == http.StatusCreated {
+				urlSaverMock.On("SaveURL", mock.Anything, "https://google.com", mock.AnythingOfType("string"), mock.Anything).
+					Return(int64(1), nil).Once()
+			}
+
+			handler := save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, newAliasPolicy(t))
+
+			req, err := http.NewRequest(http.MethodPost, "/save", bytes.NewReader([]byte(`{"url": "https://google.com"}`)))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code)
+
+			var resp save.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			if tc.status == http.StatusCreated {
+				require.Len(t, resp.Alias, 6)
+			} else {
+				require.Equal(t, "internal_error", resp.Code)
+			}
+		})
+	}
+}
+
+func TestSaveHandler_AliasSpaceReserved(t *testing.T) {
+	policy, err := aliaspolicy.New(aliaspolicy.Options{
+		Length:             1,
+		MaxGeneratedLength: 1,
+		Alphabet:           "ab",
+		Pattern:            "^[a-z]+$",
+		CaseSensitive:      true,
+		Reserved:           []string{"a", "b"},
+	})
+	require.NoError(t, err)
+
+	handler := save.New(slogdiscard.NewDiscardLogger(), mocks.NewURLSaver(t), policy)
+
+	req, err := http.NewRequest(http.MethodPost, "/save", bytes.NewReader([]byte(`{"url": "https://google.com"}`)))
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+
+	var resp save.Response
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Equal(t, "internal_error", resp.Code)
+}
+
+func TestSaveHandler_Owner(t *testing.T) {
+	cases := []struct {
+		name      string
+		principal *auth.Principal
+		owner     string
+	}{
+		{name: "API key", principal: &auth.Principal{KeyID: 7, Scopes: []string{apikey.ScopeCreate}}, owner: "key:7"},
+		{name: "BasicAuth", principal: &auth.Principal{Name: "admin", Scopes: []string{apikey.ScopeAdmin}}, owner: "basic:admin"},
+		{name: "Anonymous", owner: ""},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			urlSaverMock := mocks.NewURLSaver(t)
+			urlSaverMock.On("SaveURL", mock.Anything, "https://google.com", "owned",
+				mock.MatchedBy(func(opts storage.SaveOptions) bool { return opts.Owner == tc.owner })).
+				Return(int64(1), nil).Once()
+
+			handler := save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, newAliasPolicy(t))
+
+			req, err := http.NewRequest(http.MethodPost, "/save", bytes.NewReader([]byte(`{"url": "https://google.com", "alias": "owned"}`)))
+			require.NoError(t, err)
+			if tc.principal != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), *tc.principal))
+			}
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, http.StatusCreated, rr.Code)
+		})
+	}
+}
+
+func TestSaveHandler_Expiration(t *testing.T) {
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	past := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+
+	cases := []struct {
+		name      string
+		body      string
+		status    int
+		respError string
+		ttl       time.Duration
+	}{
+		{
+			name:   "TTL",
+			body:   `{"url": "https://google.com", "ttl": "24h"}`,
+			status: http.StatusCreated,
+			ttl:    24 * time.Hour,
+		},
+		{
+			name:   "Expires at",
+			body:   `{"url": "https://google.com", "expires_at": "` + future + `"}`,
+			status: http.StatusCreated,
+			ttl:    time.Hour,
+		},
+		{
+			name:      "Both set",
+			body:      `{"url": "https://google.com", "ttl": "1h", "expires_at": "` + future + `"}`,
+			status:    http.StatusUnprocessableEntity,
+			respError: "only one of expires_at and ttl may be set",
+		},
+		{
+			name:      "Expires in the past",
+			body:      `{"url": "https://google.com", "expires_at": "` + past + `"}`,
+			status:    http.StatusUnprocessableEntity,
+			respError: "expires_at must be in the future",
+		},
+		{
+			name:      "Invalid TTL",
+			body:      `{"url": "https://google.com", "ttl": "-5m"}`,
+			status:    http.StatusUnprocessableEntity,
+			respError: "ttl must be a positive duration",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			urlSaverMock := mocks.NewURLSaver(t)
+			if tc.status == http.StatusCreated {
+				urlSaverMock.On("SaveURL", mock.Anything, "https://google.com", mock.AnythingOfType("string"),
+					mock.MatchedBy(func(opts storage.SaveOptions) bool {
+						return time.Until(opts.ExpiresAt).Round(time.Minute) == tc.ttl
+					})).
+					Return(int64(1), nil).Once()
+			}
+
+			handler := save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, newAliasPolicy(t))
+
+			req, err := http.NewRequest(http.MethodPost, "/save", bytes.NewReader([]byte(tc.body)))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code)
+
+			var resp save.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			if tc.respError == "" {
+				require.NotNil(t, resp.ExpiresAt)
+			} else {
+				require.Equal(t, "invalid_expiry", resp.Code)
+				require.Contains(t, resp.Error, tc.respError)
+			}
+		})
+	}
+}
+
+func TestSaveHandler_Tags(t *testing.T) {
+	cases := []struct {
+		name     string
+		tags     string
+		status   int
+		expected []string
+	}{
+		{name: "None", tags: `[]`, status: http.StatusCreated, expected: []string{}},
+		{name: "Normalized", tags: `["Promo", "docs", "promo"]`, status: http.StatusCreated, expected: []string{"docs", "promo"}},
+		{name: "Invalid", tags: `["a,b"]`, status: http.StatusUnprocessableEntity},
+		{name: "Too many", tags: `["a","b","c","d","e","f","g","h","i","j","k"]`, status: http.StatusUnprocessableEntity},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			urlSaverMock := mocks.NewURLSaver(t)
+			if tc.status == http.StatusCreated {
+				urlSaverMock.On("SaveURL", mock.Anything, "https://google.com", "tagged",
+					mock.MatchedBy(func(opts storage.SaveOptions) bool { return reflect.DeepEqual(opts.Tags, tc.expected) })).
+					Return(int64(1), nil).Once()
+			}
+
+			handler := save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, newAliasPolicy(t))
+
+			body := `{"url": "https://google.com", "alias": "tagged", "tags": ` + tc.tags + `}`
+			req, err := http.NewRequest(http.MethodPost, "/save", bytes.NewReader([]byte(body)))
+			require.NoError(t, err)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code)
+
+			if tc.status != http.StatusCreated {
+				var resp save.Response
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+				require.Equal(t, "tags", resp.Fields[0].Field)
+			}
+		})
+	}
+}
+
+func TestSaveHandler_Dedupe(t *testing.T) {
+	cases := []struct {
+		name         string
+		body         string
+		findErr      error
+		setupFind    bool
+		setupSave    bool
+		status       int
+		deduplicated bool
+	}{
+		{
+			name:         "Existing link",
+			body:         `{"url": "https://Google.com", "dedupe": true}`,
+			setupFind:    true,
+			status:       http.StatusOK,
+			deduplicated: true,
+		},
+		{
+			name:      "New link",
+			body:      `{"url": "https://Google.com", "dedupe": true}`,
+			findErr:   storage.ErrURLNotFound,
+			setupFind: true,
+			setupSave: true,
+			status:    http.StatusCreated,
+		},
+		{
+			name:      "Custom alias skips lookup",
+			body:      `{"url": "https://Google.com", "alias": "custom", "dedupe": true}`,
+			setupSave: true,
+			status:    http.StatusCreated,
+		},
+		{
+			name:      "TTL skips lookup",
+			body:      `{"url": "https://Google.com", "ttl": "1h", "dedupe": true}`,
+			setupSave: true,
+			status:    http.StatusCreated,
+		},
+		{
+			name:      "Tags skip lookup",
+			body:      `{"url": "https://Google.com", "tags": ["promo"], "dedupe": true}`,
+			setupSave: true,
+			status:    http.StatusCreated,
+		},
+		{
+			name:      "Without dedupe",
+			body:      `{"url": "https://Google.com"}`,
+			setupSave: true,
+			status:    http.StatusCreated,
+		},
+		{
+			name:      "Lookup timeout",
+			body:      `{"url": "https://Google.com", "dedupe": true}`,
+			findErr:   context.DeadlineExceeded,
+			setupFind: true,
+			status:    http.StatusGatewayTimeout,
+		},
+		{
+			name:      "Lookup error",
+			body:      `{"url": "https://Google.com", "dedupe": true}`,
+			findErr:   errors.New("database error"),
+			setupFind: true,
+			status:    http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			urlSaverMock := mocks.NewURLSaver(t)
+			if tc.setupFind {
+				urlSaverMock.On("FindURL", mock.Anything, "key:7", "https://Google.com").
+					Return(storage.Link{Alias: "existing", Version: 3}, tc.findErr).Once()
+			}
+			if tc.setupSave {
+				urlSaverMock.On("SaveURL", mock.Anything, "https://Google.com", mock.AnythingOfType("string"), mock.Anything).
+					Return(int64(1), nil).Once()
+			}
+
+			handler := save.New(slogdiscard.NewDiscardLogger(), urlSaverMock, newAliasPolicy(t))
+
+			req, err := http.NewRequest(http.MethodPost, "/save", bytes.NewReader([]byte(tc.body)))
+			require.NoError(t, err)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{KeyID: 7, Scopes: []string{apikey.ScopeCreate}}))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.status, rr.Code)
+
+			var resp save.Response
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+			require.Equal(t, tc.deduplicated, resp.Deduplicated)
+			if tc.deduplicated {
+				require.Equal(t, "existing", resp.Alias)
+				require.Equal(t, "/existing", rr.Header().Get("Location"))
+				require.Equal(t, `"3"`, rr.Header().Get("ETag"))
+			}
 		})
 	}
 }
@@ -0,0 +1,46 @@
+package mocks
+
+import (
+	storage "URL-shortener/internal/storage"
+	context "context"
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+type URLStatsGetter struct {
+	mock.Mock
+}
+
+func (_m *URLStatsGetter) URLStats(ctx context.Context, alias string, owner string, since time.Time, topReferrers int) (storage.Stats, error) {
+	ret := _m.Called(ctx, alias, owner, since, topReferrers)
+
+	var r0 storage.Stats
+	var r1 error
+
+	if rf, ok := ret.Get(0).(func(context.Context, string, string, time.Time, int) (storage.Stats, error)); ok {
+		return rf(ctx, alias, owner, since, topReferrers)
+	}
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.Stats)
+	}
+
+	r1 = ret.Error(1)
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewURLStatsGetter interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+func NewURLStatsGetter(t mockConstructorTestingTNewURLStatsGetter) *URLStatsGetter {
+	mock := &URLStatsGetter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
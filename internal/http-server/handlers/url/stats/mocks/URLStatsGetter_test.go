@@ -0,0 +1,62 @@
+package mocks
+
+import (
+	"URL-shortener/internal/storage"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestURLStatsGetter_URLStats(t *testing.T) {
+	tests := []struct {
+		name          string
+		alias         string
+		setupMock     func(*URLStatsGetter)
+		expectedStats storage.Stats
+		expectedErr   error
+	}{
+		{
+			name:  "successful get",
+			alias: "test123",
+			setupMock: func(m *URLStatsGetter) {
+				m.On("URLStats", mock.Anything, "test123", "", mock.Anything, 10).Return(storage.Stats{TotalClicks: 3}, nil)
+			},
+			expectedStats: storage.Stats{TotalClicks: 3},
+		},
+		{
+			name:  "internal error",
+			alias: "test456",
+			setupMock: func(m *URLStatsGetter) {
+				m.On("URLStats", mock.Anything, "test456", "", mock.Anything, 10).Return(storage.Stats{}, errors.New("database error"))
+			},
+			expectedErr: errors.New("database error"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStatsGetter := NewURLStatsGetter(t)
+			tt.setupMock(mockStatsGetter)
+
+			stats, err := mockStatsGetter.URLStats(context.Background(), tt.alias, "", time.Now(), 10)
+
+			assert.Equal(t, tt.expectedStats, stats)
+			if tt.expectedErr != nil {
+				assert.EqualError(t, err, tt.expectedErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockStatsGetter.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNewURLStatsGetter(t *testing.T) {
+	mock := NewURLStatsGetter(t)
+	assert.NotNil(t, mock)
+}
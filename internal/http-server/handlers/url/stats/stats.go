@@ -0,0 +1,139 @@
+package stats
+
+import (
+	"URL-shortener/internal/http-server/middleware/auth"
+	"URL-shortener/internal/lib/api/response"
+	"URL-shortener/internal/lib/logger/sl"
+	"URL-shortener/internal/lib/telemetry"
+	"URL-shortener/internal/storage"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type Response struct {
+	response.Response
+	Alias        string           `json:"alias"`
+	TotalClicks  int64            `json:"total_clicks"`
+	ClicksPerDay []DailyClicks    `json:"clicks_per_day"`
+	TopReferrers []ReferrerClicks `json:"top_referrers"`
+}
+
+type DailyClicks struct {
+	Day    string `json:"day"`
+	Clicks int64  `json:"clicks"`
+}
+
+type ReferrerClicks struct {
+	Referrer string `json:"referrer"`
+	Clicks   int64  `json:"clicks"`
+}
+
+// Per-day counts cover the last ?days=N days, today included.
+const (
+	defaultDays  = 30
+	maxDays      = 365
+	topReferrers = 10
+)
+
+//go:generate go run github.com/vektra/mockery/v2@v2.43.0 --name URLStatsGetter
+type URLStatsGetter interface {
+	URLStats(ctx context.Context, alias string, owner string, since time.Time, topReferrers int) (storage.Stats, error)
+}
+
+// AliasNormalizer maps an alias to the form it is stored in.
+type AliasNormalizer interface {
+	Normalize(alias string) string
+}
+
+func New(log *slog.Logger, statsGetter URLStatsGetter, aliasNormalizer AliasNormalizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.stats.New"
+
+		log := log.With(
+			slog.String("operation", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", telemetry.TraceID(r.Context())),
+		)
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.Info("Alias is empty")
+
+			response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest, "Invalid request"))
+
+			return
+		}
+
+		alias = aliasNormalizer.Normalize(alias)
+
+		days := defaultDays
+		if raw := r.URL.Query().Get("days"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 1 || n > maxDays {
+				log.Info("Invalid days parameter", slog.String("days", raw))
+
+				response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest, "Parameter days must be between 1 and 365"))
+
+				return
+			}
+			days = n
+		}
+
+		since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-days)
+
+		stats, err := statsGetter.URLStats(r.Context(), alias, auth.OwnerFilter(r.Context()), since, topReferrers)
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("URL not found", slog.String("alias", alias))
+
+			response.RenderError(w, r, http.StatusNotFound, response.Error(response.CodeNotFound, "URL not found"))
+
+			return
+		}
+		if errors.Is(err, storage.ErrNotOwner) {
+			log.Info("URL belongs to another owner", slog.String("alias", alias))
+
+			response.RenderError(w, r, http.StatusForbidden, response.Error(response.CodeForbidden, "URL belongs to another owner"))
+
+			return
+		}
+		if status, resp, ok := response.ContextError(err); ok {
+			log.Warn("Getting URL stats interrupted", sl.Err(err))
+
+			response.RenderError(w, r, status, resp)
+
+			return
+		}
+
+		if err != nil {
+			log.Error("Failed to get URL stats", sl.Err(err))
+
+			response.RenderError(w, r, http.StatusInternalServerError, response.Error(response.CodeInternal, "Failed to get URL stats"))
+
+			return
+		}
+
+		resp := Response{
+			Response:     response.OK(),
+			Alias:        alias,
+			TotalClicks:  stats.TotalClicks,
+			ClicksPerDay: make([]DailyClicks, 0, len(stats.ClicksPerDay)),
+			TopReferrers: make([]ReferrerClicks, 0, len(stats.TopReferrers)),
+		}
+		for _, d := range stats.ClicksPerDay {
+			resp.ClicksPerDay = append(resp.ClicksPerDay, DailyClicks{Day: d.Day, Clicks: d.Clicks})
+		}
+		for _, rc := range stats.TopReferrers {
+			resp.TopReferrers = append(resp.TopReferrers, ReferrerClicks{Referrer: rc.Referrer, Clicks: rc.Clicks})
+		}
+
+		render.JSON(w, r, resp)
+	}
+}
@@ -0,0 +1,152 @@
+package stats_test
+
+import (
+	"URL-shortener/internal/http-server/handlers/url/stats"
+	"URL-shortener/internal/http-server/handlers/url/stats/mocks"
+	"URL-shortener/internal/http-server/middleware/auth"
+	"URL-shortener/internal/lib/aliaspolicy"
+	"URL-shortener/internal/lib/apikey"
+	"URL-shortener/internal/lib/logger/handlers/slogdiscard"
+	"URL-shortener/internal/lib/random"
+	"URL-shortener/internal/storage"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsHandler(t *testing.T) {
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	cases := []struct {
+		name          string
+		alias         string
+		query         string
+		principal     *auth.Principal
+		owner         string
+		mockStats     storage.Stats
+		mockError     error
+		expectedSince time.Time
+		expectedCode  int
+		expectedErr   string
+	}{
+		{
+			name:  "Success",
+			alias: "test_alias",
+			mockStats: storage.Stats{
+				TotalClicks:  3,
+				ClicksPerDay: []storage.DailyClicks{{Day: today.Format(time.DateOnly), Clicks: 3}},
+				TopReferrers: []storage.ReferrerClicks{{Referrer: "https://referrer.com", Clicks: 2}},
+			},
+			expectedSince: today.AddDate(0, 0, -29),
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:          "Custom days",
+			alias:         "test_alias",
+			query:         "?days=7",
+			expectedSince: today.AddDate(0, 0, -6),
+			expectedCode:  http.StatusOK,
+		},
+		{
+			name:         "Invalid days",
+			alias:        "test_alias",
+			query:        "?days=abc",
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "Parameter days must be between 1 and 365",
+		},
+		{
+			name:         "Days out of range",
+			alias:        "test_alias",
+			query:        "?days=366",
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "Parameter days must be between 1 and 365",
+		},
+		{
+			name:          "URL not found",
+			alias:         "nonexistent",
+			mockError:     storage.ErrURLNotFound,
+			expectedSince: today.AddDate(0, 0, -29),
+			expectedCode:  http.StatusNotFound,
+			expectedErr:   "URL not found",
+		},
+		{
+			name:          "Another owner",
+			alias:         "test_alias",
+			principal:     &auth.Principal{KeyID: 8, Scopes: []string{apikey.ScopeReadStats}},
+			owner:         "key:8",
+			mockError:     storage.ErrNotOwner,
+			expectedSince: today.AddDate(0, 0, -29),
+			expectedCode:  http.StatusForbidden,
+			expectedErr:   "URL belongs to another owner",
+		},
+		{
+			name:          "Internal error",
+			alias:         "test_error",
+			mockError:     errors.New("database error"),
+			expectedSince: today.AddDate(0, 0, -29),
+			expectedCode:  http.StatusInternalServerError,
+			expectedErr:   "Failed to get URL stats",
+		},
+	}
+
+	aliasPolicy, err := aliaspolicy.New(aliaspolicy.Options{
+		Length:        6,
+		Alphabet:      random.Alphabet,
+		Pattern:       ".*",
+		CaseSensitive: true,
+	})
+	require.NoError(t, err)
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			statsGetterMock := mocks.NewURLStatsGetter(t)
+			if !tc.expectedSince.IsZero() {
+				statsGetterMock.On("URLStats", mock.Anything, tc.alias, tc.owner, mock.MatchedBy(func(since time.Time) bool {
+					// The day may roll over between computing the expectation and the request.
+					return since.Equal(tc.expectedSince) || since.Equal(tc.expectedSince.AddDate(0, 0, 1))
+				}), 10).Return(tc.mockStats, tc.mockError).Once()
+			}
+
+			handler := stats.New(slogdiscard.NewDiscardLogger(), statsGetterMock, aliasPolicy)
+
+			r := chi.NewRouter()
+			r.Get("/url/{alias}/stats", handler)
+
+			req, err := http.NewRequest(http.MethodGet, "/url/"+tc.alias+"/stats"+tc.query, nil)
+			require.NoError(t, err)
+			if tc.principal != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), *tc.principal))
+			}
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedCode, rr.Code)
+
+			var resp stats.Response
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+
+			if tc.expectedErr != "" {
+				require.Contains(t, resp.Error, tc.expectedErr)
+				return
+			}
+
+			require.Equal(t, "OK", resp.Status)
+			require.Equal(t, tc.alias, resp.Alias)
+			require.Equal(t, tc.mockStats.TotalClicks, resp.TotalClicks)
+			require.Len(t, resp.ClicksPerDay, len(tc.mockStats.ClicksPerDay))
+			require.Len(t, resp.TopReferrers, len(tc.mockStats.TopReferrers))
+		})
+	}
+}
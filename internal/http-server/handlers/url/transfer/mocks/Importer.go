@@ -0,0 +1,83 @@
+package mocks
+
+import (
+	storage "URL-shortener/internal/storage"
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+type Importer struct {
+	mock.Mock
+}
+
+func (_m *Importer) SaveURLs(ctx context.Context, items []storage.BatchItem, atomic bool) ([]storage.BatchResult, error) {
+	ret := _m.Called(ctx, items, atomic)
+
+	var r0 []storage.BatchResult
+	var r1 error
+
+	if rf, ok := ret.Get(0).(func(context.Context, []storage.BatchItem, bool) ([]storage.BatchResult, error)); ok {
+		return rf(ctx, items, atomic)
+	}
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]storage.BatchResult)
+	}
+
+	r1 = ret.Error(1)
+
+	return r0, r1
+}
+
+func (_m *Importer) GetLink(ctx context.Context, alias string, owner string) (storage.Link, error) {
+	ret := _m.Called(ctx, alias, owner)
+
+	var r0 storage.Link
+	var r1 error
+
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) (storage.Link, error)); ok {
+		return rf(ctx, alias, owner)
+	}
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.Link)
+	}
+
+	r1 = ret.Error(1)
+
+	return r0, r1
+}
+
+func (_m *Importer) UpdateURL(ctx context.Context, alias string, upd storage.URLUpdate) (int64, error) {
+	ret := _m.Called(ctx, alias, upd)
+
+	var r0 int64
+	var r1 error
+
+	if rf, ok := ret.Get(0).(func(context.Context, string, storage.URLUpdate) (int64, error)); ok {
+		return rf(ctx, alias, upd)
+	}
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(int64)
+	}
+
+	r1 = ret.Error(1)
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewImporter interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+func NewImporter(t mockConstructorTestingTNewImporter) *Importer {
+	mock := &Importer{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
@@ -0,0 +1,73 @@
+package mocks
+
+import (
+	"URL-shortener/internal/storage"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestImporter_SaveURLs(t *testing.T) {
+	mockImporter := NewImporter(t)
+	mockImporter.On("SaveURLs", mock.Anything, mock.Anything, false).Return([]storage.BatchResult{{ID: 1}}, nil)
+
+	results, err := mockImporter.SaveURLs(context.Background(), []storage.BatchItem{{URL: "https://google.com", Alias: "test123"}}, false)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.BatchResult{{ID: 1}}, results)
+}
+
+func TestImporter_GetLink(t *testing.T) {
+	tests := []struct {
+		name         string
+		setupMock    func(*Importer)
+		expectedLink storage.Link
+		expectedErr  error
+	}{
+		{
+			name: "found",
+			setupMock: func(m *Importer) {
+				m.On("GetLink", mock.Anything, "test123", "").Return(storage.Link{Alias: "test123"}, nil)
+			},
+			expectedLink: storage.Link{Alias: "test123"},
+		},
+		{
+			name: "not found",
+			setupMock: func(m *Importer) {
+				m.On("GetLink", mock.Anything, "test123", "").Return(storage.Link{}, storage.ErrURLNotFound)
+			},
+			expectedErr: storage.ErrURLNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockImporter := NewImporter(t)
+			tt.setupMock(mockImporter)
+
+			link, err := mockImporter.GetLink(context.Background(), "test123", "")
+
+			assert.Equal(t, tt.expectedLink, link)
+			assert.ErrorIs(t, err, tt.expectedErr)
+
+			mockImporter.AssertExpectations(t)
+		})
+	}
+}
+
+func TestImporter_UpdateURL(t *testing.T) {
+	mockImporter := NewImporter(t)
+	mockImporter.On("UpdateURL", mock.Anything, "test123", storage.URLUpdate{URL: "https://google.com"}).Return(int64(0), errors.New("database error"))
+
+	_, err := mockImporter.UpdateURL(context.Background(), "test123", storage.URLUpdate{URL: "https://google.com"})
+
+	assert.EqualError(t, err, "database error")
+}
+
+func TestNewImporter(t *testing.T) {
+	mock := NewImporter(t)
+	assert.NotNil(t, mock)
+}
@@ -0,0 +1,240 @@
+package transfer
+
+import (
+	"URL-shortener/internal/http-server/handlers/url/save"
+	"URL-shortener/internal/http-server/middleware/auth"
+	"URL-shortener/internal/lib/api/response"
+	"URL-shortener/internal/lib/apikey"
+	"URL-shortener/internal/lib/linkfile"
+	"URL-shortener/internal/lib/logger/sl"
+	"URL-shortener/internal/lib/telemetry"
+	"URL-shortener/internal/storage"
+	"context"
+	"errors"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+)
+
+type ImportResponse struct {
+	response.Response
+	linkfile.Summary
+}
+
+// maxImportBytes limits the size of an uploaded file.
+const maxImportBytes = 64 << 20
+
+//go:generate go run github.com/vektra/mockery/v2@v2.43.0 --name Exporter
+type Exporter interface {
+	ListURLs(ctx context.Context, opts storage.ListOptions) ([]storage.Link, error)
+}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.43.0 --name Importer
+type Importer interface {
+	SaveURLs(ctx context.Context, items []storage.BatchItem, atomic bool) ([]storage.BatchResult, error)
+	GetLink(ctx context.Context, alias string, owner string) (storage.Link, error)
+	UpdateURL(ctx context.Context, alias string, upd storage.URLUpdate) (int64, error)
+}
+
+// Export streams the links as ?format=csv or jsonl (the default),
+// oldest first. Non-admins only get their own links. The file can be
+// fed back to Import as is.
+func Export(log *slog.Logger, exporter Exporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.transfer.Export"
+
+		log := log.With(
+			slog.String("operation", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", telemetry.TraceID(r.Context())),
+		)
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = linkfile.FormatJSONL
+		}
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		enc, err := linkfile.NewEncoder(ww, format)
+		if err != nil {
+			log.Info("Invalid format parameter", slog.String("format", format))
+
+			response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest, "Parameter format must be csv or jsonl"))
+
+			return
+		}
+
+		extendDeadlines(w, r)
+
+		w.Header().Set("Content-Type", linkfile.ContentType(format))
+		w.Header().Set("Content-Disposition", `attachment; filename="links.`+format+`"`)
+
+		rc := http.NewResponseController(w)
+
+		written, err := linkfile.Export(r.Context(), exporter, enc, auth.OwnerFilter(r.Context()), func() {
+			// Not every writer can flush, the rows still arrive at the end.
+			_ = rc.Flush()
+		})
+		if err != nil && ww.BytesWritten() == 0 {
+			log.Error("Failed to export URLs", sl.Err(err))
+
+			w.Header().Del("Content-Disposition")
+			if status, errResp, ok := response.ContextError(err); ok {
+				response.RenderError(w, r, status, errResp)
+				return
+			}
+			response.RenderError(w, r, http.StatusInternalServerError, response.Error(response.CodeInternal, "Failed to export URLs"))
+
+			return
+		}
+		if err != nil {
+			log.Error("Export interrupted", slog.Int("written", written), sl.Err(err))
+
+			// The status is sent already. Aborting the connection is the
+			// only way to tell the client the file is incomplete.
+			panic(http.ErrAbortHandler)
+		}
+
+		log.Info("URLs exported", slog.Int("written", written), slog.String("format", format))
+	}
+}
+
+// Import saves the links of an uploaded CSV or JSON Lines file. Each row
+// is validated like a POST /url request, rows that fail are listed in
+// the summary and the rest are saved. Taken aliases are handled as
+// ?on_conflict= says, overwriting needs the update scope as well.
+// With ?dry_run=true nothing is saved.
+func Import(log *slog.Logger, importer Importer, aliasPolicy save.AliasPolicy) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.transfer.Import"
+
+		log := log.With(
+			slog.String("operation", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", telemetry.TraceID(r.Context())),
+		)
+
+		query := r.URL.Query()
+
+		format := query.Get("format")
+		if format == "" {
+			format = linkfile.FormatJSONL
+			if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "text/csv" {
+				format = linkfile.FormatCSV
+			}
+		}
+
+		onConflict := query.Get("on_conflict")
+		if onConflict == "" {
+			onConflict = linkfile.ConflictSkip
+		}
+
+		var dryRun bool
+		if raw := query.Get("dry_run"); raw != "" {
+			var err error
+			if dryRun, err = strconv.ParseBool(raw); err != nil {
+				log.Info("Invalid dry_run parameter", slog.String("dry_run", raw))
+
+				response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest, "Parameter dry_run must be true or false"))
+
+				return
+			}
+		}
+
+		var creator string
+		principal, ok := auth.FromContext(r.Context())
+		if ok {
+			creator = principal.Subject()
+		}
+		if ok && onConflict == linkfile.ConflictOverwrite && !apikey.HasScope(principal.Scopes, apikey.ScopeUpdate) {
+			response.RenderError(w, r, http.StatusForbidden, response.Error(response.CodeForbidden, "Missing scope "+apikey.ScopeUpdate))
+
+			return
+		}
+
+		dec, err := linkfile.NewDecoder(http.MaxBytesReader(w, r.Body, maxImportBytes), format)
+		if err != nil {
+			log.Info("Invalid format parameter", slog.String("format", format))
+
+			response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest, "Parameter format must be csv or jsonl"))
+
+			return
+		}
+
+		extendDeadlines(w, r)
+
+		summary, err := linkfile.Import(r.Context(), importer, dec, aliasPolicy, linkfile.ImportOptions{
+			OnConflict: onConflict,
+			DryRun:     dryRun,
+			Creator:    creator,
+			Owner:      auth.OwnerFilter(r.Context()),
+			Progress: func(s linkfile.Summary) {
+				log.Info("Import progress",
+					slog.Int("rows", s.Rows),
+					slog.Int("created", s.Created),
+					slog.Int("updated", s.Updated),
+					slog.Int("renamed", s.Renamed),
+					slog.Int("skipped", s.Skipped),
+					slog.Int("failed", s.Failed),
+				)
+			},
+		})
+		if err != nil {
+			status, errResp := importError(err)
+			log.Error("Import stopped", slog.Int("rows", summary.Rows), sl.Err(err))
+
+			// The rows before the error are saved, say which.
+			render.Status(r, status)
+			render.JSON(w, r, ImportResponse{Response: errResp, Summary: summary})
+
+			return
+		}
+
+		log.Info("URLs imported",
+			slog.Bool("dry_run", dryRun),
+			slog.Int("rows", summary.Rows),
+			slog.Int("failed", summary.Failed),
+		)
+
+		render.JSON(w, r, ImportResponse{Response: response.OK(), Summary: summary})
+	}
+}
+
+func importError(err error) (int, response.Response) {
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.Is(err, linkfile.ErrUnknownConflict):
+		return http.StatusBadRequest, response.Error(response.CodeBadRequest, "Parameter on_conflict must be skip, overwrite or rename")
+	case errors.Is(err, linkfile.ErrInvalidFile):
+		return http.StatusBadRequest, response.Error(response.CodeBadRequest, err.Error())
+	case errors.As(err, &maxBytesErr):
+		return http.StatusRequestEntityTooLarge, response.Error(response.CodeBadRequest, "File is larger than 64 MiB")
+	}
+
+	if status, errResp, ok := response.ContextError(err); ok {
+		return status, errResp
+	}
+
+	return http.StatusInternalServerError, response.Error(response.CodeInternal, "Import stopped, failed to save URLs")
+}
+
+// extendDeadlines lets the connection live as long as the request
+// context, which the transfer routes give more time than the server's
+// read and write timeouts.
+func extendDeadlines(w http.ResponseWriter, r *http.Request) {
+	deadline, ok := r.Context().Deadline()
+	if !ok {
+		return
+	}
+
+	// Test recorders have no connection to extend.
+	rc := http.NewResponseController(w)
+	_ = rc.SetReadDeadline(deadline)
+	_ = rc.SetWriteDeadline(deadline)
+}
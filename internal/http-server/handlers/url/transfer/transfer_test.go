@@ -0,0 +1,277 @@
+package transfer_test
+
+import (
+	"URL-shortener/internal/http-server/handlers/url/transfer"
+	"URL-shortener/internal/http-server/handlers/url/transfer/mocks"
+	"URL-shortener/internal/http-server/middleware/auth"
+	"URL-shortener/internal/lib/aliaspolicy"
+	"URL-shortener/internal/lib/apikey"
+	"URL-shortener/internal/lib/linkfile"
+	"URL-shortener/internal/lib/logger/handlers/slogdiscard"
+	"URL-shortener/internal/lib/random"
+	"URL-shortener/internal/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newAliasPolicy(t *testing.T) *aliaspolicy.Policy {
+	t.Helper()
+
+	policy, err := aliaspolicy.New(aliaspolicy.Options{
+		Length:             6,
+		MaxGeneratedLength: 16,
+		Alphabet:           random.Alphabet,
+		MinLength:          3,
+		MaxLength:          64,
+		Pattern:            "^[A-Za-z0-9_-]+$",
+		CaseSensitive:      true,
+		Reserved:           []string{"url"},
+	})
+	require.NoError(t, err)
+
+	return policy
+}
+
+func TestExportHandler(t *testing.T) {
+	cases := []struct {
+		name         string
+		query        string
+		mockError    error
+		expectedCode int
+		expectedType string
+		expectedBody string
+	}{
+		{
+			name:         "CSV",
+			query:        "?format=csv",
+			expectedCode: http.StatusOK,
+			expectedType: "text/csv; charset=utf-8",
+			expectedBody: "alias,url,expires_at,tags,creator,created_at\ngoogle,https://google.com,,promo,key:7,\n",
+		},
+		{
+			name:         "JSON Lines by default",
+			expectedCode: http.StatusOK,
+			expectedType: "application/x-ndjson",
+			expectedBody: `{"alias":"google","url":"https://google.com","tags":["promo"],"creator":"key:7"}` + "\n",
+		},
+		{
+			name:         "Invalid format",
+			query:        "?format=xml",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "Parameter format must be csv or jsonl",
+		},
+		{
+			name:         "Internal error",
+			mockError:    errors.New("database error"),
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: "Failed to export URLs",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			exporterMock := mocks.NewExporter(t)
+			switch {
+			case tc.mockError != nil:
+				exporterMock.On("ListURLs", mock.Anything, mock.Anything).Return(nil, tc.mockError).Once()
+			case tc.expectedCode == http.StatusOK:
+				exporterMock.On("ListURLs", mock.Anything, mock.MatchedBy(func(opts storage.ListOptions) bool {
+					return opts.Ascending && opts.Owner == "key:7"
+				})).Return([]storage.Link{{ID: 1, Alias: "google", URL: "https://google.com", Owner: "key:7", Tags: []string{"promo"}}}, nil).Once()
+			}
+
+			handler := transfer.Export(slogdiscard.NewDiscardLogger(), exporterMock)
+
+			req, err := http.NewRequest(http.MethodGet, "/url/export"+tc.query, nil)
+			require.NoError(t, err)
+			req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{KeyID: 7, Scopes: []string{apikey.ScopeReadStats}}))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedCode, rr.Code)
+			if tc.expectedType != "" {
+				require.Equal(t, tc.expectedType, rr.Header().Get("Content-Type"))
+				require.Equal(t, tc.expectedBody, rr.Body.String())
+			} else {
+				require.Contains(t, rr.Body.String(), tc.expectedBody)
+			}
+		})
+	}
+}
+
+func TestExportHandler_Interrupted(t *testing.T) {
+	exporterMock := mocks.NewExporter(t)
+
+	page := make([]storage.Link, 500)
+	for i := range page {
+		page[i] = storage.Link{ID: int64(i + 1), Alias: "google", URL: "https://google.com"}
+	}
+	exporterMock.On("ListURLs", mock.Anything, mock.Anything).Return(page, nil).Once()
+	exporterMock.On("ListURLs", mock.Anything, mock.Anything).Return(nil, context.DeadlineExceeded).Once()
+
+	handler := transfer.Export(slogdiscard.NewDiscardLogger(), exporterMock)
+
+	req, err := http.NewRequest(http.MethodGet, "/url/export", nil)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	require.PanicsWithValue(t, http.ErrAbortHandler, func() { handler.ServeHTTP(rr, req) })
+	require.Equal(t, http.StatusOK, rr.Code)
+}
+
+func TestImportHandler(t *testing.T) {
+	cases := []struct {
+		name         string
+		query        string
+		contentType  string
+		body         string
+		scopes       []string
+		setupMock    func(*mocks.Importer)
+		expectedCode int
+		expectedErr  string
+		expected     linkfile.Summary
+	}{
+		{
+			name: "JSON Lines",
+			body: `{"url": "https://google.com", "alias": "google"}` + "\n" + `{"url": "not a url"}` + "\n",
+			setupMock: func(m *mocks.Importer) {
+				m.On("SaveURLs", mock.Anything, mock.MatchedBy(func(items []storage.BatchItem) bool {
+					return len(items) == 1 && items[0].Alias == "google" && items[0].Opts.Owner == "key:7"
+				}), false).Return([]storage.BatchResult{{ID: 1}}, nil).Once()
+			},
+			expectedCode: http.StatusOK,
+			expected:     linkfile.Summary{Rows: 2, Created: 1, Failed: 1},
+		},
+		{
+			name:        "CSV by content type",
+			contentType: "text/csv",
+			body:        "url,alias\nhttps://google.com,taken\n",
+			setupMock: func(m *mocks.Importer) {
+				m.On("SaveURLs", mock.Anything, mock.Anything, false).Return([]storage.BatchResult{{Err: storage.ErrURLExists}}, nil).Once()
+			},
+			expectedCode: http.StatusOK,
+			expected:     linkfile.Summary{Rows: 1, Skipped: 1},
+		},
+		{
+			name:  "Dry run",
+			query: "?dry_run=true",
+			body:  `{"url": "https://google.com", "alias": "google"}`,
+			setupMock: func(m *mocks.Importer) {
+				m.On("GetLink", mock.Anything, "google", "key:7").Return(storage.Link{}, storage.ErrURLNotFound).Once()
+			},
+			expectedCode: http.StatusOK,
+			expected:     linkfile.Summary{DryRun: true, Rows: 1, Created: 1},
+		},
+		{
+			name:         "Overwrite without update scope",
+			query:        "?on_conflict=overwrite",
+			body:         `{"url": "https://google.com"}`,
+			expectedCode: http.StatusForbidden,
+			expectedErr:  "Missing scope update",
+		},
+		{
+			name:   "Overwrite",
+			query:  "?on_conflict=overwrite",
+			body:   `{"url": "https://google.com", "alias": "taken", "tags": ["promo"]}`,
+			scopes: []string{apikey.ScopeCreate, apikey.ScopeUpdate},
+			setupMock: func(m *mocks.Importer) {
+				m.On("SaveURLs", mock.Anything, mock.Anything, false).Return([]storage.BatchResult{{Err: storage.ErrURLExists}}, nil).Once()
+				m.On("UpdateURL", mock.Anything, "taken", storage.URLUpdate{
+					URL:       "https://google.com",
+					Owner:     "key:7",
+					ChangedBy: "key:7",
+					SetMeta:   true,
+					Tags:      []string{"promo"},
+				}).Return(int64(2), nil).Once()
+			},
+			expectedCode: http.StatusOK,
+			expected:     linkfile.Summary{Rows: 1, Updated: 1},
+		},
+		{
+			name:         "Unknown conflict strategy",
+			query:        "?on_conflict=merge",
+			body:         `{"url": "https://google.com"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "Parameter on_conflict must be skip, overwrite or rename",
+		},
+		{
+			name:         "Invalid dry run",
+			query:        "?dry_run=maybe",
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "Parameter dry_run must be true or false",
+		},
+		{
+			name:         "CSV without url column",
+			query:        "?format=csv",
+			body:         "alias\ngoogle\n",
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "header has no url column",
+		},
+		{
+			name: "Internal error",
+			body: `{"url": "https://google.com"}`,
+			setupMock: func(m *mocks.Importer) {
+				m.On("SaveURLs", mock.Anything, mock.Anything, false).Return(nil, errors.New("database error")).Once()
+			},
+			expectedCode: http.StatusInternalServerError,
+			expectedErr:  "Import stopped, failed to save URLs",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			importerMock := mocks.NewImporter(t)
+			if tc.setupMock != nil {
+				tc.setupMock(importerMock)
+			}
+
+			handler := transfer.Import(slogdiscard.NewDiscardLogger(), importerMock, newAliasPolicy(t))
+
+			req, err := http.NewRequest(http.MethodPost, "/url/import"+tc.query, strings.NewReader(tc.body))
+			require.NoError(t, err)
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			scopes := tc.scopes
+			if scopes == nil {
+				scopes = []string{apikey.ScopeCreate}
+			}
+			req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{KeyID: 7, Scopes: scopes}))
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedCode, rr.Code)
+
+			var resp transfer.ImportResponse
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+
+			if tc.expectedErr != "" {
+				require.Contains(t, resp.Error, tc.expectedErr)
+				return
+			}
+
+			require.Equal(t, "OK", resp.Status)
+			require.Len(t, resp.Errors, tc.expected.Failed)
+			resp.Errors = nil
+			require.Equal(t, tc.expected, resp.Summary)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package mocks
+
+import (
+	storage "URL-shortener/internal/storage"
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+type URLUpdater struct {
+	mock.Mock
+}
+
+func (_m *URLUpdater) UpdateURL(ctx context.Context, alias string, upd storage.URLUpdate) (int64, error) {
+	ret := _m.Called(ctx, alias, upd)
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, storage.URLUpdate) (int64, error)); ok {
+		r0, r1 = rf(ctx, alias, upd)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(int64)
+		}
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+type mockConstructorTestingTNewURLUpdater interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+func NewURLUpdater(t mockConstructorTestingTNewURLUpdater) *URLUpdater {
+	mock := &URLUpdater{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
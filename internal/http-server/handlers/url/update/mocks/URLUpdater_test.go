@@ -0,0 +1,63 @@
+package mocks
+
+import (
+	"URL-shortener/internal/storage"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestURLUpdater_UpdateURL(t *testing.T) {
+	upd := storage.URLUpdate{URL: "https://example.com", Version: 1}
+
+	tests := []struct {
+		name            string
+		alias           string
+		setupMock       func(*URLUpdater)
+		expectedVersion int64
+		expectedErr     error
+	}{
+		{
+			name:  "successful update",
+			alias: "test123",
+			setupMock: func(m *URLUpdater) {
+				m.On("UpdateURL", mock.Anything, "test123", upd).Return(int64(2), nil)
+			},
+			expectedVersion: 2,
+		},
+		{
+			name:  "version mismatch",
+			alias: "test456",
+			setupMock: func(m *URLUpdater) {
+				m.On("UpdateURL", mock.Anything, "test456", upd).Return(int64(0), errors.New("version mismatch"))
+			},
+			expectedErr: errors.New("version mismatch"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockURLUpdater := NewURLUpdater(t)
+			tt.setupMock(mockURLUpdater)
+
+			version, err := mockURLUpdater.UpdateURL(context.Background(), tt.alias, upd)
+
+			assert.Equal(t, tt.expectedVersion, version)
+			if tt.expectedErr != nil {
+				assert.EqualError(t, err, tt.expectedErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockURLUpdater.AssertExpectations(t)
+		})
+	}
+}
+
+func TestNewURLUpdater(t *testing.T) {
+	mock := NewURLUpdater(t)
+	assert.NotNil(t, mock)
+}
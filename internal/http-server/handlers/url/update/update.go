@@ -0,0 +1,157 @@
+package update
+
+import (
+	"URL-shortener/internal/http-server/middleware/auth"
+	"URL-shortener/internal/lib/api/etag"
+	"URL-shortener/internal/lib/api/response"
+	"URL-shortener/internal/lib/logger/sl"
+	"URL-shortener/internal/lib/telemetry"
+	"URL-shortener/internal/storage"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
+)
+
+type Request struct {
+	URL string `json:"url" validate:"required,url"`
+}
+
+type Response struct {
+	response.Response
+	Alias   string `json:"alias"`
+	URL     string `json:"url"`
+	Version int64  `json:"version"`
+}
+
+var validate = response.NewValidator()
+
+//go:generate go run github.com/vektra/mockery/v2@v2.43.0 --name URLUpdater
+type URLUpdater interface {
+	UpdateURL(ctx context.Context, alias string, upd storage.URLUpdate) (int64, error)
+}
+
+// AliasNormalizer maps an alias to the form it is stored in.
+type AliasNormalizer interface {
+	Normalize(alias string) string
+}
+
+// New changes the destination of a link. An If-Match header with the
+// ETag of a previous response makes the change conditional: if the link
+// was changed in between, the request fails with 412.
+func New(log *slog.Logger, urlUpdater URLUpdater, aliasNormalizer AliasNormalizer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		const op = "handlers.url.update.New"
+
+		log := log.With(
+			slog.String("operation", op),
+			slog.String("request_id", middleware.GetReqID(r.Context())),
+			slog.String("trace_id", telemetry.TraceID(r.Context())),
+		)
+
+		alias := chi.URLParam(r, "alias")
+		if alias == "" {
+			log.Info("Alias is empty")
+
+			response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest, "Invalid request"))
+
+			return
+		}
+
+		alias = aliasNormalizer.Normalize(alias)
+
+		version, err := etag.ParseIfMatch(r.Header.Get("If-Match"))
+		if err != nil {
+			log.Info("Invalid If-Match header", slog.String("if_match", r.Header.Get("If-Match")))
+
+			response.RenderError(w, r, http.StatusPreconditionFailed, response.Error(response.CodeVersionMismatch, "If-Match must be an ETag returned by the API"))
+
+			return
+		}
+
+		var req Request
+
+		err = render.DecodeJSON(r.Body, &req)
+		if err != nil {
+			log.Error("Failed to decode request body", sl.Err(err))
+
+			response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest, "Failed to decode request"))
+
+			return
+		}
+
+		if err := validate.Struct(req); err != nil {
+			validateErr, ok := err.(validator.ValidationErrors)
+			if !ok {
+				log.Error("Invalid request", sl.Err(err))
+				response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest, "Failed to validate request"))
+				return
+			}
+
+			log.Error("Invalid request", sl.Err(err))
+			response.RenderError(w, r, http.StatusUnprocessableEntity, response.ValidationError(validateErr))
+			return
+		}
+
+		upd := storage.URLUpdate{
+			URL:     req.URL,
+			Version: version,
+			Owner:   auth.OwnerFilter(r.Context()),
+		}
+		if principal, ok := auth.FromContext(r.Context()); ok {
+			upd.ChangedBy = principal.Subject()
+		}
+
+		version, err = urlUpdater.UpdateURL(r.Context(), alias, upd)
+		if errors.Is(err, storage.ErrURLNotFound) {
+			log.Info("URL not found", slog.String("alias", alias))
+
+			response.RenderError(w, r, http.StatusNotFound, response.Error(response.CodeNotFound, "URL not found"))
+
+			return
+		}
+		if errors.Is(err, storage.ErrNotOwner) {
+			log.Info("URL belongs to another owner", slog.String("alias", alias))
+
+			response.RenderError(w, r, http.StatusForbidden, response.Error(response.CodeForbidden, "URL belongs to another owner"))
+
+			return
+		}
+		if errors.Is(err, storage.ErrVersionMismatch) {
+			log.Info("URL version mismatch", slog.String("alias", alias), slog.Int64("version", upd.Version))
+
+			response.RenderError(w, r, http.StatusPreconditionFailed, response.Error(response.CodeVersionMismatch, "URL was changed, fetch the current version and retry"))
+
+			return
+		}
+		if status, resp, ok := response.ContextError(err); ok {
+			log.Warn("Updating URL interrupted", sl.Err(err))
+
+			response.RenderError(w, r, status, resp)
+
+			return
+		}
+		if err != nil {
+			log.Error("Failed to update URL", sl.Err(err))
+
+			response.RenderError(w, r, http.StatusInternalServerError, response.Error(response.CodeInternal, "Failed to update URL"))
+
+			return
+		}
+
+		log.Info("URL updated", slog.String("alias", alias), slog.Int64("version", version))
+
+		w.Header().Set("ETag", etag.Format(version))
+		render.JSON(w, r, Response{
+			Response: response.OK(),
+			Alias:    alias,
+			URL:      req.URL,
+			Version:  version,
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package update_test
+
+import (
+	"URL-shortener/internal/http-server/handlers/url/update"
+	"URL-shortener/internal/http-server/handlers/url/update/mocks"
+	"URL-shortener/internal/http-server/middleware/auth"
+	"URL-shortener/internal/lib/aliaspolicy"
+	"URL-shortener/internal/lib/api/etag"
+	"URL-shortener/internal/lib/apikey"
+	"URL-shortener/internal/lib/logger/handlers/slogdiscard"
+	"URL-shortener/internal/lib/random"
+	"URL-shortener/internal/storage"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateHandler(t *testing.T) {
+	cases := []struct {
+		name         string
+		body         string
+		ifMatch      string
+		principal    *auth.Principal
+		expectedUpd  *storage.URLUpdate
+		mockVersion  int64
+		mockError    error
+		expectedCode int
+		expectedErr  string
+	}{
+		{
+			name:         "Success",
+			body:         `{"url": "https://example.com"}`,
+			expectedUpd:  &storage.URLUpdate{URL: "https://example.com"},
+			mockVersion:  2,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:      "Owner with If-Match",
+			body:      `{"url": "https://example.com"}`,
+			ifMatch:   `"2"`,
+			principal: &auth.Principal{KeyID: 7, Scopes: []string{apikey.ScopeUpdate}},
+			expectedUpd: &storage.URLUpdate{
+				URL: "https://example.com", Version: 2, Owner: "key:7", ChangedBy: "key:7",
+			},
+			mockVersion:  3,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Admin",
+			body:         `{"url": "https://example.com"}`,
+			principal:    &auth.Principal{KeyID: 1, Scopes: []string{apikey.ScopeAdmin}},
+			expectedUpd:  &storage.URLUpdate{URL: "https://example.com", ChangedBy: "key:1"},
+			mockVersion:  2,
+			expectedCode: http.StatusOK,
+		},
+		{
+			name:         "Stale version",
+			body:         `{"url": "https://example.com"}`,
+			ifMatch:      `"1"`,
+			expectedUpd:  &storage.URLUpdate{URL: "https://example.com", Version: 1},
+			mockError:    storage.ErrVersionMismatch,
+			expectedCode: http.StatusPreconditionFailed,
+			expectedErr:  "URL was changed",
+		},
+		{
+			name:         "Malformed If-Match",
+			body:         `{"url": "https://example.com"}`,
+			ifMatch:      `W/"1"`,
+			expectedCode: http.StatusPreconditionFailed,
+			expectedErr:  "If-Match must be an ETag",
+		},
+		{
+			name:         "Another owner",
+			body:         `{"url": "https://example.com"}`,
+			principal:    &auth.Principal{KeyID: 8, Scopes: []string{apikey.ScopeUpdate}},
+			expectedUpd:  &storage.URLUpdate{URL: "https://example.com", Owner: "key:8", ChangedBy: "key:8"},
+			mockError:    storage.ErrNotOwner,
+			expectedCode: http.StatusForbidden,
+			expectedErr:  "URL belongs to another owner",
+		},
+		{
+			name:         "URL not found",
+			body:         `{"url": "https://example.com"}`,
+			expectedUpd:  &storage.URLUpdate{URL: "https://example.com"},
+			mockError:    storage.ErrURLNotFound,
+			expectedCode: http.StatusNotFound,
+			expectedErr:  "URL not found",
+		},
+		{
+			name:         "Invalid URL",
+			body:         `{"url": "not a url"}`,
+			expectedCode: http.StatusUnprocessableEntity,
+			expectedErr:  "Field URL is not a valid URL",
+		},
+		{
+			name:         "Invalid body",
+			body:         `{`,
+			expectedCode: http.StatusBadRequest,
+			expectedErr:  "Failed to decode request",
+		},
+		{
+			name:         "Request canceled",
+			body:         `{"url": "https://example.com"}`,
+			expectedUpd:  &storage.URLUpdate{URL: "https://example.com"},
+			mockError:    context.Canceled,
+			expectedCode: http.StatusServiceUnavailable,
+			expectedErr:  "Request canceled",
+		},
+		{
+			name:         "Internal error",
+			body:         `{"url": "https://example.com"}`,
+			expectedUpd:  &storage.URLUpdate{URL: "https://example.com"},
+			mockError:    errors.New("database error"),
+			expectedCode: http.StatusInternalServerError,
+			expectedErr:  "Failed to update URL",
+		},
+	}
+
+	aliasPolicy, err := aliaspolicy.New(aliaspolicy.Options{
+		Length:        6,
+		Alphabet:      random.Alphabet,
+		Pattern:       ".*",
+		CaseSensitive: true,
+	})
+	require.NoError(t, err)
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			urlUpdaterMock := mocks.NewURLUpdater(t)
+			if tc.expectedUpd != nil {
+				urlUpdaterMock.On("UpdateURL", mock.Anything, "test_alias", *tc.expectedUpd).
+					Return(tc.mockVersion, tc.mockError).Once()
+			}
+
+			handler := update.New(slogdiscard.NewDiscardLogger(), urlUpdaterMock, aliasPolicy)
+
+			r := chi.NewRouter()
+			r.Patch("/url/{alias}", handler)
+
+			req, err := http.NewRequest(http.MethodPatch, "/url/test_alias", bytes.NewReader([]byte(tc.body)))
+			require.NoError(t, err)
+			if tc.ifMatch != "" {
+				req.Header.Set("If-Match", tc.ifMatch)
+			}
+			if tc.principal != nil {
+				req = req.WithContext(auth.WithPrincipal(req.Context(), *tc.principal))
+			}
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedCode, rr.Code)
+
+			var resp update.Response
+			require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+
+			if tc.expectedErr != "" {
+				require.Contains(t, resp.Error, tc.expectedErr)
+				return
+			}
+
+			require.Equal(t, "OK", resp.Status)
+			require.Equal(t, tc.mockVersion, resp.Version)
+			require.Equal(t, "https://example.com", resp.URL)
+			require.Equal(t, etag.Format(tc.mockVersion), rr.Header().Get("ETag"))
+		})
+	}
+}
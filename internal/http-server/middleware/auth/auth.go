@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"URL-shortener/internal/lib/api/response"
+	"URL-shortener/internal/lib/apikey"
+	"URL-shortener/internal/lib/logger/sl"
+	"URL-shortener/internal/storage"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Modes select which credentials are accepted.
+const (
+	ModeAPIKey = "api_key"
+	ModeBasic  = "basic"
+	// ModeBoth accepts API keys and falls back to BasicAuth.
+	ModeBoth = "both"
+)
+
+// touchInterval throttles last-used updates, so a busy key
+// doesn't cost a write on every request.
+const touchInterval = time.Minute
+
+//go:generate go run github.com/vektra/mockery/v2@v2.43.0 --name KeyGetter
+type KeyGetter interface {
+	GetAPIKey(ctx context.Context, hash string) (storage.APIKey, error)
+	TouchAPIKey(ctx context.Context, id int64, usedAt time.Time) error
+}
+
+// Principal is who made the request.
+type Principal struct {
+	// KeyID is zero for BasicAuth credentials.
+	KeyID  int64
+	Name   string
+	Scopes []string
+}
+
+// Subject identifies the principal as the owner of the links it creates.
+// Keys are identified by ID, so renaming or reusing a name doesn't
+// hand links over.
+func (p Principal) Subject() string {
+	if p.KeyID != 0 {
+		return "key:" + strconv.FormatInt(p.KeyID, 10)
+	}
+	return "basic:" + p.Name
+}
+
+type Options struct {
+	Mode  string
+	Realm string
+	// Credentials are the user and password pairs accepted by BasicAuth.
+	// They grant every scope, like the single shared credential used to.
+	Credentials map[string]string
+}
+
+type ctxKey struct{}
+
+// FromContext returns the principal the request was authenticated as.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(ctxKey{}).(Principal)
+	return p, ok
+}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, ctxKey{}, p)
+}
+
+// OwnerFilter returns the owner the request is limited to. It is empty
+// for admins, who may manage every link, and for unauthenticated routes.
+func OwnerFilter(ctx context.Context) string {
+	p, ok := FromContext(ctx)
+	if !ok || apikey.HasScope(p.Scopes, apikey.ScopeAdmin) {
+		return ""
+	}
+	return p.Subject()
+}
+
+// New authenticates requests by an "Authorization: Bearer" API key or,
+// depending on the mode, by BasicAuth. Requests without valid credentials
+// get 401, authorized ones carry a Principal in their context.
+func New(log *slog.Logger, keys KeyGetter, opts Options) func(next http.Handler) http.Handler {
+	const op = "middleware.auth.New"
+
+	log = log.With(slog.String("operation", op))
+
+	acceptKeys := opts.Mode == ModeAPIKey || opts.Mode == ModeBoth
+	acceptBasic := opts.Mode == ModeBasic || opts.Mode == ModeBoth
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			log := log.With(slog.String("request_id", middleware.GetReqID(r.Context())))
+
+			var (
+				principal Principal
+				err       error
+			)
+
+			token, isBearer := bearerToken(r)
+			user, password, isBasic := r.BasicAuth()
+
+			switch {
+			case isBearer && acceptKeys:
+				principal, err = authenticateKey(r.Context(), log, keys, token)
+			case isBasic && acceptBasic:
+				principal, err = authenticateBasic(opts.Credentials, user, password)
+			default:
+				err = errNoCredentials
+			}
+
+			if status, resp, ok := response.ContextError(err); ok {
+				log.Warn("Authentication interrupted", sl.Err(err))
+				response.RenderError(w, r, status, resp)
+				return
+			}
+			if errors.Is(err, errNoCredentials) || errors.Is(err, errInvalidCredentials) {
+				log.Info("Unauthorized", sl.Err(err))
+				unauthorized(w, r, opts, acceptKeys, acceptBasic, err)
+				return
+			}
+			if err != nil {
+				log.Error("Failed to authenticate", sl.Err(err))
+				response.RenderError(w, r, http.StatusInternalServerError, response.Error(response.CodeInternal, "Internal error"))
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), principal)))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RequireScope answers 403 to principals without scope.
+// It must run after New.
+func RequireScope(scope string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := FromContext(r.Context())
+			if !ok || !apikey.HasScope(principal.Scopes, scope) {
+				response.RenderError(w, r, http.StatusForbidden, response.Error(response.CodeForbidden, "Missing scope "+scope))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+var (
+	errNoCredentials      = errors.New("no credentials")
+	errInvalidCredentials = errors.New("invalid credentials")
+)
+
+func authenticateKey(ctx context.Context, log *slog.Logger, keys KeyGetter, token string) (Principal, error) {
+	key, err := keys.GetAPIKey(ctx, apikey.Hash(token))
+	if errors.Is(err, storage.ErrAPIKeyNotFound) {
+		return Principal{}, errInvalidCredentials
+	}
+	if err != nil {
+		return Principal{}, err
+	}
+
+	if now := time.Now(); now.Sub(key.LastUsedAt) >= touchInterval {
+		// A failed update only makes last-used less accurate.
+		if err := keys.TouchAPIKey(ctx, key.ID, now); err != nil {
+			log.Warn("Failed to update API key last use", slog.Int64("key_id", key.ID), sl.Err(err))
+		}
+	}
+
+	return Principal{KeyID: key.ID, Name: key.Name, Scopes: key.Scopes}, nil
+}
+
+func authenticateBasic(credentials map[string]string, user, password string) (Principal, error) {
+	expected, ok := credentials[user]
+	if !ok || subtle.ConstantTimeCompare([]byte(password), []byte(expected)) != 1 {
+		return Principal{}, errInvalidCredentials
+	}
+
+	return Principal{Name: user, Scopes: []string{apikey.ScopeAdmin}}, nil
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+
+	return token, token != ""
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, opts Options, acceptKeys, acceptBasic bool, err error) {
+	if acceptKeys {
+		w.Header().Add("WWW-Authenticate", `Bearer realm="`+opts.Realm+`"`)
+	}
+	if acceptBasic {
+		w.Header().Add("WWW-Authenticate", `Basic realm="`+opts.Realm+`"`)
+	}
+
+	msg := "Missing credentials"
+	if errors.Is(err, errInvalidCredentials) {
+		msg = "Invalid credentials"
+	}
+
+	response.RenderError(w, r, http.StatusUnauthorized, response.Error(response.CodeUnauthorized, msg))
+}
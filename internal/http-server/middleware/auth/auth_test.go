@@ -0,0 +1,259 @@
+package auth_test
+
+import (
+	"URL-shortener/internal/http-server/middleware/auth"
+	"URL-shortener/internal/http-server/middleware/auth/mocks"
+	"URL-shortener/internal/lib/apikey"
+	"URL-shortener/internal/lib/logger/handlers/slogdiscard"
+	"URL-shortener/internal/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testKey = "usk_test"
+
+func TestAuth(t *testing.T) {
+	activeKey := storage.APIKey{ID: 7, Name: "ci", Scopes: []string{apikey.ScopeCreate}}
+	recentKey := activeKey
+	recentKey.LastUsedAt = time.Now()
+
+	cases := []struct {
+		name          string
+		mode          string
+		setupRequest  func(*http.Request)
+		setupMock     func(*mocks.KeyGetter)
+		expectedCode  int
+		expectedName  string
+		expectedError string
+		challenges    []string
+	}{
+		{
+			name:          "No credentials",
+			mode:          auth.ModeBoth,
+			setupRequest:  func(*http.Request) {},
+			expectedCode:  http.StatusUnauthorized,
+			expectedError: "Missing credentials",
+			challenges:    []string{`Bearer realm="test"`, `Basic realm="test"`},
+		},
+		{
+			name: "Valid API key",
+			mode: auth.ModeAPIKey,
+			setupRequest: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+testKey)
+			},
+			setupMock: func(m *mocks.KeyGetter) {
+				m.On("GetAPIKey", mock.Anything, apikey.Hash(testKey)).Return(activeKey, nil).Once()
+				m.On("TouchAPIKey", mock.Anything, int64(7), mock.Anything).Return(nil).Once()
+			},
+			expectedCode: http.StatusOK,
+			expectedName: "ci",
+		},
+		{
+			name: "Recently used key is not touched",
+			mode: auth.ModeAPIKey,
+			setupRequest: func(r *http.Request) {
+				r.Header.Set("Authorization", "bearer "+testKey)
+			},
+			setupMock: func(m *mocks.KeyGetter) {
+				m.On("GetAPIKey", mock.Anything, apikey.Hash(testKey)).Return(recentKey, nil).Once()
+			},
+			expectedCode: http.StatusOK,
+			expectedName: "ci",
+		},
+		{
+			name: "Failed touch doesn't fail the request",
+			mode: auth.ModeAPIKey,
+			setupRequest: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+testKey)
+			},
+			setupMock: func(m *mocks.KeyGetter) {
+				m.On("GetAPIKey", mock.Anything, apikey.Hash(testKey)).Return(activeKey, nil).Once()
+				m.On("TouchAPIKey", mock.Anything, int64(7), mock.Anything).Return(errors.New("database error")).Once()
+			},
+			expectedCode: http.StatusOK,
+			expectedName: "ci",
+		},
+		{
+			name: "Unknown or revoked key",
+			mode: auth.ModeBoth,
+			setupRequest: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+testKey)
+			},
+			setupMock: func(m *mocks.KeyGetter) {
+				m.On("GetAPIKey", mock.Anything, apikey.Hash(testKey)).Return(storage.APIKey{}, storage.ErrAPIKeyNotFound).Once()
+			},
+			expectedCode:  http.StatusUnauthorized,
+			expectedError: "Invalid credentials",
+			challenges:    []string{`Bearer realm="test"`, `Basic realm="test"`},
+		},
+		{
+			name: "Storage error",
+			mode: auth.ModeAPIKey,
+			setupRequest: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+testKey)
+			},
+			setupMock: func(m *mocks.KeyGetter) {
+				m.On("GetAPIKey", mock.Anything, apikey.Hash(testKey)).Return(storage.APIKey{}, errors.New("database error")).Once()
+			},
+			expectedCode:  http.StatusInternalServerError,
+			expectedError: "Internal error",
+		},
+		{
+			name: "Storage timeout",
+			mode: auth.ModeAPIKey,
+			setupRequest: func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer "+testKey)
+			},
+			setupMock: func(m *mocks.KeyGetter) {
+				m.On("GetAPIKey", mock.Anything, apikey.Hash(testKey)).Return(storage.APIKey{}, context.DeadlineExceeded).Once()
+			},
+			expectedCode:  http.StatusGatewayTimeout,
+			expectedError: "Request timed out",
+		},
+		{
+			name: "BasicAuth fallback",
+			mode: auth.ModeBoth,
+			setupRequest: func(r *http.Request) {
+				r.SetBasicAuth("admin", "secret")
+			},
+			expectedCode: http.StatusOK,
+			expectedName: "admin",
+		},
+		{
+			name: "Wrong BasicAuth password",
+			mode: auth.ModeBasic,
+			setupRequest: func(r *http.Request) {
+				r.SetBasicAuth("admin", "wrong")
+			},
+			expectedCode:  http.StatusUnauthorized,
+			expectedError: "Invalid credentials",
+			challenges:    []string{`Basic realm="test"`},
+		},
+		{
+			name: "BasicAuth disabled",
+			mode: auth.ModeAPIKey,
+			setupRequest: func(r *http.Request) {
+				r.SetBasicAuth("admin", "secret")
+			},
+			expectedCode:  http.StatusUnauthorized,
+			expectedError: "Missing credentials",
+			challenges:    []string{`Bearer realm="test"`},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			keyGetterMock := mocks.NewKeyGetter(t)
+			if tc.setupMock != nil {
+				tc.setupMock(keyGetterMock)
+			}
+
+			var principal auth.Principal
+
+			r := chi.NewRouter()
+			r.Use(auth.New(slogdiscard.NewDiscardLogger(), keyGetterMock, auth.Options{
+				Mode:        tc.mode,
+				Realm:       "test",
+				Credentials: map[string]string{"admin": "secret"},
+			}))
+			r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+				principal, _ = auth.FromContext(r.Context())
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			tc.setupRequest(req)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedCode, rr.Code)
+			require.Equal(t, tc.expectedName, principal.Name)
+			require.Equal(t, tc.challenges, rr.Header().Values("WWW-Authenticate"))
+
+			if tc.expectedError != "" {
+				var resp struct {
+					Error string `json:"error"`
+				}
+				require.NoError(t, json.NewDecoder(rr.Body).Decode(&resp))
+				require.Equal(t, tc.expectedError, resp.Error)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	cases := []struct {
+		name         string
+		scopes       []string
+		expectedCode int
+	}{
+		{name: "Granted", scopes: []string{apikey.ScopeCreate}, expectedCode: http.StatusOK},
+		{name: "Admin", scopes: []string{apikey.ScopeAdmin}, expectedCode: http.StatusOK},
+		{name: "Missing", scopes: []string{apikey.ScopeReadStats}, expectedCode: http.StatusForbidden},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			keyGetterMock := mocks.NewKeyGetter(t)
+			keyGetterMock.On("GetAPIKey", mock.Anything, mock.Anything).
+				Return(storage.APIKey{ID: 1, Scopes: tc.scopes, LastUsedAt: time.Now()}, nil).Once()
+
+			r := chi.NewRouter()
+			r.Use(auth.New(slogdiscard.NewDiscardLogger(), keyGetterMock, auth.Options{Mode: auth.ModeAPIKey}))
+			r.With(auth.RequireScope(apikey.ScopeCreate)).Post("/", func(w http.ResponseWriter, r *http.Request) {})
+
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+testKey)
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			require.Equal(t, tc.expectedCode, rr.Code)
+		})
+	}
+}
+
+func TestOwnerFilter(t *testing.T) {
+	cases := []struct {
+		name      string
+		principal *auth.Principal
+		expected  string
+	}{
+		{name: "API key", principal: &auth.Principal{KeyID: 7, Scopes: []string{apikey.ScopeDelete}}, expected: "key:7"},
+		{name: "BasicAuth user", principal: &auth.Principal{Name: "ops", Scopes: []string{apikey.ScopeDelete}}, expected: "basic:ops"},
+		{name: "Admin", principal: &auth.Principal{KeyID: 1, Scopes: []string{apikey.ScopeAdmin}}, expected: ""},
+		{name: "Unauthenticated", expected: ""},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			if tc.principal != nil {
+				ctx = auth.WithPrincipal(ctx, *tc.principal)
+			}
+
+			require.Equal(t, tc.expected, auth.OwnerFilter(ctx))
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package mocks
+
+import (
+	storage "URL-shortener/internal/storage"
+	context "context"
+	time "time"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+type KeyGetter struct {
+	mock.Mock
+}
+
+func (_m *KeyGetter) GetAPIKey(ctx context.Context, hash string) (storage.APIKey, error) {
+	ret := _m.Called(ctx, hash)
+
+	var r0 storage.APIKey
+	var r1 error
+
+	if rf, ok := ret.Get(0).(func(context.Context, string) (storage.APIKey, error)); ok {
+		return rf(ctx, hash)
+	}
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.APIKey)
+	}
+
+	r1 = ret.Error(1)
+
+	return r0, r1
+}
+
+func (_m *KeyGetter) TouchAPIKey(ctx context.Context, id int64, usedAt time.Time) error {
+	ret := _m.Called(ctx, id, usedAt)
+
+	var r0 error
+
+	if rf, ok := ret.Get(0).(func(context.Context, int64, time.Time) error); ok {
+		return rf(ctx, id, usedAt)
+	}
+
+	r0 = ret.Error(0)
+
+	return r0
+}
+
+type mockConstructorTestingTNewKeyGetter interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+func NewKeyGetter(t mockConstructorTestingTNewKeyGetter) *KeyGetter {
+	mock := &KeyGetter{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
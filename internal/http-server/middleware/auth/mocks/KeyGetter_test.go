@@ -0,0 +1,69 @@
+package mocks
+
+import (
+	"URL-shortener/internal/storage"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestKeyGetter_GetAPIKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		hash        string
+		setupMock   func(*KeyGetter)
+		expectedKey storage.APIKey
+		expectedErr error
+	}{
+		{
+			name: "successful get",
+			hash: "abc",
+			setupMock: func(m *KeyGetter) {
+				m.On("GetAPIKey", mock.Anything, "abc").Return(storage.APIKey{ID: 1, Hash: "abc"}, nil)
+			},
+			expectedKey: storage.APIKey{ID: 1, Hash: "abc"},
+		},
+		{
+			name: "key not found",
+			hash: "missing",
+			setupMock: func(m *KeyGetter) {
+				m.On("GetAPIKey", mock.Anything, "missing").Return(storage.APIKey{}, errors.New("API key not found"))
+			},
+			expectedErr: errors.New("API key not found"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockKeyGetter := NewKeyGetter(t)
+			tt.setupMock(mockKeyGetter)
+
+			key, err := mockKeyGetter.GetAPIKey(context.Background(), tt.hash)
+
+			assert.Equal(t, tt.expectedKey, key)
+			if tt.expectedErr != nil {
+				assert.EqualError(t, err, tt.expectedErr.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockKeyGetter.AssertExpectations(t)
+		})
+	}
+}
+
+func TestKeyGetter_TouchAPIKey(t *testing.T) {
+	mockKeyGetter := NewKeyGetter(t)
+	mockKeyGetter.On("TouchAPIKey", mock.Anything, int64(1), mock.Anything).Return(nil).Once()
+
+	assert.NoError(t, mockKeyGetter.TouchAPIKey(context.Background(), 1, time.Now()))
+}
+
+func TestNewKeyGetter(t *testing.T) {
+	mock := NewKeyGetter(t)
+	assert.NotNil(t, mock)
+}
@@ -0,0 +1,24 @@
+package deadline
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// New puts a deadline on the request context, so work started by a handler
+// is cancelled once the server would stop waiting for the response anyway.
+// Unlike chi's middleware.Timeout it never writes a response itself,
+// handlers decide how to report the error.
+func New(timeout time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
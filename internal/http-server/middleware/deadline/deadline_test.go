@@ -0,0 +1,30 @@
+package deadline_test
+
+import (
+	"URL-shortener/internal/http-server/middleware/deadline"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadline(t *testing.T) {
+	var (
+		got time.Time
+		ok  bool
+	)
+
+	handler := deadline.New(time.Second)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok = r.Context().Deadline()
+	}))
+
+	start := time.Now()
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	require.True(t, ok)
+	require.WithinDuration(t, start.Add(time.Second), got, 100*time.Millisecond)
+	require.Equal(t, http.StatusOK, rr.Code)
+}
@@ -0,0 +1,214 @@
+package idempotency
+
+import (
+	"URL-shortener/internal/http-server/middleware/auth"
+	"URL-shortener/internal/lib/api/response"
+	"URL-shortener/internal/lib/logger/sl"
+	"URL-shortener/internal/storage"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Header carries the key a client picks for a request it may retry.
+const Header = "Idempotency-Key"
+
+// ReplayedHeader is set on responses replayed from an earlier request.
+const ReplayedHeader = "Idempotent-Replayed"
+
+const (
+	maxKeyLength = 255
+	// maxBodyBytes limits the request body that is read up front
+	// to fingerprint the request. It matches POST /url/batch.
+	maxBodyBytes = 4 << 20
+	// pendingTTL is how long a key stays reserved when the server dies
+	// before the first request gets its response.
+	pendingTTL = time.Minute
+)
+
+// replayedHeaders are the response headers stored with the body.
+var replayedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+//go:generate go run github.com/vektra/mockery/v2@v2.43.0 --name Store
+type Store interface {
+	ReserveIdempotencyKey(ctx context.Context, rec storage.IdempotencyRecord) (storage.IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, rec storage.IdempotencyRecord) error
+	ReleaseIdempotencyKey(ctx context.Context, owner string, key string) error
+}
+
+// New makes requests with an Idempotency-Key header safe to retry.
+// The first request with a key is served and its response is stored for
+// window, retries with the same key and body get that response back.
+// Reusing a key for a different request answers 422, retrying while the
+// first request is still in flight answers 409. Server errors are not
+// stored, so the request can be retried with the same key.
+// Keys belong to the principal, so New must run after auth.New.
+// A zero window disables the header.
+func New(log *slog.Logger, store Store, window time.Duration) func(next http.Handler) http.Handler {
+	const op = "middleware.idempotency.New"
+
+	log = log.With(slog.String("operation", op))
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(Header)
+			if key == "" || window <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			log := log.With(slog.String("request_id", middleware.GetReqID(r.Context())))
+
+			if !validKey(key) {
+				response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest,
+					"Header "+Header+" must be 1 to "+strconv.Itoa(maxKeyLength)+" printable ASCII characters"))
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+			if err != nil {
+				log.Info("Failed to read request body", sl.Err(err))
+
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					response.RenderError(w, r, http.StatusRequestEntityTooLarge, response.Error(response.CodeBadRequest, "Request body is larger than 4 MiB"))
+					return
+				}
+				response.RenderError(w, r, http.StatusBadRequest, response.Error(response.CodeBadRequest, "Failed to read request body"))
+
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			var owner string
+			if principal, ok := auth.FromContext(r.Context()); ok {
+				owner = principal.Subject()
+			}
+
+			rec := storage.IdempotencyRecord{
+				Owner:       owner,
+				Key:         key,
+				RequestHash: requestHash(r, body),
+				ExpiresAt:   time.Now().Add(pendingTTL),
+			}
+
+			existing, err := store.ReserveIdempotencyKey(r.Context(), rec)
+			if errors.Is(err, storage.ErrIdempotencyKeyExists) {
+				replay(w, r, log, existing, rec.RequestHash)
+				return
+			}
+			if status, resp, ok := response.ContextError(err); ok {
+				log.Warn("Reserving idempotency key interrupted", sl.Err(err))
+				response.RenderError(w, r, status, resp)
+				return
+			}
+			if err != nil {
+				log.Error("Failed to reserve idempotency key", sl.Err(err))
+				response.RenderError(w, r, http.StatusInternalServerError, response.Error(response.CodeInternal, "Internal error"))
+				return
+			}
+
+			// The request may be out of time by now, the outcome
+			// must be stored all the same.
+			ctx := context.WithoutCancel(r.Context())
+
+			completed := false
+			defer func() {
+				if completed {
+					return
+				}
+				// Without a response worth keeping, let the client retry.
+				if err := store.ReleaseIdempotencyKey(ctx, owner, key); err != nil {
+					log.Error("Failed to release idempotency key", sl.Err(err))
+				}
+			}()
+
+			var buf bytes.Buffer
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			ww.Tee(&buf)
+
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status >= http.StatusInternalServerError {
+				return
+			}
+
+			rec.Status = status
+			rec.Headers = make(map[string]string, len(replayedHeaders))
+			for _, name := range replayedHeaders {
+				if value := ww.Header().Get(name); value != "" {
+					rec.Headers[name] = value
+				}
+			}
+			rec.Body = buf.Bytes()
+			rec.ExpiresAt = time.Now().Add(window)
+
+			completed = true
+			if err := store.CompleteIdempotencyKey(ctx, rec); err != nil {
+				// The response is sent already, a retry will run
+				// the request again once the reservation expires.
+				log.Error("Failed to store idempotent response", sl.Err(err))
+			}
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// replay answers a request whose key is taken with the stored response.
+func replay(w http.ResponseWriter, r *http.Request, log *slog.Logger, rec storage.IdempotencyRecord, hash string) {
+	switch {
+	case rec.RequestHash != hash:
+		log.Info("Idempotency key reused for a different request")
+		response.RenderError(w, r, http.StatusUnprocessableEntity, response.Error(response.CodeKeyReused,
+			"Header "+Header+" was already used for a different request"))
+	case rec.Status == 0:
+		log.Info("Request with the same idempotency key in progress")
+		w.Header().Set("Retry-After", "1")
+		response.RenderError(w, r, http.StatusConflict, response.Error(response.CodeKeyInProgress,
+			"A request with the same "+Header+" is in progress"))
+	default:
+		log.Info("Replaying idempotent response", slog.Int("status", rec.Status))
+		for name, value := range rec.Headers {
+			w.Header().Set(name, value)
+		}
+		w.Header().Set(ReplayedHeader, "true")
+		w.WriteHeader(rec.Status)
+		_, _ = w.Write(rec.Body)
+	}
+}
+
+// requestHash fingerprints the method, path and body of r.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func validKey(key string) bool {
+	if len(key) > maxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
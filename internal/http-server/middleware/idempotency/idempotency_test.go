@@ -0,0 +1,145 @@
+package idempotency_test
+
+import (
+	"URL-shortener/internal/http-server/middleware/auth"
+	"URL-shortener/internal/http-server/middleware/idempotency"
+	"URL-shortener/internal/http-server/middleware/idempotency/mocks"
+	"URL-shortener/internal/lib/logger/handlers/slogdiscard"
+	"URL-shortener/internal/storage"
+	"URL-shortener/internal/storage/memory"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// counter answers with the number of the call, so replays are told
+// apart from requests that ran again.
+func counter(calls *atomic.Int64, status int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.ReadAll(r.Body)
+		n := calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Location", "/alias")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"call":` + strconv.FormatInt(n, 10) + `}`))
+	})
+}
+
+func send(t *testing.T, handler http.Handler, key, body string, keyID int64) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodPost, "/url", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(idempotency.Header, key)
+	}
+	req = req.WithContext(auth.WithPrincipal(req.Context(), auth.Principal{KeyID: keyID}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	return rr
+}
+
+func TestIdempotency(t *testing.T) {
+	st, err := memory.New("")
+	require.NoError(t, err)
+
+	var calls atomic.Int64
+	handler := idempotency.New(slogdiscard.NewDiscardLogger(), st, time.Hour)(counter(&calls, http.StatusCreated))
+
+	first := send(t, handler, "abc", `{"url":"https://google.com"}`, 1)
+	require.Equal(t, http.StatusCreated, first.Code)
+	require.Equal(t, `{"call":1}`, first.Body.String())
+	require.Empty(t, first.Header().Get(idempotency.ReplayedHeader))
+
+	replayed := send(t, handler, "abc", `{"url":"https://google.com"}`, 1)
+	require.Equal(t, http.StatusCreated, replayed.Code)
+	require.Equal(t, `{"call":1}`, replayed.Body.String())
+	require.Equal(t, "/alias", replayed.Header().Get("Location"))
+	require.Equal(t, "application/json", replayed.Header().Get("Content-Type"))
+	require.Equal(t, "true", replayed.Header().Get(idempotency.ReplayedHeader))
+
+	reused := send(t, handler, "abc", `{"url":"https://ya.ru"}`, 1)
+	require.Equal(t, http.StatusUnprocessableEntity, reused.Code)
+	require.Contains(t, reused.Body.String(), "idempotency_key_reused")
+
+	// Keys of different owners don't clash.
+	other := send(t, handler, "abc", `{"url":"https://google.com"}`, 2)
+	require.Equal(t, http.StatusCreated, other.Code)
+	require.Equal(t, `{"call":2}`, other.Body.String())
+
+	without := send(t, handler, "", `{"url":"https://google.com"}`, 1)
+	require.Equal(t, `{"call":3}`, without.Body.String())
+
+	invalid := send(t, handler, "bad\tkey", `{"url":"https://google.com"}`, 1)
+	require.Equal(t, http.StatusBadRequest, invalid.Code)
+
+	require.Equal(t, int64(3), calls.Load())
+}
+
+func TestIdempotency_InProgress(t *testing.T) {
+	storeMock := mocks.NewStore(t)
+	storeMock.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(func(_ context.Context, rec storage.IdempotencyRecord) (storage.IdempotencyRecord, error) {
+		// Same request, no response yet.
+		return rec, storage.ErrIdempotencyKeyExists
+	}).Once()
+
+	handler := idempotency.New(slogdiscard.NewDiscardLogger(), storeMock, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler must not run")
+	}))
+
+	rr := send(t, handler, "abc", `{}`, 1)
+	require.Equal(t, http.StatusConflict, rr.Code)
+	require.Equal(t, "1", rr.Header().Get("Retry-After"))
+	require.Contains(t, rr.Body.String(), "idempotency_key_in_progress")
+}
+
+func TestIdempotency_ServerErrorReleasesKey(t *testing.T) {
+	st, err := memory.New("")
+	require.NoError(t, err)
+
+	var calls atomic.Int64
+	failing := idempotency.New(slogdiscard.NewDiscardLogger(), st, time.Hour)(counter(&calls, http.StatusInternalServerError))
+
+	rr := send(t, failing, "abc", `{}`, 1)
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+
+	handler := idempotency.New(slogdiscard.NewDiscardLogger(), st, time.Hour)(counter(&calls, http.StatusCreated))
+
+	rr = send(t, handler, "abc", `{}`, 1)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	require.Empty(t, rr.Header().Get(idempotency.ReplayedHeader))
+	require.Equal(t, int64(2), calls.Load())
+}
+
+func TestIdempotency_StoreError(t *testing.T) {
+	storeMock := mocks.NewStore(t)
+	storeMock.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(storage.IdempotencyRecord{}, errors.New("database error")).Once()
+
+	var calls atomic.Int64
+	handler := idempotency.New(slogdiscard.NewDiscardLogger(), storeMock, time.Hour)(counter(&calls, http.StatusCreated))
+
+	rr := send(t, handler, "abc", `{}`, 1)
+	require.Equal(t, http.StatusInternalServerError, rr.Code)
+	require.Zero(t, calls.Load())
+}
+
+func TestIdempotency_Disabled(t *testing.T) {
+	var calls atomic.Int64
+	handler := idempotency.New(slogdiscard.NewDiscardLogger(), mocks.NewStore(t), 0)(counter(&calls, http.StatusCreated))
+
+	send(t, handler, "abc", `{}`, 1)
+	send(t, handler, "abc", `{}`, 1)
+
+	require.Equal(t, int64(2), calls.Load())
+}
@@ -0,0 +1,73 @@
+package mocks
+
+import (
+	storage "URL-shortener/internal/storage"
+	context "context"
+
+	mock "github.com/stretchr/testify/mock"
+)
+
+type Store struct {
+	mock.Mock
+}
+
+func (_m *Store) ReserveIdempotencyKey(ctx context.Context, rec storage.IdempotencyRecord) (storage.IdempotencyRecord, error) {
+	ret := _m.Called(ctx, rec)
+
+	var r0 storage.IdempotencyRecord
+	var r1 error
+
+	if rf, ok := ret.Get(0).(func(context.Context, storage.IdempotencyRecord) (storage.IdempotencyRecord, error)); ok {
+		return rf(ctx, rec)
+	}
+
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.IdempotencyRecord)
+	}
+
+	r1 = ret.Error(1)
+
+	return r0, r1
+}
+
+func (_m *Store) CompleteIdempotencyKey(ctx context.Context, rec storage.IdempotencyRecord) error {
+	ret := _m.Called(ctx, rec)
+
+	var r0 error
+
+	if rf, ok := ret.Get(0).(func(context.Context, storage.IdempotencyRecord) error); ok {
+		return rf(ctx, rec)
+	}
+
+	r0 = ret.Error(0)
+
+	return r0
+}
+
+func (_m *Store) ReleaseIdempotencyKey(ctx context.Context, owner string, key string) error {
+	ret := _m.Called(ctx, owner, key)
+
+	var r0 error
+
+	if rf, ok := ret.Get(0).(func(context.Context, string, string) error); ok {
+		return rf(ctx, owner, key)
+	}
+
+	r0 = ret.Error(0)
+
+	return r0
+}
+
+type mockConstructorTestingTNewStore interface {
+	mock.TestingT
+	Cleanup(func())
+}
+
+func NewStore(t mockConstructorTestingTNewStore) *Store {
+	mock := &Store{}
+	mock.Mock.Test(t)
+
+	t.Cleanup(func() { mock.AssertExpectations(t) })
+
+	return mock
+}
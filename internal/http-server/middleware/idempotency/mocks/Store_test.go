@@ -0,0 +1,71 @@
+package mocks
+
+import (
+	"URL-shortener/internal/storage"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestStore_ReserveIdempotencyKey(t *testing.T) {
+	tests := []struct {
+		name           string
+		setupMock      func(*Store)
+		expectedRecord storage.IdempotencyRecord
+		expectedErr    error
+	}{
+		{
+			name: "reserved",
+			setupMock: func(m *Store) {
+				m.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(storage.IdempotencyRecord{Key: "abc"}, nil)
+			},
+			expectedRecord: storage.IdempotencyRecord{Key: "abc"},
+		},
+		{
+			name: "key exists",
+			setupMock: func(m *Store) {
+				m.On("ReserveIdempotencyKey", mock.Anything, mock.Anything).Return(storage.IdempotencyRecord{Key: "abc", Status: 201}, storage.ErrIdempotencyKeyExists)
+			},
+			expectedRecord: storage.IdempotencyRecord{Key: "abc", Status: 201},
+			expectedErr:    storage.ErrIdempotencyKeyExists,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStore := NewStore(t)
+			tt.setupMock(mockStore)
+
+			rec, err := mockStore.ReserveIdempotencyKey(context.Background(), storage.IdempotencyRecord{Key: "abc"})
+
+			assert.Equal(t, tt.expectedRecord, rec)
+			assert.ErrorIs(t, err, tt.expectedErr)
+
+			mockStore.AssertExpectations(t)
+		})
+	}
+}
+
+func TestStore_CompleteIdempotencyKey(t *testing.T) {
+	mockStore := NewStore(t)
+	mockStore.On("CompleteIdempotencyKey", mock.Anything, storage.IdempotencyRecord{Key: "abc", Status: 201}).Return(errors.New("database error"))
+
+	err := mockStore.CompleteIdempotencyKey(context.Background(), storage.IdempotencyRecord{Key: "abc", Status: 201})
+
+	assert.EqualError(t, err, "database error")
+}
+
+func TestStore_ReleaseIdempotencyKey(t *testing.T) {
+	mockStore := NewStore(t)
+	mockStore.On("ReleaseIdempotencyKey", mock.Anything, "key:1", "abc").Return(nil).Once()
+
+	assert.NoError(t, mockStore.ReleaseIdempotencyKey(context.Background(), "key:1", "abc"))
+}
+
+func TestNewStore(t *testing.T) {
+	mock := NewStore(t)
+	assert.NotNil(t, mock)
+}
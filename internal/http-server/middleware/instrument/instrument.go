@@ -0,0 +1,77 @@
+package instrument
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type Recorder interface {
+	ObserveRequest(method, route string, status, bytes int, duration time.Duration)
+	CountOperation(operation, outcome string)
+}
+
+// unmatchedRoute labels requests that did not match any route.
+const unmatchedRoute = "unmatched"
+
+// New records the duration, status and size of every request by its chi
+// route pattern. Requests whose "METHOD pattern" is a key of operations
+// are also counted as that operation, with the outcome derived from the status.
+func New(recorder Recorder, operations map[string]string) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+			t1 := time.Now()
+			defer func() {
+				status := ww.Status()
+				if status == 0 {
+					status = http.StatusOK
+				}
+
+				route := unmatchedRoute
+				if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+					route = rctx.RoutePattern()
+				}
+
+				recorder.ObserveRequest(r.Method, route, status, ww.BytesWritten(), time.Since(t1))
+
+				if operation, ok := operations[r.Method+" "+route]; ok {
+					recorder.CountOperation(operation, Outcome(status))
+				}
+			}()
+
+			next.ServeHTTP(ww, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+// Outcome maps a response status to a small set of outcome labels.
+func Outcome(status int) string {
+	switch {
+	case status < http.StatusBadRequest:
+		return "success"
+	case status == http.StatusBadRequest, status == http.StatusUnprocessableEntity:
+		return "invalid"
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return "unauthorized"
+	case status == http.StatusNotFound:
+		return "not_found"
+	case status == http.StatusConflict, status == http.StatusPreconditionFailed:
+		return "conflict"
+	case status == http.StatusGone:
+		return "expired"
+	case status == http.StatusServiceUnavailable:
+		return "unavailable"
+	case status == http.StatusGatewayTimeout:
+		return "timeout"
+	case status < http.StatusInternalServerError:
+		return "rejected"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,101 @@
+package instrument_test
+
+import (
+	"URL-shortener/internal/http-server/middleware/instrument"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+type request struct {
+	method, route string
+	status, bytes int
+}
+
+type recorder struct {
+	mu         sync.Mutex
+	requests   []request
+	operations []string
+}
+
+func (r *recorder) ObserveRequest(method, route string, status, bytes int, _ time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.requests = append(r.requests, request{method: method, route: route, status: status, bytes: bytes})
+}
+
+func (r *recorder) CountOperation(operation, outcome string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.operations = append(r.operations, operation+"/"+outcome)
+}
+
+func TestInstrument(t *testing.T) {
+	rec := &recorder{}
+
+	router := chi.NewRouter()
+	router.Use(instrument.New(rec, map[string]string{
+		"GET /{alias}":        "redirect",
+		"DELETE /url/{alias}": "delete",
+	}))
+	router.Get("/{alias}", func(w http.ResponseWriter, r *http.Request) {
+		if chi.URLParam(r, "alias") == "missing" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Location", "https://google.com")
+		w.WriteHeader(http.StatusFound)
+	})
+	router.Route("/url", func(r chi.Router) {
+		r.Delete("/{alias}", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("{}"))
+		})
+	})
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodGet, "/google"},
+		{http.MethodGet, "/missing"},
+		{http.MethodDelete, "/url/google"},
+		{http.MethodGet, "/no/such/route"},
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, nil))
+	}
+
+	require.Equal(t, []request{
+		{method: http.MethodGet, route: "/{alias}", status: http.StatusFound, bytes: 0},
+		{method: http.MethodGet, route: "/{alias}", status: http.StatusNotFound, bytes: 10},
+		{method: http.MethodDelete, route: "/url/{alias}", status: http.StatusOK, bytes: 2},
+		{method: http.MethodGet, route: "unmatched", status: http.StatusNotFound, bytes: 19},
+	}, rec.requests)
+	require.Equal(t, []string{"redirect/success", "redirect/not_found", "delete/success"}, rec.operations)
+}
+
+func TestOutcome(t *testing.T) {
+	cases := map[int]string{
+		http.StatusOK:                  "success",
+		http.StatusCreated:             "success",
+		http.StatusFound:               "success",
+		http.StatusBadRequest:          "invalid",
+		http.StatusUnprocessableEntity: "invalid",
+		http.StatusUnauthorized:        "unauthorized",
+		http.StatusNotFound:            "not_found",
+		http.StatusConflict:            "conflict",
+		http.StatusPreconditionFailed:  "conflict",
+		http.StatusGone:                "expired",
+		http.StatusTooManyRequests:     "rejected",
+		http.StatusInternalServerError: "error",
+		http.StatusServiceUnavailable:  "unavailable",
+		http.StatusGatewayTimeout:      "timeout",
+	}
+
+	for status, expected := range cases {
+		require.Equal(t, expected, instrument.Outcome(status), status)
+	}
+}
@@ -0,0 +1,71 @@
+package legacystatus
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// New restores the pre-1.0 behavior of answering 200 OK for created
+// links and the errors the old handlers reported in the body. Only
+// requests whose "METHOD pattern" is one of routes, the handlers that
+// existed before 1.0, are affected; the pattern is taken from the chi
+// route context when the status is written. Other statuses, such as
+// the 401 BasicAuth challenge, redirects or 503, are passed through
+// as they were before.
+//
+// Deprecated: kept for one release so clients can migrate to proper status codes.
+func New(routes ...string) func(next http.Handler) http.Handler {
+	legacy := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		legacy[strings.TrimSuffix(route, "/")] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&responseWriter{ResponseWriter: w, r: r, legacy: legacy}, r)
+		}
+
+		return http.HandlerFunc(fn)
+	}
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+
+	r      *http.Request
+	legacy map[string]bool
+}
+
+// legacyOK lists the statuses the old handlers answered with 200 OK.
+var legacyOK = map[int]bool{
+	http.StatusCreated:             true,
+	http.StatusBadRequest:          true,
+	http.StatusNotFound:            true,
+	http.StatusConflict:            true,
+	http.StatusUnprocessableEntity: true,
+	http.StatusInternalServerError: true,
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if legacyOK[code] && w.isLegacyRoute() {
+		code = http.StatusOK
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) isLegacyRoute() bool {
+	rctx := chi.RouteContext(w.r.Context())
+	if rctx == nil {
+		return false
+	}
+
+	// chi versions disagree on whether "/url/" keeps its trailing slash.
+	return w.legacy[w.r.Method+" "+strings.TrimSuffix(rctx.RoutePattern(), "/")]
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,91 @@
+package legacystatus_test
+
+import (
+	"URL-shortener/internal/http-server/middleware/legacystatus"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLegacyStatus(t *testing.T) {
+	cases := []struct {
+		status   int
+		expected int
+	}{
+		{status: http.StatusOK, expected: http.StatusOK},
+		{status: http.StatusCreated, expected: http.StatusOK},
+		{status: http.StatusFound, expected: http.StatusFound},
+		{status: http.StatusBadRequest, expected: http.StatusOK},
+		{status: http.StatusNotFound, expected: http.StatusOK},
+		{status: http.StatusConflict, expected: http.StatusOK},
+		{status: http.StatusUnprocessableEntity, expected: http.StatusOK},
+		{status: http.StatusInternalServerError, expected: http.StatusOK},
+		{status: http.StatusUnauthorized, expected: http.StatusUnauthorized},
+		{status: http.StatusMethodNotAllowed, expected: http.StatusMethodNotAllowed},
+		{status: http.StatusGone, expected: http.StatusGone},
+		{status: http.StatusServiceUnavailable, expected: http.StatusServiceUnavailable},
+		{status: http.StatusGatewayTimeout, expected: http.StatusGatewayTimeout},
+	}
+
+	for _, tc := range cases {
+		t.Run(http.StatusText(tc.status), func(t *testing.T) {
+			router := chi.NewRouter()
+			router.Use(legacystatus.New(http.MethodGet + " /{alias}"))
+			router.Get("/{alias}", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tc.status)
+			})
+
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/abc", nil))
+
+			require.Equal(t, tc.expected, rr.Code)
+		})
+	}
+}
+
+func TestLegacyStatus_OtherRoutes(t *testing.T) {
+	router := chi.NewRouter()
+	router.Use(legacystatus.New(http.MethodPost+" /url/", http.MethodDelete+" /url/{alias}"))
+	router.Route("/url", func(r chi.Router) {
+		r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		})
+		r.Post("/batch", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusCreated)
+		})
+		r.Delete("/{alias}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+		r.Patch("/{alias}", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+	})
+	router.Get("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	cases := []struct {
+		method   string
+		path     string
+		expected int
+	}{
+		{method: http.MethodPost, path: "/url/", expected: http.StatusOK},
+		{method: http.MethodDelete, path: "/url/abc", expected: http.StatusOK},
+		{method: http.MethodPost, path: "/url/batch", expected: http.StatusCreated},
+		{method: http.MethodPatch, path: "/url/abc", expected: http.StatusNotFound},
+		{method: http.MethodGet, path: "/healthz", expected: http.StatusInternalServerError},
+		{method: http.MethodGet, path: "/missing/route", expected: http.StatusNotFound},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.path, nil))
+
+			require.Equal(t, tc.expected, rr.Code)
+		})
+	}
+}
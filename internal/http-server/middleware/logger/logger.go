@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"URL-shortener/internal/lib/telemetry"
 	"log/slog"
 	"net/http"
 	"time"
@@ -23,6 +24,7 @@ func New(log *slog.Logger) func(next http.Handler) http.Handler {
 				slog.String("remote_addr", r.RemoteAddr),
 				slog.String("user_agent", r.UserAgent()),
 				slog.String("request_id", r.Context().Value(middleware.RequestIDKey).(string)),
+				slog.String("trace_id", telemetry.TraceID(r.Context())),
 			)
 
 			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
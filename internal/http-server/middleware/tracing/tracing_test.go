@@ -0,0 +1,65 @@
+package tracing_test
+
+import (
+	"URL-shortener/internal/http-server/middleware/tracing"
+	"URL-shortener/internal/lib/telemetry"
+	"URL-shortener/internal/storage"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracing(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := telemetry.NewTracerProvider(exporter, telemetry.Options{ServiceName: "test", SampleRatio: 1})
+
+	var traceID string
+
+	router := chi.NewRouter()
+	router.Use(tracing.New(tp, telemetry.Propagator()))
+	router.Get("/{alias}", func(w http.ResponseWriter, r *http.Request) {
+		traceID = telemetry.TraceID(r.Context())
+
+		// Child spans of storage queries join the request trace.
+		_, span := tp.Tracer("test").Start(r.Context(), "storage.GetURL")
+		storage.EndSpan(span, storage.ErrURLNotFound)
+
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/google", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	require.NoError(t, tp.ForceFlush(context.Background()))
+
+	require.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", traceID)
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+
+	child, server := spans[0], spans[1]
+
+	require.Equal(t, "storage.GetURL", child.Name)
+	require.Equal(t, server.SpanContext.SpanID(), child.Parent.SpanID())
+	require.Equal(t, codes.Unset, child.Status.Code)
+	require.Len(t, child.Events, 1)
+
+	require.Equal(t, "GET /{alias}", server.Name)
+	require.Equal(t, trace.SpanKindServer, server.SpanKind)
+	require.Equal(t, traceID, server.SpanContext.TraceID().String())
+	require.Equal(t, "00f067aa0ba902b7", server.Parent.SpanID().String())
+	require.True(t, server.Parent.IsRemote())
+	require.Equal(t, codes.Error, server.Status.Code)
+	require.Contains(t, server.Attributes, semconv.HTTPRoute("/{alias}"))
+	require.Contains(t, server.Attributes, semconv.HTTPResponseStatusCode(http.StatusInternalServerError))
+}
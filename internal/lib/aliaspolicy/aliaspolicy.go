@@ -0,0 +1,207 @@
+package aliaspolicy
+
+import (
+	"URL-shortener/internal/lib/random"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+var (
+	ErrTooShort = errors.New("alias is too short")
+	ErrTooLong  = errors.New("alias is too long")
+	ErrInvalid  = errors.New("alias contains invalid characters")
+	ErrReserved = errors.New("alias is reserved")
+	// ErrExhausted means every generated alias was reserved,
+	// e.g. because the reserved words cover the whole alias space.
+	ErrExhausted = errors.New("no unreserved alias could be generated")
+)
+
+type Options struct {
+	// Length is the initial length of generated aliases, MaxGeneratedLength
+	// caps how far it may grow when collisions become frequent.
+	Length             int
+	MaxGeneratedLength int
+	// Alphabet is the set of characters generated aliases are built from.
+	Alphabet string
+	// MinLength, MaxLength and Pattern restrict custom aliases.
+	MinLength int
+	MaxLength int
+	Pattern   string
+	// CaseSensitive false makes "Abc" and "abc" the same alias:
+	// aliases are stored and looked up in lower case.
+	CaseSensitive bool
+	Reserved      []string
+}
+
+// Policy decides which aliases are acceptable and generates random ones.
+// It is safe for concurrent use.
+type Policy struct {
+	*random.Generator
+
+	minLength     int
+	maxLength     int
+	pattern       *regexp.Regexp
+	caseSensitive bool
+
+	mu       sync.RWMutex
+	reserved map[string]struct{}
+}
+
+func New(opts Options) (*Policy, error) {
+	const op = "aliaspolicy.New"
+
+	pattern, err := regexp.Compile(opts.Pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%s: compile pattern: %w", op, err)
+	}
+
+	if err := validateAlphabet(opts.Alphabet); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	alphabet := opts.Alphabet
+	if !opts.CaseSensitive {
+		alphabet = dedup(strings.ToLower(alphabet))
+	}
+	if n := len([]rune(alphabet)); n < minAlphabet {
+		return nil, fmt.Errorf("%s: alphabet has %d distinct characters ignoring case, need at least %d", op, n, minAlphabet)
+	}
+	if opts.Length <= 0 {
+		return nil, fmt.Errorf("%s: length must be positive", op)
+	}
+
+	p := &Policy{
+		Generator:     random.NewGenerator(alphabet, opts.Length, opts.MaxGeneratedLength),
+		minLength:     opts.MinLength,
+		maxLength:     opts.MaxLength,
+		pattern:       pattern,
+		caseSensitive: opts.CaseSensitive,
+		reserved:      make(map[string]struct{}),
+	}
+	p.Reserve(opts.Reserved...)
+
+	return p, nil
+}
+
+// Reserve forbids the given words as aliases, e.g. top-level route names.
+// Reserved words are matched case-insensitively.
+func (p *Policy) Reserve(words ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w != "" {
+			p.reserved[strings.ToLower(w)] = struct{}{}
+		}
+	}
+}
+
+func (p *Policy) IsReserved(alias string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	_, ok := p.reserved[strings.ToLower(alias)]
+	return ok
+}
+
+// Normalize returns the canonical form aliases are stored and looked up in.
+func (p *Policy) Normalize(alias string) string {
+	if p.caseSensitive {
+		return alias
+	}
+
+	return strings.ToLower(alias)
+}
+
+// Validate checks a custom alias against the policy.
+func (p *Policy) Validate(alias string) error {
+	n := len([]rune(alias))
+
+	switch {
+	case p.minLength > 0 && n < p.minLength:
+		return fmt.Errorf("%w: minimum is %d characters", ErrTooShort, p.minLength)
+	case p.maxLength > 0 && n > p.maxLength:
+		return fmt.Errorf("%w: maximum is %d characters", ErrTooLong, p.maxLength)
+	case !p.pattern.MatchString(alias):
+		return fmt.Errorf("%w: must match %s", ErrInvalid, p.pattern)
+	case strings.IndexFunc(alias, isSpecial) >= 0:
+		return fmt.Errorf("%w: must not contain %s or whitespace", ErrInvalid, specialChars)
+	case p.IsReserved(alias):
+		return ErrReserved
+	}
+
+	return nil
+}
+
+// maxReservedDraws limits how many random aliases Generate draws
+// before giving up on finding one that is not reserved.
+const maxReservedDraws = 100
+
+// Generate returns a random alias that is not reserved.
+func (p *Policy) Generate() (string, error) {
+	for i := 0; i < maxReservedDraws; i++ {
+		if a := p.Generator.Generate(); !p.IsReserved(a) {
+			return a, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w after %d attempts", ErrExhausted, maxReservedDraws)
+}
+
+// Generated aliases pick each character from a random byte,
+// see random.String, so the alphabet can't be larger than 256.
+const (
+	minAlphabet = 2
+	maxAlphabet = 256
+)
+
+// specialChars can't appear in an alias because the router gives them
+// a meaning of their own: "/" splits the path, URLFormat strips ".ext",
+// a trailing "+" opens the preview page, "?" and "#" end the path and
+// "%" starts an escape.
+const specialChars = "/.+?#%"
+
+func isSpecial(c rune) bool {
+	return strings.ContainsRune(specialChars, c) || unicode.IsSpace(c)
+}
+
+// validateAlphabet checks that alphabet has 2 to 256 distinct
+// characters and none of specialChars or whitespace.
+func validateAlphabet(alphabet string) error {
+	chars := []rune(alphabet)
+	if len(chars) < minAlphabet || len(chars) > maxAlphabet {
+		return fmt.Errorf("alphabet must have %d to %d characters, got %d", minAlphabet, maxAlphabet, len(chars))
+	}
+
+	seen := make(map[rune]struct{}, len(chars))
+	for _, c := range chars {
+		if isSpecial(c) {
+			return fmt.Errorf("alphabet must not contain %q", c)
+		}
+		if _, ok := seen[c]; ok {
+			return fmt.Errorf("alphabet contains %q more than once", c)
+		}
+		seen[c] = struct{}{}
+	}
+
+	return nil
+}
+
+func dedup(s string) string {
+	seen := make(map[rune]struct{}, len(s))
+
+	var b strings.Builder
+	for _, c := range s {
+		if _, ok := seen[c]; ok {
+			continue
+		}
+		seen[c] = struct{}{}
+		b.WriteRune(c)
+	}
+
+	return b.String()
+}
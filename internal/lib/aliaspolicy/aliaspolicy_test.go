@@ -0,0 +1,166 @@
+package aliaspolicy_test
+
+import (
+	"URL-shortener/internal/lib/aliaspolicy"
+	"URL-shortener/internal/lib/random"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func defaultOptions() aliaspolicy.Options {
+	return aliaspolicy.Options{
+		Length:             6,
+		MaxGeneratedLength: 16,
+		Alphabet:           random.Alphabet,
+		MinLength:          3,
+		MaxLength:          10,
+		Pattern:            "^[A-Za-z0-9_-]+$",
+		CaseSensitive:      true,
+		Reserved:           []string{"url", "healthz"},
+	}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	policy, err := aliaspolicy.New(defaultOptions())
+	require.NoError(t, err)
+
+	cases := []struct {
+		alias string
+		err   error
+	}{
+		{alias: "good_alias"},
+		{alias: "ab", err: aliaspolicy.ErrTooShort},
+		{alias: "much_too_long", err: aliaspolicy.ErrTooLong},
+		{alias: "a/b", err: aliaspolicy.ErrInvalid},
+		{alias: "привет", err: aliaspolicy.ErrInvalid},
+		{alias: "url", err: aliaspolicy.ErrReserved},
+		{alias: "URL", err: aliaspolicy.ErrReserved},
+		{alias: "HealthZ", err: aliaspolicy.ErrReserved},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.alias, func(t *testing.T) {
+			err := policy.Validate(tc.alias)
+			if tc.err == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tc.err)
+			}
+		})
+	}
+
+	policy.Reserve("metrics")
+	require.ErrorIs(t, policy.Validate("metrics"), aliaspolicy.ErrReserved)
+}
+
+func TestPolicy_ValidateSpecialChars(t *testing.T) {
+	opts := defaultOptions()
+	opts.Pattern = "^.+$"
+
+	policy, err := aliaspolicy.New(opts)
+	require.NoError(t, err)
+
+	require.NoError(t, policy.Validate("any_alias"))
+	for _, alias := range []string{"a/b", "a.txt", "abc+", "a?b", "a#b", "a%20", "a b", "a\tb"} {
+		require.ErrorIs(t, policy.Validate(alias), aliaspolicy.ErrInvalid, alias)
+	}
+}
+
+func TestPolicy_CaseInsensitive(t *testing.T) {
+	opts := defaultOptions()
+	opts.CaseSensitive = false
+
+	policy, err := aliaspolicy.New(opts)
+	require.NoError(t, err)
+
+	require.Equal(t, "myalias", policy.Normalize("MyAlias"))
+
+	for i := 0; i < 100; i++ {
+		a, err := policy.Generate()
+		require.NoError(t, err)
+		require.Len(t, a, 6)
+		require.Equal(t, strings.ToLower(a), a)
+	}
+}
+
+func TestNew_InvalidPattern(t *testing.T) {
+	opts := defaultOptions()
+	opts.Pattern = "["
+
+	_, err := aliaspolicy.New(opts)
+	require.Error(t, err)
+}
+
+func TestNew_InvalidAlphabet(t *testing.T) {
+	cases := []struct {
+		name          string
+		alphabet      string
+		caseSensitive bool
+	}{
+		{name: "Empty", alphabet: "", caseSensitive: true},
+		{name: "Single character", alphabet: "a", caseSensitive: true},
+		{name: "Too long", alphabet: runes(257), caseSensitive: true},
+		{name: "Duplicates", alphabet: "abca", caseSensitive: true},
+		{name: "Slash", alphabet: "ab/", caseSensitive: true},
+		{name: "Dot", alphabet: "ab.", caseSensitive: true},
+		{name: "Plus", alphabet: "ab+", caseSensitive: true},
+		{name: "Question mark", alphabet: "ab?", caseSensitive: true},
+		{name: "Hash", alphabet: "ab#", caseSensitive: true},
+		{name: "Percent", alphabet: "ab%", caseSensitive: true},
+		{name: "Space", alphabet: "ab ", caseSensitive: true},
+		{name: "Non-breaking space", alphabet: "ab\u00a0", caseSensitive: true},
+		{name: "Single character ignoring case", alphabet: "aA", caseSensitive: false},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			opts := defaultOptions()
+			opts.Alphabet = tc.alphabet
+			opts.CaseSensitive = tc.caseSensitive
+
+			_, err := aliaspolicy.New(opts)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestNew_LargestAlphabet(t *testing.T) {
+	opts := defaultOptions()
+	opts.Alphabet = runes(256)
+
+	policy, err := aliaspolicy.New(opts)
+	require.NoError(t, err)
+	a, err := policy.Generate()
+	require.NoError(t, err)
+	require.Len(t, []rune(a), opts.Length)
+}
+
+func TestPolicy_GenerateExhausted(t *testing.T) {
+	opts := defaultOptions()
+	opts.Alphabet = "ab"
+	opts.Length = 1
+	opts.MaxGeneratedLength = 1
+	opts.Reserved = []string{"a", "b"}
+
+	policy, err := aliaspolicy.New(opts)
+	require.NoError(t, err)
+
+	_, err = policy.Generate()
+	require.ErrorIs(t, err, aliaspolicy.ErrExhausted)
+}
+
+// runes returns n distinct letters.
+func runes(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		b.WriteRune(rune(0x100 + i))
+	}
+
+	return b.String()
+}
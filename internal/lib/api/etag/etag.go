@@ -0,0 +1,41 @@
+package etag
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrMalformed means an If-Match header is not an entity tag issued by Format.
+var ErrMalformed = errors.New("malformed entity tag")
+
+// Format returns the strong entity tag of a link version.
+func Format(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// ParseIfMatch returns the link version an If-Match header requires.
+// A missing header and "*" match any version and yield zero.
+func ParseIfMatch(header string) (int64, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	// Weak tags never match under If-Match, so only strong ones are accepted.
+	raw, ok := strings.CutPrefix(header, `"`)
+	if !ok {
+		return 0, ErrMalformed
+	}
+	raw, ok = strings.CutSuffix(raw, `"`)
+	if !ok {
+		return 0, ErrMalformed
+	}
+
+	version, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || version < 1 {
+		return 0, ErrMalformed
+	}
+
+	return version, nil
+}
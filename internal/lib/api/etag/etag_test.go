@@ -0,0 +1,44 @@
+package etag_test
+
+import (
+	"URL-shortener/internal/lib/api/etag"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	cases := []struct {
+		name     string
+		header   string
+		expected int64
+		wantErr  bool
+	}{
+		{name: "Missing", header: "", expected: 0},
+		{name: "Any", header: "*", expected: 0},
+		{name: "Version", header: `"3"`, expected: 3},
+		{name: "Round trip", header: etag.Format(42), expected: 42},
+		{name: "Weak", header: `W/"3"`, wantErr: true},
+		{name: "Unquoted", header: "3", wantErr: true},
+		{name: "Not a number", header: `"abc"`, wantErr: true},
+		{name: "Zero", header: `"0"`, wantErr: true},
+		{name: "List", header: `"1", "2"`, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			version, err := etag.ParseIfMatch(tc.header)
+			if tc.wantErr {
+				require.ErrorIs(t, err, etag.ErrMalformed)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, version)
+		})
+	}
+}
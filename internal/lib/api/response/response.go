@@ -1,14 +1,31 @@
 package response
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/go-playground/validator/v10"
+	"mime"
+	"net/http"
+	"reflect"
 	"strings"
+
+	"github.com/go-chi/render"
+	"github.com/go-playground/validator/v10"
 )
 
 type Response struct {
-	Status string `json:"status"`
-	Error  string `json:"error,omitempty"`
+	Status string       `json:"status"`
+	Error  string       `json:"error,omitempty"`
+	Code   string       `json:"code,omitempty"`
+	Fields []FieldError `json:"fields,omitempty"`
+}
+
+// FieldError describes why a single request field failed validation.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
 const (
@@ -16,35 +33,160 @@ const (
 	StatusError = "ERROR"
 )
 
+// Machine-readable error codes, stable across releases.
+// Clients should branch on these instead of the error message.
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidationFailed = "validation_failed"
+	CodeAliasTaken       = "alias_taken"
+	CodeInvalidAlias     = "invalid_alias"
+	CodeAliasReserved    = "alias_reserved"
+	CodeInvalidExpiry    = "invalid_expiry"
+	CodeNotFound         = "not_found"
+	CodeExpired          = "expired"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeVersionMismatch  = "version_mismatch"
+	CodeBatchAborted     = "batch_aborted"
+	CodeKeyReused        = "idempotency_key_reused"
+	CodeKeyInProgress    = "idempotency_key_in_progress"
+	CodeTimeout          = "timeout"
+	CodeUnavailable      = "unavailable"
+	CodeInternal         = "internal_error"
+)
+
+// Field-level codes used in FieldError.
+const (
+	FieldCodeRequired   = "required"
+	FieldCodeInvalidURL = "invalid_url"
+	FieldCodeInvalid    = "invalid"
+	FieldCodeReserved   = "reserved"
+)
+
+const ContentTypeProblem = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code,omitempty"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
 func OK() Response {
 	return Response{
 		Status: StatusOK,
 	}
 }
 
-func Error(msg string) Response {
+func Error(code, msg string) Response {
 	return Response{
 		Status: StatusError,
 		Error:  msg,
+		Code:   code,
 	}
 }
 
+// NewValidator returns a validator that reports fields by their JSON
+// names, so that FieldError.Field matches the request body.
+func NewValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	return v
+}
+
 func ValidationError(errs validator.ValidationErrors) Response {
 	var errMsgs []string
+	var fields []FieldError
 
 	for _, err := range errs {
+		var fieldErr FieldError
+
 		switch err.ActualTag() {
 		case "required":
-			errMsgs = append(errMsgs, fmt.Sprintf("Field '%s' is required", err.Field()))
+			fieldErr = FieldError{
+				Code:    FieldCodeRequired,
+				Message: fmt.Sprintf("Field '%s' is required", err.StructField()),
+			}
 		case "url":
-			errMsgs = append(errMsgs, fmt.Sprintf("Field %s is not a valid URL", err.Field()))
+			fieldErr = FieldError{
+				Code:    FieldCodeInvalidURL,
+				Message: fmt.Sprintf("Field %s is not a valid URL", err.StructField()),
+			}
 		default:
-			errMsgs = append(errMsgs, fmt.Sprintf("Field %s is not valid", err.Field()))
+			fieldErr = FieldError{
+				Code:    FieldCodeInvalid,
+				Message: fmt.Sprintf("Field %s is not valid", err.StructField()),
+			}
 		}
+
+		fieldErr.Field = err.Field()
+		fields = append(fields, fieldErr)
+		errMsgs = append(errMsgs, fieldErr.Message)
 	}
 
 	return Response{
 		Status: StatusError,
 		Error:  strings.Join(errMsgs, ", "),
+		Code:   CodeValidationFailed,
+		Fields: fields,
+	}
+}
+
+// ContextError reports whether err comes from a context deadline or
+// cancellation, and if so which status and response to answer with.
+func ContextError(err error) (int, Response, bool) {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, Error(CodeTimeout, "Request timed out"), true
+	case errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable, Error(CodeUnavailable, "Request canceled"), true
+	}
+
+	return 0, Response{}, false
+}
+
+// RenderError writes an error response with the given HTTP status.
+// Clients that accept application/problem+json get an RFC 7807 body,
+// everybody else gets the regular Response JSON.
+func RenderError(w http.ResponseWriter, r *http.Request, status int, resp Response) {
+	if !AcceptsProblem(r) {
+		render.Status(r, status)
+		render.JSON(w, r, resp)
+		return
+	}
+
+	w.Header().Set("Content-Type", ContentTypeProblem)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(Problem{
+		Type:     "urn:url-shortener:error:" + resp.Code,
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   resp.Error,
+		Instance: r.URL.Path,
+		Code:     resp.Code,
+		Errors:   resp.Fields,
+	})
+}
+
+// AcceptsProblem reports whether the Accept header lists application/problem+json.
+func AcceptsProblem(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err == nil && mediaType == ContentTypeProblem {
+			return true
+		}
 	}
+
+	return false
 }
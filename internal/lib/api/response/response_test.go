@@ -0,0 +1,116 @@
+package response_test
+
+import (
+	"URL-shortener/internal/lib/api/response"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/require"
+)
+
+type request struct {
+	URL   string `json:"url" validate:"required,url"`
+	Alias string `json:"alias,omitempty" validate:"required"`
+}
+
+func validationErrors(t *testing.T) validator.ValidationErrors {
+	t.Helper()
+
+	err := response.NewValidator().Struct(request{URL: "not a url"})
+	require.Error(t, err)
+
+	return err.(validator.ValidationErrors)
+}
+
+func TestValidationError(t *testing.T) {
+	resp := response.ValidationError(validationErrors(t))
+
+	require.Equal(t, response.StatusError, resp.Status)
+	require.Equal(t, response.CodeValidationFailed, resp.Code)
+	require.Equal(t, "Field URL is not a valid URL, Field 'Alias' is required", resp.Error)
+	require.Equal(t, []response.FieldError{
+		{Field: "url", Code: response.FieldCodeInvalidURL, Message: "Field URL is not a valid URL"},
+		{Field: "alias", Code: response.FieldCodeRequired, Message: "Field 'Alias' is required"},
+	}, resp.Fields)
+}
+
+func TestRenderError(t *testing.T) {
+	cases := []struct {
+		name        string
+		accept      string
+		contentType string
+		problem     bool
+	}{
+		{
+			name:        "JSON",
+			accept:      "",
+			contentType: "application/json",
+		},
+		{
+			name:        "Problem",
+			accept:      "application/json;q=0.5, application/problem+json",
+			contentType: response.ContentTypeProblem,
+			problem:     true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/url", nil)
+			req.Header.Set("Accept", tc.accept)
+
+			rr := httptest.NewRecorder()
+			response.RenderError(rr, req, http.StatusUnprocessableEntity, response.ValidationError(validationErrors(t)))
+
+			require.Equal(t, http.StatusUnprocessableEntity, rr.Code)
+			require.Contains(t, rr.Header().Get("Content-Type"), tc.contentType)
+
+			if !tc.problem {
+				var resp response.Response
+				require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+				require.Equal(t, response.CodeValidationFailed, resp.Code)
+				require.Len(t, resp.Fields, 2)
+				return
+			}
+
+			var problem response.Problem
+			require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+			require.Equal(t, http.StatusUnprocessableEntity, problem.Status)
+			require.Equal(t, "Unprocessable Entity", problem.Title)
+			require.Equal(t, response.CodeValidationFailed, problem.Code)
+			require.Equal(t, "/url", problem.Instance)
+			require.Len(t, problem.Errors, 2)
+		})
+	}
+}
+
+func TestContextError(t *testing.T) {
+	cases := []struct {
+		name     string
+		err      error
+		ok       bool
+		status   int
+		expected string
+	}{
+		{name: "deadline", err: fmt.Errorf("storage.GetURL: %w", context.DeadlineExceeded), ok: true, status: http.StatusGatewayTimeout, expected: response.CodeTimeout},
+		{name: "canceled", err: context.Canceled, ok: true, status: http.StatusServiceUnavailable, expected: response.CodeUnavailable},
+		{name: "other", err: errors.New("database error")},
+		{name: "nil", err: nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, resp, ok := response.ContextError(tc.err)
+
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.status, status)
+			require.Equal(t, tc.expected, resp.Code)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package apikey
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"slices"
+)
+
+// Scopes limit what a key may do. ScopeAdmin grants every other scope
+// and additionally allows managing keys.
+const (
+	ScopeCreate    = "create"
+	ScopeUpdate    = "update"
+	ScopeDelete    = "delete"
+	ScopeReadStats = "read-stats"
+	ScopeAdmin     = "admin"
+)
+
+// Scopes lists every valid scope.
+var Scopes = []string{ScopeCreate, ScopeUpdate, ScopeDelete, ScopeReadStats, ScopeAdmin}
+
+const (
+	// keyPrefix makes keys easy to recognize, e.g. by secret scanners.
+	keyPrefix = "usk_"
+	// secretBytes of randomness make keys infeasible to guess, so a fast
+	// hash is enough to store them.
+	secretBytes = 32
+	// displayLength is how much of a key is kept in clear to tell keys apart.
+	displayLength = len(keyPrefix) + 8
+)
+
+// Generate returns a new random key together with its display prefix
+// and the hash to store.
+func Generate() (key, prefix, hash string, err error) {
+	const op = "lib.apikey.Generate"
+
+	secret := make([]byte, secretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	key = keyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	return key, key[:displayLength], Hash(key), nil
+}
+
+// Hash returns the hex encoded SHA-256 of key.
+func Hash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidScope reports whether scope is one of Scopes.
+func ValidScope(scope string) bool {
+	return slices.Contains(Scopes, scope)
+}
+
+// HasScope reports whether scopes grant scope.
+func HasScope(scopes []string, scope string) bool {
+	return slices.Contains(scopes, scope) || slices.Contains(scopes, ScopeAdmin)
+}
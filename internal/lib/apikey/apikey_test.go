@@ -0,0 +1,46 @@
+package apikey_test
+
+import (
+	"URL-shortener/internal/lib/apikey"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	key, prefix, hash, err := apikey.Generate()
+	require.NoError(t, err)
+
+	require.True(t, strings.HasPrefix(key, "usk_"))
+	require.True(t, strings.HasPrefix(key, prefix))
+	require.Less(t, len(prefix), len(key))
+	require.Equal(t, apikey.Hash(key), hash)
+	require.NotContains(t, hash, key)
+
+	other, _, otherHash, err := apikey.Generate()
+	require.NoError(t, err)
+	require.NotEqual(t, key, other)
+	require.NotEqual(t, hash, otherHash)
+}
+
+func TestHasScope(t *testing.T) {
+	cases := []struct {
+		name     string
+		scopes   []string
+		scope    string
+		expected bool
+	}{
+		{name: "Granted", scopes: []string{apikey.ScopeCreate}, scope: apikey.ScopeCreate, expected: true},
+		{name: "Missing", scopes: []string{apikey.ScopeCreate}, scope: apikey.ScopeDelete, expected: false},
+		{name: "Admin grants all", scopes: []string{apikey.ScopeAdmin}, scope: apikey.ScopeReadStats, expected: true},
+		{name: "Only admin grants admin", scopes: []string{apikey.ScopeCreate, apikey.ScopeDelete, apikey.ScopeReadStats}, scope: apikey.ScopeAdmin, expected: false},
+		{name: "No scopes", scopes: nil, scope: apikey.ScopeCreate, expected: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, apikey.HasScope(tc.scopes, tc.scope))
+		})
+	}
+}
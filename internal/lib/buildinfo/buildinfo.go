@@ -0,0 +1,61 @@
+package buildinfo
+
+import (
+	"runtime/debug"
+)
+
+// Set at build time:
+//
+//	go build -ldflags "-X URL-shortener/internal/lib/buildinfo.Version=v1.2.0 \
+//	    -X URL-shortener/internal/lib/buildinfo.Commit=$(git rev-parse HEAD) \
+//	    -X URL-shortener/internal/lib/buildinfo.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+//
+// When left empty they fall back to what the Go toolchain recorded in the binary.
+var (
+	Version string
+	Commit  string
+	Date    string
+)
+
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	Modified  bool   `json:"modified"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the build information of the running binary.
+func Get() Info {
+	info := Info{Version: "devel"}
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info.GoVersion = bi.GoVersion
+		if bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				info.Commit = s.Value
+			case "vcs.time":
+				info.Date = s.Value
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+
+	if Version != "" {
+		info.Version = Version
+	}
+	if Commit != "" {
+		info.Commit = Commit
+	}
+	if Date != "" {
+		info.Date = Date
+	}
+
+	return info
+}
@@ -0,0 +1,23 @@
+package buildinfo_test
+
+import (
+	"URL-shortener/internal/lib/buildinfo"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGet(t *testing.T) {
+	info := buildinfo.Get()
+	require.Equal(t, runtime.Version(), info.GoVersion)
+	require.NotEmpty(t, info.Version)
+
+	buildinfo.Version, buildinfo.Commit, buildinfo.Date = "v1.2.3", "abc123", "2024-01-02T03:04:05Z"
+	t.Cleanup(func() { buildinfo.Version, buildinfo.Commit, buildinfo.Date = "", "", "" })
+
+	info = buildinfo.Get()
+	require.Equal(t, "v1.2.3", info.Version)
+	require.Equal(t, "abc123", info.Commit)
+	require.Equal(t, "2024-01-02T03:04:05Z", info.Date)
+}
@@ -0,0 +1,70 @@
+package linkfile
+
+import (
+	"URL-shortener/internal/storage"
+	"context"
+	"fmt"
+	"time"
+)
+
+// exportPage is how many links are read from the storage at once.
+const exportPage = 500
+
+type Lister interface {
+	ListURLs(ctx context.Context, opts storage.ListOptions) ([]storage.Link, error)
+}
+
+// Export writes the links of owner, every link if owner is empty, to enc
+// oldest first. It reads them page by page, so memory use doesn't grow
+// with the number of links, and calls flushed after every page.
+// It returns how many links were written.
+func Export(ctx context.Context, lister Lister, enc Encoder, owner string, flushed func()) (int, error) {
+	const op = "linkfile.Export"
+
+	opts := storage.ListOptions{Limit: exportPage, Ascending: true, Owner: owner}
+	written := 0
+
+	for {
+		links, err := lister.ListURLs(ctx, opts)
+		if err != nil {
+			return written, fmt.Errorf("%s: list: %w", op, err)
+		}
+
+		for _, link := range links {
+			if err := enc.Encode(record(link)); err != nil {
+				return written, fmt.Errorf("%s: encode: %w", op, err)
+			}
+			written++
+		}
+
+		if err := enc.Flush(); err != nil {
+			return written, fmt.Errorf("%s: flush: %w", op, err)
+		}
+		if flushed != nil {
+			flushed()
+		}
+
+		if len(links) < opts.Limit {
+			return written, nil
+		}
+		opts.After = links[len(links)-1].ID
+	}
+}
+
+func record(link storage.Link) Record {
+	return Record{
+		Alias:     link.Alias,
+		URL:       link.URL,
+		ExpiresAt: timeOrNil(link.ExpiresAt),
+		Tags:      link.Tags,
+		Creator:   link.Owner,
+		CreatedAt: timeOrNil(link.CreatedAt),
+	}
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -0,0 +1,330 @@
+package linkfile
+
+import (
+	"URL-shortener/internal/http-server/handlers/url/save"
+	"URL-shortener/internal/lib/api/response"
+	"URL-shortener/internal/storage"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// What Import does with a row whose alias is taken.
+const (
+	ConflictSkip = "skip"
+	// ConflictOverwrite points the existing link to the row's URL,
+	// keeping its clicks, expiration and tags.
+	ConflictOverwrite = "overwrite"
+	// ConflictRename saves the row under a random alias instead.
+	ConflictRename = "rename"
+)
+
+// ErrUnknownConflict means the conflict strategy is not one of the above.
+var ErrUnknownConflict = errors.New("on_conflict must be skip, overwrite or rename")
+
+const (
+	// importChunk is how many rows are saved with one batch.
+	importChunk = 500
+	// maxReported limits the renames and errors listed in a Summary.
+	maxReported = 1000
+	// maxGenerateAttempts matches the single save, see handlers/url/save.
+	maxGenerateAttempts = 5
+)
+
+type Store interface {
+	SaveURLs(ctx context.Context, items []storage.BatchItem, atomic bool) ([]storage.BatchResult, error)
+	GetLink(ctx context.Context, alias string, owner string) (storage.Link, error)
+	UpdateURL(ctx context.Context, alias string, upd storage.URLUpdate) (int64, error)
+}
+
+type ImportOptions struct {
+	OnConflict string
+	// DryRun validates the rows and reports what would happen
+	// without saving anything.
+	DryRun bool
+	// Creator owns the imported links.
+	Creator string
+	// Owner limits overwrites to the links of this owner,
+	// empty allows every link.
+	Owner string
+	// Progress, if set, is called with the summary so far after every chunk.
+	Progress func(Summary)
+}
+
+// Summary reports what an import did, or would do on a dry run.
+type Summary struct {
+	DryRun  bool `json:"dry_run"`
+	Rows    int  `json:"rows"`
+	Created int  `json:"created"`
+	Updated int  `json:"updated"`
+	Renamed int  `json:"renamed"`
+	Skipped int  `json:"skipped"`
+	Failed  int  `json:"failed"`
+	// Renames and Errors list the first 1000 entries.
+	Renames []Rename   `json:"renames,omitempty"`
+	Errors  []RowError `json:"errors,omitempty"`
+}
+
+// Rename is a row saved under another alias than the one it asked for.
+type Rename struct {
+	Line int    `json:"line"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// RowError is why a row was not imported.
+type RowError struct {
+	Line    int    `json:"line"`
+	Alias   string `json:"alias,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type row struct {
+	line int
+	link save.Link
+}
+
+type importer struct {
+	store       Store
+	aliasPolicy save.AliasPolicy
+	opts        ImportOptions
+	summary     Summary
+	// seen holds the aliases of earlier rows on a dry run.
+	seen map[string]bool
+}
+
+// Import saves the rows of dec in chunks. Every row is validated like
+// a POST /url request, invalid rows are reported and skipped.
+// An error means the import stopped, the summary covers the rows
+// handled until then.
+func Import(ctx context.Context, store Store, dec Decoder, aliasPolicy save.AliasPolicy, opts ImportOptions) (Summary, error) {
+	const op = "linkfile.Import"
+
+	switch opts.OnConflict {
+	case ConflictSkip, ConflictOverwrite, ConflictRename:
+	default:
+		return Summary{}, fmt.Errorf("%s: %w", op, ErrUnknownConflict)
+	}
+
+	im := &importer{
+		store:       store,
+		aliasPolicy: aliasPolicy,
+		opts:        opts,
+		summary:     Summary{DryRun: opts.DryRun},
+		seen:        make(map[string]bool),
+	}
+
+	now := time.Now()
+	chunk := make([]row, 0, importChunk)
+
+	for {
+		req, line, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if errors.Is(err, ErrInvalidRow) {
+			im.summary.Rows++
+			im.fail(line, "", response.CodeBadRequest, err.Error())
+			continue
+		}
+		if err != nil {
+			return im.summary, fmt.Errorf("%s: decode line %d: %w", op, line, err)
+		}
+
+		im.summary.Rows++
+
+		link, resp, ok := save.Prepare(req, aliasPolicy, now)
+		if !ok {
+			im.fail(line, req.Alias, resp.Code, resp.Error)
+			continue
+		}
+		link.Opts.Owner = opts.Creator
+
+		chunk = append(chunk, row{line: line, link: link})
+		if len(chunk) == importChunk {
+			if err := im.flush(ctx, chunk); err != nil {
+				return im.summary, fmt.Errorf("%s: %w", op, err)
+			}
+			chunk = chunk[:0]
+		}
+	}
+
+	if err := im.flush(ctx, chunk); err != nil {
+		return im.summary, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return im.summary, nil
+}
+
+func (im *importer) flush(ctx context.Context, rows []row) error {
+	var err error
+	if im.opts.DryRun {
+		err = im.check(ctx, rows)
+	} else {
+		err = im.save(ctx, rows)
+	}
+	if err != nil {
+		return err
+	}
+
+	if im.opts.Progress != nil {
+		im.opts.Progress(im.summary)
+	}
+
+	return nil
+}
+
+// save saves rows with one batch, then retries the ones that need
+// a random alias until they get a free one.
+func (im *importer) save(ctx context.Context, rows []row) error {
+	items := make([]storage.BatchItem, len(rows))
+	// random marks items whose current alias was generated here.
+	random := make([]bool, len(rows))
+	pending := make([]int, len(rows))
+	for i, r := range rows {
+		items[i] = storage.BatchItem{URL: r.link.URL, Alias: r.link.Alias, Opts: r.link.Opts}
+		if items[i].Alias == "" {
+			alias, err := im.aliasPolicy.Generate()
+			if err != nil {
+				return fmt.Errorf("generate alias: %w", err)
+			}
+			items[i].Alias = alias
+			random[i] = true
+		}
+		pending[i] = i
+	}
+
+	for attempt := 1; len(pending) > 0; attempt++ {
+		batch := make([]storage.BatchItem, len(pending))
+		for j, i := range pending {
+			batch[j] = items[i]
+		}
+
+		results, err := im.store.SaveURLs(ctx, batch, false)
+		if err != nil {
+			return fmt.Errorf("save: %w", err)
+		}
+
+		var retry []int
+		for j, i := range pending {
+			r := rows[i]
+			switch err := results[j].Err; {
+			case err == nil && r.link.Alias != "" && items[i].Alias != r.link.Alias:
+				im.summary.Renamed++
+				if len(im.summary.Renames) < maxReported {
+					im.summary.Renames = append(im.summary.Renames, Rename{Line: r.line, From: r.link.Alias, To: items[i].Alias})
+				}
+			case err == nil:
+				im.summary.Created++
+			case !errors.Is(err, storage.ErrURLExists):
+				im.fail(r.line, r.link.Alias, response.CodeInternal, err.Error())
+			case random[i]:
+				im.aliasPolicy.Collided()
+				retry = append(retry, i)
+			case im.opts.OnConflict == ConflictOverwrite:
+				if err := im.overwrite(ctx, r); err != nil {
+					return err
+				}
+			case im.opts.OnConflict == ConflictRename:
+				retry = append(retry, i)
+			default:
+				im.summary.Skipped++
+			}
+		}
+
+		if attempt == maxGenerateAttempts {
+			for _, i := range retry {
+				im.fail(rows[i].line, rows[i].link.Alias, response.CodeInternal, "No free alias after several attempts")
+			}
+			return nil
+		}
+
+		for _, i := range retry {
+			alias, err := im.aliasPolicy.Generate()
+			if err != nil {
+				return fmt.Errorf("generate alias: %w", err)
+			}
+			items[i].Alias = alias
+			random[i] = true
+		}
+		pending = retry
+	}
+
+	return nil
+}
+
+func (im *importer) overwrite(ctx context.Context, r row) error {
+	// The row replaces the link as a whole, like a fresh save would.
+	_, err := im.store.UpdateURL(ctx, r.link.Alias, storage.URLUpdate{
+		URL:       r.link.URL,
+		Owner:     im.opts.Owner,
+		ChangedBy: im.opts.Creator,
+		SetMeta:   true,
+		ExpiresAt: r.link.Opts.ExpiresAt,
+		Tags:      r.link.Opts.Tags,
+	})
+	switch {
+	case errors.Is(err, storage.ErrNotOwner):
+		im.fail(r.line, r.link.Alias, response.CodeForbidden, "URL belongs to another owner")
+	case errors.Is(err, storage.ErrURLNotFound):
+		// Deleted since the batch found it taken.
+		im.fail(r.line, r.link.Alias, response.CodeNotFound, "URL not found")
+	case err != nil:
+		return fmt.Errorf("overwrite %s: %w", r.link.Alias, err)
+	default:
+		im.summary.Updated++
+	}
+
+	return nil
+}
+
+// check counts what save would do with rows without changing anything.
+func (im *importer) check(ctx context.Context, rows []row) error {
+	for _, r := range rows {
+		alias := r.link.Alias
+		if alias == "" {
+			im.summary.Created++
+			continue
+		}
+
+		taken, foreign := im.seen[alias], false
+		if !taken {
+			_, err := im.store.GetLink(ctx, alias, im.opts.Owner)
+			switch {
+			case errors.Is(err, storage.ErrURLNotFound):
+			case errors.Is(err, storage.ErrNotOwner):
+				taken, foreign = true, true
+			case err != nil:
+				return fmt.Errorf("check %s: %w", alias, err)
+			default:
+				taken = true
+			}
+		}
+		im.seen[alias] = true
+
+		switch {
+		case !taken:
+			im.summary.Created++
+		case im.opts.OnConflict == ConflictOverwrite && foreign:
+			im.fail(r.line, alias, response.CodeForbidden, "URL belongs to another owner")
+		case im.opts.OnConflict == ConflictOverwrite:
+			im.summary.Updated++
+		case im.opts.OnConflict == ConflictRename:
+			im.summary.Renamed++
+		default:
+			im.summary.Skipped++
+		}
+	}
+
+	return nil
+}
+
+func (im *importer) fail(line int, alias, code, msg string) {
+	im.summary.Failed++
+	if len(im.summary.Errors) < maxReported {
+		im.summary.Errors = append(im.summary.Errors, RowError{Line: line, Alias: alias, Code: code, Message: msg})
+	}
+}
@@ -0,0 +1,278 @@
+// Package linkfile reads and writes links as CSV or JSON Lines, and
+// moves them between such files and the storage.
+package linkfile
+
+import (
+	"URL-shortener/internal/http-server/handlers/url/save"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Formats of a link file.
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// ErrInvalidRow means a single row could not be decoded,
+// the rows after it can still be read.
+var ErrInvalidRow = errors.New("invalid row")
+
+// ErrInvalidFile means the file as a whole can't be read,
+// like a CSV file without a url column.
+var ErrInvalidFile = errors.New("invalid file")
+
+// ErrUnknownFormat means the format is neither csv nor jsonl.
+var ErrUnknownFormat = errors.New("format must be csv or jsonl")
+
+// Record is an exported link. Its JSON fields are those of save.Request,
+// so an export can be imported as is. Creator and CreatedAt are
+// informational and ignored on import.
+type Record struct {
+	Alias     string     `json:"alias"`
+	URL       string     `json:"url"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Tags      []string   `json:"tags,omitempty"`
+	Creator   string     `json:"creator,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+}
+
+// csvHeader are the columns of an exported CSV file. Imported files
+// need a header too, but only the url column is required.
+var csvHeader = []string{"alias", "url", "expires_at", "tags", "creator", "created_at"}
+
+// maxLineBytes limits a single JSON Lines row.
+const maxLineBytes = 1 << 20
+
+// FormatOf picks the format by the file extension, JSON Lines by default.
+func FormatOf(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return FormatCSV
+	}
+	return FormatJSONL
+}
+
+// ContentType returns the media type of a format.
+func ContentType(format string) string {
+	if format == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+type Encoder interface {
+	Encode(rec Record) error
+	// Flush writes any buffered rows.
+	Flush() error
+}
+
+// NewEncoder returns an encoder writing rows of format to w.
+func NewEncoder(w io.Writer, format string) (Encoder, error) {
+	switch format {
+	case FormatCSV:
+		return &csvEncoder{w: csv.NewWriter(w)}, nil
+	case FormatJSONL:
+		bw := bufio.NewWriter(w)
+		return &jsonlEncoder{w: bw, enc: json.NewEncoder(bw)}, nil
+	}
+
+	return nil, ErrUnknownFormat
+}
+
+type csvEncoder struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (e *csvEncoder) Encode(rec Record) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	return e.w.Write([]string{
+		rec.Alias,
+		rec.URL,
+		formatTime(rec.ExpiresAt),
+		strings.Join(rec.Tags, " "),
+		rec.Creator,
+		formatTime(rec.CreatedAt),
+	})
+}
+
+// Flush writes the header even without rows, so an empty export
+// is still a valid file.
+func (e *csvEncoder) Flush() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func (e *csvEncoder) writeHeader() error {
+	if e.headerWritten {
+		return nil
+	}
+	e.headerWritten = true
+
+	return e.w.Write(csvHeader)
+}
+
+type jsonlEncoder struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e *jsonlEncoder) Encode(rec Record) error {
+	return e.enc.Encode(rec)
+}
+
+func (e *jsonlEncoder) Flush() error {
+	return e.w.Flush()
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+type Decoder interface {
+	// Decode returns the next row and the line it starts on.
+	// Errors wrapping ErrInvalidRow only concern that row,
+	// io.EOF means there are no more rows.
+	Decode() (save.Request, int, error)
+}
+
+// NewDecoder returns a decoder reading rows of format from r.
+func NewDecoder(r io.Reader, format string) (Decoder, error) {
+	switch format {
+	case FormatCSV:
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		cr.ReuseRecord = true
+		return &csvDecoder{r: cr}, nil
+	case FormatJSONL:
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+		return &jsonlDecoder{sc: sc}, nil
+	}
+
+	return nil, ErrUnknownFormat
+}
+
+type csvDecoder struct {
+	r *csv.Reader
+	// columns maps the known column names to their positions.
+	columns map[string]int
+}
+
+func (d *csvDecoder) Decode() (save.Request, int, error) {
+	if d.columns == nil {
+		if err := d.readHeader(); err != nil {
+			return save.Request{}, 1, err
+		}
+	}
+
+	row, err := d.r.Read()
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return save.Request{}, parseErr.StartLine, fmt.Errorf("%w: %w", ErrInvalidRow, err)
+	}
+	if err != nil {
+		return save.Request{}, 0, err
+	}
+
+	line, _ := d.r.FieldPos(0)
+
+	field := func(name string) string {
+		i, ok := d.columns[name]
+		if !ok || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	req := save.Request{
+		URL:   field("url"),
+		Alias: field("alias"),
+		TTL:   field("ttl"),
+	}
+	if raw := field("expires_at"); raw != "" {
+		expiresAt, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return save.Request{}, line, fmt.Errorf("%w: expires_at must be an RFC 3339 time", ErrInvalidRow)
+		}
+		req.ExpiresAt = &expiresAt
+	}
+	if raw := field("tags"); raw != "" {
+		req.Tags = strings.Fields(raw)
+	}
+
+	return req, line, nil
+}
+
+func (d *csvDecoder) readHeader() error {
+	header, err := d.r.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return fmt.Errorf("%w: header: %w", ErrInvalidFile, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	d.columns = make(map[string]int, len(header))
+	for i, name := range header {
+		d.columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := d.columns["url"]; !ok {
+		return fmt.Errorf("%w: header has no url column", ErrInvalidFile)
+	}
+
+	return nil
+}
+
+type jsonlDecoder struct {
+	sc   *bufio.Scanner
+	line int
+}
+
+func (d *jsonlDecoder) Decode() (save.Request, int, error) {
+	for d.sc.Scan() {
+		d.line++
+
+		raw := d.sc.Bytes()
+		if len(bytes.TrimSpace(raw)) == 0 {
+			continue
+		}
+
+		var req save.Request
+		if err := json.Unmarshal(raw, &req); err != nil {
+			return save.Request{}, d.line, fmt.Errorf("%w: %w", ErrInvalidRow, err)
+		}
+
+		return req, d.line, nil
+	}
+
+	err := d.sc.Err()
+	if errors.Is(err, bufio.ErrTooLong) {
+		return save.Request{}, d.line + 1, fmt.Errorf("%w: line longer than %d bytes", ErrInvalidFile, maxLineBytes)
+	}
+	if err != nil {
+		return save.Request{}, d.line + 1, err
+	}
+
+	return save.Request{}, d.line, io.EOF
+}
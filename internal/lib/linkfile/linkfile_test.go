@@ -0,0 +1,275 @@
+package linkfile_test
+
+import (
+	"URL-shortener/internal/lib/aliaspolicy"
+	"URL-shortener/internal/lib/linkfile"
+	"URL-shortener/internal/lib/random"
+	"URL-shortener/internal/storage"
+	"URL-shortener/internal/storage/memory"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newAliasPolicy(t *testing.T) *aliaspolicy.Policy {
+	t.Helper()
+
+	policy, err := aliaspolicy.New(aliaspolicy.Options{
+		Length:             6,
+		MaxGeneratedLength: 16,
+		Alphabet:           random.Alphabet,
+		MinLength:          3,
+		MaxLength:          64,
+		Pattern:            "^[A-Za-z0-9_-]+$",
+		CaseSensitive:      true,
+	})
+	require.NoError(t, err)
+
+	return policy
+}
+
+func TestRoundTrip(t *testing.T) {
+	expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	records := []linkfile.Record{
+		{Alias: "google", URL: "https://google.com", Tags: []string{"promo", "search"}, Creator: "key:1", CreatedAt: &createdAt},
+		{Alias: "example", URL: "https://example.com/?a=1,b=2", ExpiresAt: &expiresAt},
+	}
+
+	for _, format := range []string{linkfile.FormatCSV, linkfile.FormatJSONL} {
+		format := format
+
+		t.Run(format, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			enc, err := linkfile.NewEncoder(&buf, format)
+			require.NoError(t, err)
+			for _, rec := range records {
+				require.NoError(t, enc.Encode(rec))
+			}
+			require.NoError(t, enc.Flush())
+
+			dec, err := linkfile.NewDecoder(&buf, format)
+			require.NoError(t, err)
+
+			for _, rec := range records {
+				req, _, err := dec.Decode()
+				require.NoError(t, err)
+				require.Equal(t, rec.Alias, req.Alias)
+				require.Equal(t, rec.URL, req.URL)
+				require.Equal(t, rec.Tags, req.Tags)
+				if rec.ExpiresAt == nil {
+					require.Nil(t, req.ExpiresAt)
+				} else {
+					require.True(t, rec.ExpiresAt.Equal(*req.ExpiresAt))
+				}
+			}
+
+			_, _, err = dec.Decode()
+			require.ErrorIs(t, err, io.EOF)
+		})
+	}
+}
+
+func TestDecoder_InvalidRows(t *testing.T) {
+	cases := []struct {
+		name   string
+		format string
+		input  string
+		// lines of the rows, 0 for an invalid one.
+		lines []int
+	}{
+		{
+			name:   "CSV",
+			format: linkfile.FormatCSV,
+			input:  "URL,Alias,TTL\nhttps://google.com,google,1h\nhttps://example.com,b\"ad\nhttps://example.org,,\n",
+			lines:  []int{2, 0, 4},
+		},
+		{
+			name:   "CSV bad expiry",
+			format: linkfile.FormatCSV,
+			input:  "url,expires_at\nhttps://google.com,tomorrow\nhttps://example.com,\n",
+			lines:  []int{0, 3},
+		},
+		{
+			name:   "JSON Lines",
+			format: linkfile.FormatJSONL,
+			input:  "{\"url\": \"https://google.com\"}\n\n{\"url\": \n{\"url\": \"https://example.com\"}\n",
+			lines:  []int{1, 0, 4},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			dec, err := linkfile.NewDecoder(strings.NewReader(tc.input), tc.format)
+			require.NoError(t, err)
+
+			for _, want := range tc.lines {
+				req, line, err := dec.Decode()
+				if want == 0 {
+					require.ErrorIs(t, err, linkfile.ErrInvalidRow)
+					continue
+				}
+				require.NoError(t, err)
+				require.Equal(t, want, line)
+				require.NotEmpty(t, req.URL)
+			}
+
+			_, _, err = dec.Decode()
+			require.ErrorIs(t, err, io.EOF)
+		})
+	}
+}
+
+func TestDecoder_NoURLColumn(t *testing.T) {
+	dec, err := linkfile.NewDecoder(strings.NewReader("alias,link\ngoogle,https://google.com\n"), linkfile.FormatCSV)
+	require.NoError(t, err)
+
+	_, _, err = dec.Decode()
+	require.ErrorIs(t, err, linkfile.ErrInvalidFile)
+	require.False(t, errors.Is(err, linkfile.ErrInvalidRow))
+}
+
+func TestImport(t *testing.T) {
+	const input = `{"url": "https://google.com", "alias": "google"}
+{"url": "https://example.com", "alias": "taken"}
+{"url": "not a url"}
+{"url": "https://example.org"}
+{"url": "https://example.net", "alias": "google"}
+`
+
+	cases := []struct {
+		name       string
+		onConflict string
+		dryRun     bool
+		expected   linkfile.Summary
+		// takenURL is where the taken alias points afterwards.
+		takenURL string
+	}{
+		{
+			name:       "Skip",
+			onConflict: linkfile.ConflictSkip,
+			expected:   linkfile.Summary{Rows: 5, Created: 2, Skipped: 2, Failed: 1},
+			takenURL:   "https://old.example.com",
+		},
+		{
+			name:       "Overwrite",
+			onConflict: linkfile.ConflictOverwrite,
+			expected:   linkfile.Summary{Rows: 5, Created: 2, Updated: 2, Failed: 1},
+			takenURL:   "https://example.com",
+		},
+		{
+			name:       "Rename",
+			onConflict: linkfile.ConflictRename,
+			expected:   linkfile.Summary{Rows: 5, Created: 2, Renamed: 2, Failed: 1},
+			takenURL:   "https://old.example.com",
+		},
+		{
+			name:       "Dry run",
+			onConflict: linkfile.ConflictOverwrite,
+			dryRun:     true,
+			expected:   linkfile.Summary{DryRun: true, Rows: 5, Created: 2, Updated: 2, Failed: 1},
+			takenURL:   "https://old.example.com",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+
+			st, err := memory.New("")
+			require.NoError(t, err)
+			_, err = st.SaveURL(ctx, "https://old.example.com", "taken", storage.SaveOptions{})
+			require.NoError(t, err)
+
+			dec, err := linkfile.NewDecoder(strings.NewReader(input), linkfile.FormatJSONL)
+			require.NoError(t, err)
+
+			progressed := false
+			summary, err := linkfile.Import(ctx, st, dec, newAliasPolicy(t), linkfile.ImportOptions{
+				OnConflict: tc.onConflict,
+				DryRun:     tc.dryRun,
+				Creator:    "key:1",
+				Progress:   func(linkfile.Summary) { progressed = true },
+			})
+			require.NoError(t, err)
+			require.True(t, progressed)
+
+			require.Len(t, summary.Errors, 1)
+			require.Equal(t, 3, summary.Errors[0].Line)
+			require.Equal(t, "validation_failed", summary.Errors[0].Code)
+
+			if !tc.dryRun && tc.onConflict == linkfile.ConflictRename {
+				require.Len(t, summary.Renames, 2)
+				require.Equal(t, linkfile.Rename{Line: 2, From: "taken", To: summary.Renames[0].To}, summary.Renames[0])
+				require.Equal(t, 5, summary.Renames[1].Line)
+			}
+
+			summary.Errors, summary.Renames = nil, nil
+			require.Equal(t, tc.expected, summary)
+
+			got, err := st.GetURL(ctx, "taken")
+			require.NoError(t, err)
+			require.Equal(t, tc.takenURL, got)
+
+			links, err := st.ListURLs(ctx, storage.ListOptions{Limit: 10})
+			require.NoError(t, err)
+			if tc.dryRun {
+				require.Len(t, links, 1)
+			} else {
+				require.Len(t, links, 1+tc.expected.Created+tc.expected.Renamed)
+			}
+		})
+	}
+}
+
+func TestImport_UnknownConflict(t *testing.T) {
+	dec, err := linkfile.NewDecoder(strings.NewReader(""), linkfile.FormatJSONL)
+	require.NoError(t, err)
+
+	_, err = linkfile.Import(context.Background(), nil, dec, newAliasPolicy(t), linkfile.ImportOptions{OnConflict: "merge"})
+	require.ErrorIs(t, err, linkfile.ErrUnknownConflict)
+}
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+
+	st, err := memory.New("")
+	require.NoError(t, err)
+	for _, alias := range []string{"first", "second", "third"} {
+		_, err := st.SaveURL(ctx, "https://"+alias+".example.com", alias, storage.SaveOptions{Owner: "key:1", Tags: []string{"promo"}})
+		require.NoError(t, err)
+	}
+	_, err = st.SaveURL(ctx, "https://other.example.com", "other", storage.SaveOptions{Owner: "key:2"})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	enc, err := linkfile.NewEncoder(&buf, linkfile.FormatCSV)
+	require.NoError(t, err)
+
+	written, err := linkfile.Export(ctx, st, enc, "key:1", nil)
+	require.NoError(t, err)
+	require.Equal(t, 3, written)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+	require.Equal(t, "alias,url,expires_at,tags,creator,created_at", lines[0])
+	require.True(t, strings.HasPrefix(lines[1], "first,https://first.example.com,,promo,key:1,"))
+	require.True(t, strings.HasPrefix(lines[3], "third,"))
+}
@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"URL-shortener/internal/storage/cache"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "url_shortener"
+
+// Metrics owns the Prometheus registry of the service.
+// Every metric is registered on it rather than on the global default,
+// so tests can create as many instances as they like.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requestDuration *prometheus.HistogramVec
+	responseSize    *prometheus.HistogramVec
+	operations      *prometheus.CounterVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Duration of HTTP requests by route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_response_size_bytes",
+			Help:      "Size of HTTP response bodies by route pattern.",
+			Buckets:   prometheus.ExponentialBuckets(64, 4, 6),
+		}, []string{"method", "route"}),
+		operations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "operations_total",
+			Help:      "Saves, batch saves, imports, redirects, updates and deletes by outcome.",
+		}, []string{"operation", "outcome"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requestDuration,
+		m.responseSize,
+		m.operations,
+	)
+
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveRequest records a completed HTTP request.
+// route must be a route pattern, raw paths would blow up the label cardinality.
+func (m *Metrics) ObserveRequest(method, route string, status, bytes int, duration time.Duration) {
+	m.requestDuration.WithLabelValues(method, route, strconv.Itoa(status)).Observe(duration.Seconds())
+	m.responseSize.WithLabelValues(method, route).Observe(float64(bytes))
+}
+
+// CountOperation records the outcome of a save, redirect, update or delete.
+func (m *Metrics) CountOperation(operation, outcome string) {
+	m.operations.WithLabelValues(operation, outcome).Inc()
+}
+
+// RegisterDB exports the connection pool stats of db.
+func (m *Metrics) RegisterDB(db *sql.DB, name string) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// RegisterCache exports the counters of the redirect cache.
+func (m *Metrics) RegisterCache(c *cache.Cache) {
+	counter := func(name, help string, value func(cache.Stats) uint64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return float64(value(c.Stats())) })
+	}
+
+	m.registry.MustRegister(
+		counter("hits_total", "Lookups answered from the cache.", func(s cache.Stats) uint64 { return s.Hits }),
+		counter("negative_hits_total", "Lookups answered from cached misses.", func(s cache.Stats) uint64 { return s.NegativeHits }),
+		counter("misses_total", "Lookups that went to the storage.", func(s cache.Stats) uint64 { return s.Misses }),
+		counter("evictions_total", "Entries evicted to make room for new ones.", func(s cache.Stats) uint64 { return s.Evictions }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "cache",
+			Name:      "entries",
+			Help:      "Entries currently in the cache.",
+		}, func() float64 { return float64(c.Stats().Size) }),
+	)
+}
+
+// RegisterDroppedClicks exports how many clicks were lost to a full buffer.
+func (m *Metrics) RegisterDroppedClicks(dropped func() uint64) {
+	m.registry.MustRegister(prometheus.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "clicks_dropped_total",
+		Help:      "Clicks not recorded because the buffer was full.",
+	}, func() float64 { return float64(dropped()) }))
+}
@@ -0,0 +1,46 @@
+package metrics_test
+
+import (
+	"URL-shortener/internal/lib/metrics"
+	"URL-shortener/internal/storage/cache"
+	"URL-shortener/internal/storage/memory"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	m := metrics.New()
+
+	st, err := memory.New("")
+	require.NoError(t, err)
+	c := cache.New(st, 10, time.Minute, time.Minute)
+	_, _ = c.GetURL(context.Background(), "missing")
+
+	m.RegisterCache(c)
+	m.RegisterDroppedClicks(func() uint64 { return 3 })
+	m.ObserveRequest(http.MethodGet, "/{alias}", http.StatusFound, 0, 5*time.Millisecond)
+	m.CountOperation("redirect", "success")
+
+	rr := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	body, err := io.ReadAll(rr.Body)
+	require.NoError(t, err)
+
+	for _, line := range []string{
+		`url_shortener_http_request_duration_seconds_count{method="GET",route="/{alias}",status="302"} 1`,
+		`url_shortener_operations_total{operation="redirect",outcome="success"} 1`,
+		`url_shortener_cache_misses_total 1`,
+		`url_shortener_cache_entries 1`,
+		`url_shortener_clicks_dropped_total 3`,
+	} {
+		require.Contains(t, string(body), line)
+	}
+}
@@ -1,21 +1,120 @@
 package random
 
 import (
-	"math/rand"
-	"time"
+	"crypto/rand"
+	"sync"
 )
 
+// Alphabet is the default set of characters random strings are built from.
+const Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
+	"abcdefghijklmnopqrstuvwxyz" +
+	"0123456789"
+
+// Collision statistics are evaluated once per window of generated aliases.
+// If more than collisionThreshold of them collided, the length grows by one.
+const (
+	collisionWindow    = 100
+	collisionThreshold = 0.1
+)
+
+// NewRandomString returns a random string of letters and digits
+// read from crypto/rand.
 func NewRandomString(size int) string {
-	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
+	return String(size, Alphabet)
+}
 
-	chars := []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZ" +
-		"abcdefghijklmnopqrstuvwxyz" +
-		"0123456789")
+// String returns a random string of the given size built from alphabet.
+// Characters are picked with rejection sampling so every one is equally likely.
+// An alphabet of more than 256 characters yields an empty string.
+func String(size int, alphabet string) string {
+	chars := []rune(alphabet)
+	if size <= 0 || len(chars) == 0 || len(chars) > 256 {
+		return ""
+	}
+
+	// Largest multiple of len(chars) that fits in a byte,
+	// bytes above it would bias the result towards the first characters.
+	limit := 256 - 256%len(chars)
 
-	b := make([]rune, size)
-	for i := range b {
-		b[i] = chars[rnd.Intn(len(chars))]
+	b := make([]rune, 0, size)
+	buf := make([]byte, size)
+	for len(b) < size {
+		// crypto/rand.Read never returns an error since Go 1.24.
+		_, _ = rand.Read(buf)
+
+		for _, v := range buf {
+			if int(v) >= limit {
+				continue
+			}
+			b = append(b, chars[int(v)%len(chars)])
+			if len(b) == size {
+				break
+			}
+		}
 	}
 
 	return string(b)
 }
+
+// Generator produces random aliases. It is safe for concurrent use.
+// Callers report collisions via Collided, and when they become frequent
+// the alias length grows, up to maxLength.
+type Generator struct {
+	alphabet string
+
+	mu         sync.Mutex
+	length     int
+	maxLength  int
+	generated  int
+	collisions int
+}
+
+func NewGenerator(alphabet string, length, maxLength int) *Generator {
+	if maxLength < length {
+		maxLength = length
+	}
+
+	return &Generator{alphabet: alphabet, length: length, maxLength: maxLength}
+}
+
+func (g *Generator) Generate() string {
+	g.mu.Lock()
+	g.rollWindow()
+	length := g.length
+	g.generated++
+	g.mu.Unlock()
+
+	return String(length, g.alphabet)
+}
+
+// Collided records that a generated alias was already taken.
+func (g *Generator) Collided() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.collisions++
+	g.rollWindow()
+}
+
+// rollWindow grows the length if the current window had too many collisions
+// and starts a new window. g.mu must be held.
+func (g *Generator) rollWindow() {
+	if g.generated < collisionWindow {
+		return
+	}
+
+	if float64(g.collisions)/float64(g.generated) > collisionThreshold && g.length < g.maxLength {
+		g.length++
+	}
+
+	g.generated = 0
+	g.collisions = 0
+}
+
+// Length returns the current length of generated aliases.
+func (g *Generator) Length() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.length
+}
@@ -0,0 +1,71 @@
+package random
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRandomString(t *testing.T) {
+	for _, size := range []int{1, 6, 32} {
+		s := NewRandomString(size)
+		require.Len(t, s, size)
+
+		for _, c := range s {
+			require.True(t, strings.ContainsRune(Alphabet, c), "unexpected char %q", c)
+		}
+	}
+
+	require.Empty(t, NewRandomString(0))
+}
+
+func TestNewRandomString_Concurrent(t *testing.T) {
+	const n = 1000
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[string]struct{}, n)
+		wg   sync.WaitGroup
+	)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			s := NewRandomString(16)
+
+			mu.Lock()
+			seen[s] = struct{}{}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, seen, n)
+}
+
+func TestGenerator_GrowsOnCollisions(t *testing.T) {
+	g := NewGenerator(Alphabet, 4, 5)
+
+	for i := 0; i < 3*collisionWindow; i++ {
+		require.LessOrEqual(t, len(g.Generate()), 5)
+		g.Collided()
+	}
+
+	require.Equal(t, 5, g.Length())
+}
+
+func TestGenerator_KeepsLengthWithoutCollisions(t *testing.T) {
+	g := NewGenerator("abc", 6, 16)
+
+	for i := 0; i < 3*collisionWindow; i++ {
+		a := g.Generate()
+		require.Len(t, a, 6)
+		require.Empty(t, strings.Trim(a, "abc"))
+	}
+
+	require.Equal(t, 6, g.Length())
+}
@@ -0,0 +1,50 @@
+package tags
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// MaxPerLink limits how many tags a single link may carry.
+const MaxPerLink = 10
+
+var (
+	ErrTooMany = fmt.Errorf("at most %d tags are allowed", MaxPerLink)
+	ErrInvalid = errors.New("tags must be 1-32 lower-case letters, digits, '-' or '_'")
+)
+
+// pattern keeps tags free of the separator storage joins them with.
+var pattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// Normalize returns the tag in the form it is stored in.
+func Normalize(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if !pattern.MatchString(tag) {
+		return "", ErrInvalid
+	}
+	return tag, nil
+}
+
+// NormalizeAll normalizes tags, dropping duplicates and sorting them.
+func NormalizeAll(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag, err := Normalize(tag)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, tag)
+	}
+
+	slices.Sort(normalized)
+	normalized = slices.Compact(normalized)
+
+	if len(normalized) > MaxPerLink {
+		return nil, ErrTooMany
+	}
+
+	return normalized, nil
+}
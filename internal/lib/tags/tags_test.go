@@ -0,0 +1,47 @@
+package tags_test
+
+import (
+	"URL-shortener/internal/lib/tags"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeAll(t *testing.T) {
+	cases := []struct {
+		name     string
+		tags     []string
+		expected []string
+		err      error
+	}{
+		{name: "Empty", tags: nil, expected: []string{}},
+		{name: "Sorted", tags: []string{"promo", "docs"}, expected: []string{"docs", "promo"}},
+		{name: "Case and spaces", tags: []string{" Promo ", "DOCS"}, expected: []string{"docs", "promo"}},
+		{name: "Duplicates", tags: []string{"promo", "Promo", "promo"}, expected: []string{"promo"}},
+		{name: "Separators", tags: []string{"q3-2026", "team_a"}, expected: []string{"q3-2026", "team_a"}},
+		{name: "Blank", tags: []string{" "}, err: tags.ErrInvalid},
+		{name: "Comma", tags: []string{"a,b"}, err: tags.ErrInvalid},
+		{name: "Leading dash", tags: []string{"-a"}, err: tags.ErrInvalid},
+		{name: "Too long", tags: []string{strings.Repeat("a", 33)}, err: tags.ErrInvalid},
+		{name: "Too many", tags: strings.Split("a,b,c,d,e,f,g,h,i,j,k", ","), err: tags.ErrTooMany},
+		{name: "Duplicates within limit", tags: strings.Split("a,a,b,c,d,e,f,g,h,i,j", ","), expected: strings.Split("a,b,c,d,e,f,g,h,i,j", ",")},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			normalized, err := tags.NormalizeAll(tc.tags)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, normalized)
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterNone   = "none"
+)
+
+type Options struct {
+	// Exporter is one of ExporterOTLP, ExporterStdout or ExporterNone.
+	Exporter    string
+	ServiceName string
+	// SampleRatio is the share of new traces that are recorded.
+	// Requests continuing a trace follow the decision of the caller.
+	SampleRatio float64
+	// OTLPEndpoint is the host:port of the OTLP/HTTP collector.
+	// Empty means the OTEL_EXPORTER_OTLP_* environment or its default.
+	OTLPEndpoint string
+	OTLPInsecure bool
+}
+
+// Setup installs the global tracer provider and the W3C trace context
+// propagator. The returned provider must be shut down to flush pending spans.
+func Setup(ctx context.Context, opts Options) (*sdktrace.TracerProvider, error) {
+	const op = "lib.telemetry.Setup"
+
+	var exporter sdktrace.SpanExporter
+	switch opts.Exporter {
+	case ExporterOTLP:
+		var httpOpts []otlptracehttp.Option
+		if opts.OTLPEndpoint != "" {
+			httpOpts = append(httpOpts, otlptracehttp.WithEndpoint(opts.OTLPEndpoint))
+		}
+		if opts.OTLPInsecure {
+			httpOpts = append(httpOpts, otlptracehttp.WithInsecure())
+		}
+
+		exp, err := otlptracehttp.New(ctx, httpOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: otlp exporter: %w", op, err)
+		}
+		exporter = exp
+	case ExporterStdout:
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		if err != nil {
+			return nil, fmt.Errorf("%s: stdout exporter: %w", op, err)
+		}
+		exporter = exp
+	case ExporterNone, "":
+		// Spans are still created so that logs carry trace IDs.
+	default:
+		return nil, fmt.Errorf("%s: unknown exporter %q", op, opts.Exporter)
+	}
+
+	tp := NewTracerProvider(exporter, opts)
+
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(Propagator())
+
+	return tp, nil
+}
+
+// NewTracerProvider returns a provider that batches spans to exporter,
+// which may be nil to drop them. Tests pass an in-memory exporter.
+func NewTracerProvider(exporter sdktrace.SpanExporter, opts Options) *sdktrace.TracerProvider {
+	providerOpts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(opts.ServiceName))),
+	}
+	if exporter != nil {
+		providerOpts = append(providerOpts, sdktrace.WithBatcher(exporter))
+	}
+
+	return sdktrace.NewTracerProvider(providerOpts...)
+}
+
+// Propagator reads and writes W3C traceparent, tracestate and baggage headers.
+func Propagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
+
+// TraceID returns the ID of the trace ctx belongs to, or "" outside of a trace.
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+
+	return sc.TraceID().String()
+}
@@ -0,0 +1,287 @@
+package storage
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+	DriverMemory   = "memory"
+)
+
+// Backend is the set of operations every storage driver has to provide.
+// It covers the URLSaver, URLGetter and URLDeleter handler interfaces.
+//
+// Operations taking an owner only apply to links created by that owner
+// and fail with ErrNotOwner otherwise. An empty owner matches every link.
+type Backend interface {
+	SaveURL(ctx context.Context, urlToSave string, alias string, opts SaveOptions) (int64, error)
+	SaveURLs(ctx context.Context, items []BatchItem, atomic bool) ([]BatchResult, error)
+	GetURL(ctx context.Context, alias string) (string, error)
+	// ResolveURL is GetURL that also returns when the link expires,
+	// zero if never.
+	ResolveURL(ctx context.Context, alias string) (string, time.Time, error)
+	GetLink(ctx context.Context, alias string, owner string) (Link, error)
+	FindURL(ctx context.Context, owner string, rawURL string) (Link, error)
+	ListURLs(ctx context.Context, opts ListOptions) ([]Link, error)
+	UpdateURL(ctx context.Context, alias string, upd URLUpdate) (int64, error)
+	DeleteURL(ctx context.Context, alias string, owner string) error
+	PurgeExpired(ctx context.Context, limit int) (int64, error)
+	SaveClicks(ctx context.Context, clicks []Click) error
+	URLStats(ctx context.Context, alias string, owner string, since time.Time, topReferrers int) (Stats, error)
+	URLHistory(ctx context.Context, alias string, owner string) ([]Revision, error)
+
+	SaveAPIKey(ctx context.Context, key APIKey) (int64, error)
+	GetAPIKey(ctx context.Context, hash string) (APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64) error
+	TouchAPIKey(ctx context.Context, id int64, usedAt time.Time) error
+
+	ReserveIdempotencyKey(ctx context.Context, rec IdempotencyRecord) (IdempotencyRecord, error)
+	CompleteIdempotencyKey(ctx context.Context, rec IdempotencyRecord) error
+	ReleaseIdempotencyKey(ctx context.Context, owner string, key string) error
+	PurgeIdempotencyKeys(ctx context.Context, limit int) (int64, error)
+}
+
+// SaveOptions holds the optional attributes of a new link.
+type SaveOptions struct {
+	// ExpiresAt is when the link stops working, zero means never.
+	ExpiresAt time.Time
+	// Owner is who created the link.
+	Owner string
+	// Tags label the link for listing, see ListOptions.Tag.
+	Tags []string
+}
+
+// BatchItem is a link to create with SaveURLs.
+type BatchItem struct {
+	URL   string
+	Alias string
+	Opts  SaveOptions
+}
+
+// BatchResult is the outcome of the BatchItem at the same position.
+// Err is ErrURLExists for an alias that is taken, also by an earlier
+// item of the batch, and ErrBatchAborted for items of an all-or-nothing
+// batch that were rolled back because another item failed.
+type BatchResult struct {
+	ID  int64
+	Err error
+}
+
+// AbortBatch marks the saved items of a failed all-or-nothing batch
+// as rolled back.
+func AbortBatch(results []BatchResult) {
+	for i := range results {
+		if results[i].Err == nil {
+			results[i] = BatchResult{Err: ErrBatchAborted}
+		}
+	}
+}
+
+// Link is a stored link with its metadata.
+type Link struct {
+	ID    int64
+	Alias string
+	URL   string
+	// Owner is who created the link.
+	Owner   string
+	Version int64
+	// CreatedAt and UpdatedAt are zero for links created
+	// before the timestamps were recorded.
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// ExpiresAt is zero for links that never expire.
+	ExpiresAt time.Time
+	Clicks    int64
+	// Tags are sorted.
+	Tags []string
+}
+
+// Expired reports whether the link stopped working by now.
+func (l Link) Expired(now time.Time) bool {
+	return !l.ExpiresAt.IsZero() && !l.ExpiresAt.After(now)
+}
+
+// ListOptions selects a page of links. Empty filters match every link.
+type ListOptions struct {
+	// After is the id of the last link of the previous page,
+	// zero starts from the beginning.
+	After int64
+	Limit int
+	// Ascending lists the oldest links first, the newest come first otherwise.
+	Ascending bool
+
+	Owner         string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// Domain matches the host of the destination, see Domain.
+	Domain      string
+	AliasPrefix string
+	Tag         string
+	// Query matches destinations containing it, ignoring case.
+	Query string
+}
+
+// Domain returns the lower-cased host of a destination URL,
+// the value ListOptions.Domain is compared with.
+func Domain(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// NormalizeURL returns the form FindURL compares destinations in:
+// the scheme and host are lower-cased, the default port of the scheme
+// is dropped and an empty path becomes "/".
+func NormalizeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if u.Scheme == "http" && port == "80" || u.Scheme == "https" && port == "443" {
+		port = ""
+	}
+	switch {
+	case port != "":
+		u.Host = net.JoinHostPort(host, port)
+	case strings.Contains(host, ":"):
+		u.Host = "[" + host + "]"
+	default:
+		u.Host = host
+	}
+
+	if u.Path == "" {
+		u.Path = "/"
+	}
+
+	return u.String()
+}
+
+// URLUpdate points an existing link to a new destination.
+// The previous destination is kept in the link history.
+type URLUpdate struct {
+	URL string
+	// Version is the link version the change is based on. The update
+	// fails with ErrVersionMismatch if the link has changed since,
+	// zero skips the check.
+	Version int64
+	// Owner limits the update to links of that owner.
+	Owner string
+	// ChangedBy is recorded in the history.
+	ChangedBy string
+	// SetMeta replaces the expiry and tags of the link with ExpiresAt
+	// and Tags, zero values included. Without it they are left as is.
+	SetMeta   bool
+	ExpiresAt time.Time
+	Tags      []string
+}
+
+// Revision is a previous destination of a link, saved by UpdateURL.
+type Revision struct {
+	URL string
+	// Version is the link version that pointed to URL.
+	Version   int64
+	ChangedBy string
+	ChangedAt time.Time
+}
+
+// Click is a single successful redirect.
+type Click struct {
+	Alias     string
+	ClickedAt time.Time
+	Referrer  string
+	UserAgent string
+	RequestID string
+}
+
+type Stats struct {
+	TotalClicks  int64
+	ClicksPerDay []DailyClicks
+	TopReferrers []ReferrerClicks
+}
+
+type DailyClicks struct {
+	// Day is a UTC date in the YYYY-MM-DD format.
+	Day    string
+	Clicks int64
+}
+
+type ReferrerClicks struct {
+	Referrer string
+	Clicks   int64
+}
+
+// APIKey is a credential for the API. Only the hash of the key is stored,
+// the key itself is shown once when it is created.
+type APIKey struct {
+	ID   int64
+	Name string
+	// Prefix is the start of the key, to tell keys apart in listings.
+	Prefix    string
+	Hash      string
+	Scopes    []string
+	CreatedAt time.Time
+	// LastUsedAt is zero for keys that were never used.
+	LastUsedAt time.Time
+	// RevokedAt is zero for active keys.
+	RevokedAt time.Time
+}
+
+var _ Backend = (*Storage)(nil)
+
+// SplitTags decodes comma-joined tags and sorts them.
+func SplitTags(tags string) []string {
+	if tags == "" {
+		return []string{}
+	}
+
+	split := strings.Split(tags, ",")
+	slices.Sort(split)
+
+	return split
+}
+
+// JoinScopes encodes scopes for a single text column.
+func JoinScopes(scopes []string) string {
+	return strings.Join(scopes, ",")
+}
+
+// SplitScopes decodes scopes stored with JoinScopes.
+func SplitScopes(scopes string) []string {
+	if scopes == "" {
+		return []string{}
+	}
+
+	return strings.Split(scopes, ",")
+}
+
+// IdempotencyRecord is the response to a request sent with an
+// Idempotency-Key header, kept so that retries get the same response.
+type IdempotencyRecord struct {
+	// Owner and Key identify the record, keys of different owners
+	// never clash.
+	Owner string
+	Key   string
+	// RequestHash fingerprints the request, a key can't be reused
+	// for a different one.
+	RequestHash string
+	// Status is zero while the first request is in flight.
+	Status  int
+	Headers map[string]string
+	Body    []byte
+	// ExpiresAt is when the key may be used again.
+	ExpiresAt time.Time
+}
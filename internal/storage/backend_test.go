@@ -0,0 +1,34 @@
+package storage_test
+
+import (
+	"URL-shortener/internal/storage"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeURL(t *testing.T) {
+	cases := []struct {
+		url      string
+		expected string
+	}{
+		{url: "https://google.com", expected: "https://google.com/"},
+		{url: "HTTPS://Google.COM/Search?q=Go", expected: "https://google.com/Search?q=Go"},
+		{url: "https://google.com:443/", expected: "https://google.com/"},
+		{url: "http://google.com:80", expected: "http://google.com/"},
+		{url: "http://google.com:8080", expected: "http://google.com:8080/"},
+		{url: "https://google.com:80", expected: "https://google.com:80/"},
+		{url: "http://[::1]:80/a", expected: "http://[::1]/a"},
+		{url: "not a url", expected: "not a url"},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+
+		t.Run(tc.url, func(t *testing.T) {
+			t.Parallel()
+
+			require.Equal(t, tc.expected, storage.NormalizeURL(tc.url))
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package cache
+
+import (
+	"URL-shortener/internal/storage"
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Cache is a read-through cache for storage.Backend lookups.
+// It keeps up to size aliases in an LRU, found URLs for ttl or until
+// the link expires, whichever comes first, and missing aliases for
+// negativeTTL. Concurrent misses on the same alias share a single
+// backend call. Writes go straight to the backend and drop the cached
+// entry, so an instance never serves its own stale data; changes made
+// by other replicas become visible after at most ttl.
+type Cache struct {
+	storage.Backend
+
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	// gen changes on every invalidation, a load that started
+	// before it must not put its result into the cache.
+	gen uint64
+
+	group singleflight.Group
+
+	hits         atomic.Uint64
+	negativeHits atomic.Uint64
+	misses       atomic.Uint64
+	evictions    atomic.Uint64
+}
+
+type entry struct {
+	alias     string
+	url       string
+	err       error
+	expiresAt time.Time
+}
+
+// Stats is a snapshot of the cache counters.
+type Stats struct {
+	Hits         uint64
+	NegativeHits uint64
+	Misses       uint64
+	Evictions    uint64
+	Size         int
+}
+
+func New(backend storage.Backend, size int, ttl, negativeTTL time.Duration) *Cache {
+	return &Cache{
+		Backend:     backend,
+		size:        size,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		entries:     make(map[string]*list.Element, size),
+		lru:         list.New(),
+	}
+}
+
+func (c *Cache) GetURL(ctx context.Context, alias string) (string, error) {
+	if e, ok := c.get(alias); ok {
+		if e.err != nil {
+			c.negativeHits.Add(1)
+		} else {
+			c.hits.Add(1)
+		}
+
+		return e.url, e.err
+	}
+
+	c.misses.Add(1)
+
+	// The load is shared between callers, so it must not be cut short
+	// when the one that started it goes away. Every caller still
+	// stops waiting when its own context is done.
+	ch := c.group.DoChan(alias, func() (any, error) {
+		// Another load may have finished between the lookup above and now.
+		if e, ok := c.get(alias); ok {
+			return e.url, e.err
+		}
+
+		gen := c.generation()
+
+		url, expiresAt, err := c.Backend.ResolveURL(context.WithoutCancel(ctx), alias)
+
+		switch {
+		case err == nil:
+			ttl := c.ttl
+			if left := time.Until(expiresAt); !expiresAt.IsZero() && left < ttl {
+				ttl = left
+			}
+			c.set(gen, alias, url, nil, ttl)
+		case errors.Is(err, storage.ErrURLNotFound), errors.Is(err, storage.ErrURLExpired):
+			c.set(gen, alias, "", err, c.negativeTTL)
+		}
+
+		return url, err
+	})
+
+	select {
+	case res := <-ch:
+		return res.Val.(string), res.Err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func (c *Cache) SaveURL(ctx context.Context, urlToSave, alias string, opts storage.SaveOptions) (int64, error) {
+	id, err := c.Backend.SaveURL(ctx, urlToSave, alias, opts)
+	if err == nil {
+		c.Invalidate(alias)
+	}
+
+	return id, err
+}
+
+func (c *Cache) SaveURLs(ctx context.Context, items []storage.BatchItem, atomic bool) ([]storage.BatchResult, error) {
+	results, err := c.Backend.SaveURLs(ctx, items, atomic)
+	for i, result := range results {
+		if result.Err == nil {
+			c.Invalidate(items[i].Alias)
+		}
+	}
+
+	return results, err
+}
+
+func (c *Cache) UpdateURL(ctx context.Context, alias string, upd storage.URLUpdate) (int64, error) {
+	version, err := c.Backend.UpdateURL(ctx, alias, upd)
+	if err == nil {
+		c.Invalidate(alias)
+	}
+
+	return version, err
+}
+
+func (c *Cache) DeleteURL(ctx context.Context, alias string, owner string) error {
+	err := c.Backend.DeleteURL(ctx, alias, owner)
+	if err == nil {
+		c.Invalidate(alias)
+	}
+
+	return err
+}
+
+// Invalidate drops the cached entry for alias, if any.
+func (c *Cache) Invalidate(alias string) {
+	c.group.Forget(alias)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	if el, ok := c.entries[alias]; ok {
+		c.remove(el)
+	}
+}
+
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	size := c.lru.Len()
+	c.mu.Unlock()
+
+	return Stats{
+		Hits:         c.hits.Load(),
+		NegativeHits: c.negativeHits.Load(),
+		Misses:       c.misses.Load(),
+		Evictions:    c.evictions.Load(),
+		Size:         size,
+	}
+}
+
+func (c *Cache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.gen
+}
+
+func (c *Cache) get(alias string) (entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[alias]
+	if !ok {
+		return entry{}, false
+	}
+
+	e := el.Value.(*entry)
+	if time.Now().After(e.expiresAt) {
+		c.remove(el)
+		return entry{}, false
+	}
+
+	c.lru.MoveToFront(el)
+
+	return *e, true
+}
+
+func (c *Cache) set(gen uint64, alias, url string, err error, ttl time.Duration) {
+	if c.size <= 0 || ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+
+	e := &entry{alias: alias, url: url, err: err, expiresAt: time.Now().Add(ttl)}
+
+	if el, ok := c.entries[alias]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	c.entries[alias] = c.lru.PushFront(e)
+
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+		c.evictions.Add(1)
+	}
+}
+
+// remove unlinks el from the cache. c.mu must be held.
+func (c *Cache) remove(el *list.Element) {
+	c.lru.Remove(el)
+	delete(c.entries, el.Value.(*entry).alias)
+}
@@ -0,0 +1,226 @@
+package cache_test
+
+import (
+	"URL-shortener/internal/storage"
+	"URL-shortener/internal/storage/cache"
+	"URL-shortener/internal/storage/memory"
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingBackend counts ResolveURL calls and can hold them until release is closed.
+type countingBackend struct {
+	storage.Backend
+	calls   atomic.Int64
+	release chan struct{}
+}
+
+func (b *countingBackend) ResolveURL(ctx context.Context, alias string) (string, time.Time, error) {
+	b.calls.Add(1)
+	if b.release != nil {
+		<-b.release
+	}
+
+	return b.Backend.ResolveURL(ctx, alias)
+}
+
+func newBackend(t *testing.T) *countingBackend {
+	t.Helper()
+
+	st, err := memory.New("")
+	require.NoError(t, err)
+
+	return &countingBackend{Backend: st}
+}
+
+func TestCache_ReadThrough(t *testing.T) {
+	backend := newBackend(t)
+	c := cache.New(backend, 10, time.Minute, time.Minute)
+
+	_, err := c.SaveURL(context.Background(), "https://google.com", "google", storage.SaveOptions{})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		got, err := c.GetURL(context.Background(), "google")
+		require.NoError(t, err)
+		require.Equal(t, "https://google.com", got)
+	}
+	require.Equal(t, int64(1), backend.calls.Load())
+
+	require.NoError(t, c.DeleteURL(context.Background(), "google", ""))
+
+	_, err = c.GetURL(context.Background(), "google")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+	require.Equal(t, int64(2), backend.calls.Load())
+
+	stats := c.Stats()
+	require.Equal(t, uint64(2), stats.Hits)
+	require.Equal(t, uint64(2), stats.Misses)
+	require.Equal(t, 1, stats.Size)
+}
+
+func TestCache_UpdateInvalidates(t *testing.T) {
+	backend := newBackend(t)
+	c := cache.New(backend, 10, time.Minute, time.Minute)
+
+	_, err := c.SaveURL(context.Background(), "https://google.com", "google", storage.SaveOptions{})
+	require.NoError(t, err)
+
+	got, err := c.GetURL(context.Background(), "google")
+	require.NoError(t, err)
+	require.Equal(t, "https://google.com", got)
+
+	_, err = c.UpdateURL(context.Background(), "google", storage.URLUpdate{URL: "https://example.com"})
+	require.NoError(t, err)
+
+	got, err = c.GetURL(context.Background(), "google")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", got)
+}
+
+func TestCache_NegativeCaching(t *testing.T) {
+	backend := newBackend(t)
+	c := cache.New(backend, 10, time.Minute, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		_, err := c.GetURL(context.Background(), "missing")
+		require.ErrorIs(t, err, storage.ErrURLNotFound)
+	}
+	require.Equal(t, int64(1), backend.calls.Load())
+	require.Equal(t, uint64(2), c.Stats().NegativeHits)
+
+	// Saving the alias drops the cached miss.
+	_, err := c.SaveURL(context.Background(), "https://google.com", "missing", storage.SaveOptions{})
+	require.NoError(t, err)
+
+	got, err := c.GetURL(context.Background(), "missing")
+	require.NoError(t, err)
+	require.Equal(t, "https://google.com", got)
+}
+
+func TestCache_BatchInvalidates(t *testing.T) {
+	backend := newBackend(t)
+	c := cache.New(backend, 10, time.Minute, time.Minute)
+
+	_, err := c.GetURL(context.Background(), "missing")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	results, err := c.SaveURLs(context.Background(), []storage.BatchItem{{URL: "https://google.com", Alias: "missing"}}, true)
+	require.NoError(t, err)
+	require.NoError(t, results[0].Err)
+
+	got, err := c.GetURL(context.Background(), "missing")
+	require.NoError(t, err)
+	require.Equal(t, "https://google.com", got)
+}
+
+func TestCache_TTL(t *testing.T) {
+	backend := newBackend(t)
+	c := cache.New(backend, 10, 10*time.Millisecond, 10*time.Millisecond)
+
+	_, err := c.GetURL(context.Background(), "missing")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	time.Sleep(20 * time.Millisecond)
+
+	_, err = c.GetURL(context.Background(), "missing")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+	require.Equal(t, int64(2), backend.calls.Load())
+}
+
+func TestCache_LinkExpiry(t *testing.T) {
+	backend := newBackend(t)
+	c := cache.New(backend, 10, time.Minute, time.Minute)
+
+	_, err := backend.SaveURL(context.Background(), "https://google.com", "google", storage.SaveOptions{ExpiresAt: time.Now().Add(50 * time.Millisecond)})
+	require.NoError(t, err)
+
+	got, err := c.GetURL(context.Background(), "google")
+	require.NoError(t, err)
+	require.Equal(t, "https://google.com", got)
+
+	time.Sleep(60 * time.Millisecond)
+
+	// The entry went with the link, long before the cache TTL.
+	_, err = c.GetURL(context.Background(), "google")
+	require.ErrorIs(t, err, storage.ErrURLExpired)
+	require.Equal(t, int64(2), backend.calls.Load())
+}
+
+func TestCache_Eviction(t *testing.T) {
+	backend := newBackend(t)
+	c := cache.New(backend, 2, time.Minute, time.Minute)
+
+	for _, alias := range []string{"a", "b", "c"} {
+		_, err := c.GetURL(context.Background(), alias)
+		require.ErrorIs(t, err, storage.ErrURLNotFound)
+	}
+
+	stats := c.Stats()
+	require.Equal(t, 2, stats.Size)
+	require.Equal(t, uint64(1), stats.Evictions)
+
+	// "a" was the least recently used one.
+	_, _ = c.GetURL(context.Background(), "a")
+	require.Equal(t, int64(4), backend.calls.Load())
+}
+
+func TestCache_Singleflight(t *testing.T) {
+	backend := newBackend(t)
+	_, err := backend.SaveURL(context.Background(), "https://google.com", "google", storage.SaveOptions{})
+	require.NoError(t, err)
+
+	backend.release = make(chan struct{})
+	c := cache.New(backend, 10, time.Minute, time.Minute)
+
+	const n = 10
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			got, err := c.GetURL(context.Background(), "google")
+			assert.NoError(t, err)
+			assert.Equal(t, "https://google.com", got)
+		}()
+	}
+
+	require.Eventually(t, func() bool { return c.Stats().Misses == n }, time.Second, time.Millisecond)
+	close(backend.release)
+	wg.Wait()
+
+	require.Equal(t, int64(1), backend.calls.Load())
+}
+
+func TestCache_CallerCancel(t *testing.T) {
+	backend := newBackend(t)
+	_, err := backend.SaveURL(context.Background(), "https://google.com", "google", storage.SaveOptions{})
+	require.NoError(t, err)
+
+	backend.release = make(chan struct{})
+	c := cache.New(backend, 10, time.Minute, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The caller stops waiting, but the shared load carries on.
+	_, err = c.GetURL(ctx, "google")
+	require.ErrorIs(t, err, context.Canceled)
+
+	close(backend.release)
+
+	require.Eventually(t, func() bool { return c.Stats().Size == 1 }, time.Second, time.Millisecond)
+
+	got, err := c.GetURL(context.Background(), "google")
+	require.NoError(t, err)
+	require.Equal(t, "https://google.com", got)
+	require.Equal(t, int64(1), backend.calls.Load())
+}
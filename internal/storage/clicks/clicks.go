@@ -0,0 +1,124 @@
+package clicks
+
+import (
+	"URL-shortener/internal/lib/logger/sl"
+	"URL-shortener/internal/storage"
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type ClickSaver interface {
+	SaveClicks(ctx context.Context, clicks []storage.Click) error
+}
+
+// Recorder collects click events in memory and writes them to storage
+// in batches from a background goroutine, so redirects never wait on the database.
+// When the buffer is full new events are dropped rather than blocking.
+type Recorder struct {
+	log           *slog.Logger
+	saver         ClickSaver
+	events        chan storage.Click
+	batchSize     int
+	flushInterval time.Duration
+	dropped       atomic.Uint64
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func NewRecorder(log *slog.Logger, saver ClickSaver, bufferSize, batchSize int, flushInterval time.Duration) *Recorder {
+	r := &Recorder{
+		log:           log.With(slog.String("component", "storage/clicks")),
+		saver:         saver,
+		events:        make(chan storage.Click, bufferSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+
+	go r.run()
+
+	return r
+}
+
+// Record queues a click for writing. It never blocks.
+func (r *Recorder) Record(click storage.Click) {
+	// Checked on its own, select picks randomly among ready cases
+	// and a click queued after Close would never be written.
+	select {
+	case <-r.stop:
+		r.dropped.Add(1)
+		return
+	default:
+	}
+
+	select {
+	case r.events <- click:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// Dropped returns how many clicks were lost because the buffer was full.
+func (r *Recorder) Dropped() uint64 {
+	return r.dropped.Load()
+}
+
+// Close stops accepting clicks and flushes everything buffered so far.
+func (r *Recorder) Close() error {
+	r.stopOnce.Do(func() { close(r.stop) })
+	<-r.done
+
+	return nil
+}
+
+func (r *Recorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]storage.Click, 0, r.batchSize)
+
+	for {
+		select {
+		case click := <-r.events:
+			batch = append(batch, click)
+			if len(batch) >= r.batchSize {
+				batch = r.flush(batch)
+			}
+		case <-ticker.C:
+			batch = r.flush(batch)
+		case <-r.stop:
+			for {
+				select {
+				case click := <-r.events:
+					batch = append(batch, click)
+					if len(batch) >= r.batchSize {
+						batch = r.flush(batch)
+					}
+				default:
+					r.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *Recorder) flush(batch []storage.Click) []storage.Click {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	if err := r.saver.SaveClicks(context.Background(), batch); err != nil {
+		r.log.Error("Failed to save clicks", slog.Int("count", len(batch)), sl.Err(err))
+	}
+
+	return batch[:0]
+}
@@ -0,0 +1,71 @@
+package clicks_test
+
+import (
+	"URL-shortener/internal/lib/logger/handlers/slogdiscard"
+	"URL-shortener/internal/storage"
+	"URL-shortener/internal/storage/clicks"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type saver struct {
+	mu      sync.Mutex
+	batches [][]storage.Click
+}
+
+func (s *saver) SaveClicks(_ context.Context, clicks []storage.Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.batches = append(s.batches, append([]storage.Click(nil), clicks...))
+
+	return nil
+}
+
+func (s *saver) total() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, b := range s.batches {
+		n += len(b)
+	}
+
+	return n
+}
+
+func TestRecorder_FlushesBatches(t *testing.T) {
+	s := &saver{}
+	r := clicks.NewRecorder(slogdiscard.NewDiscardLogger(), s, 100, 10, time.Hour)
+
+	for i := 0; i < 25; i++ {
+		r.Record(storage.Click{Alias: "alias", ClickedAt: time.Now()})
+	}
+
+	// Two full batches are written without waiting for the ticker.
+	require.Eventually(t, func() bool { return s.total() == 20 }, time.Second, time.Millisecond)
+
+	// The rest is flushed on Close.
+	require.NoError(t, r.Close())
+	require.Equal(t, 25, s.total())
+	for _, b := range s.batches {
+		require.LessOrEqual(t, len(b), 10)
+	}
+
+	r.Record(storage.Click{Alias: "alias"})
+	require.Equal(t, uint64(1), r.Dropped())
+}
+
+func TestRecorder_FlushesOnInterval(t *testing.T) {
+	s := &saver{}
+	r := clicks.NewRecorder(slogdiscard.NewDiscardLogger(), s, 100, 10, 10*time.Millisecond)
+	defer r.Close()
+
+	r.Record(storage.Click{Alias: "alias", ClickedAt: time.Now()})
+
+	require.Eventually(t, func() bool { return s.total() == 1 }, time.Second, time.Millisecond)
+}
@@ -0,0 +1,86 @@
+package janitor
+
+import (
+	"URL-shortener/internal/lib/logger/sl"
+	"context"
+	"log/slog"
+	"time"
+)
+
+type ExpiredPurger interface {
+	PurgeExpired(ctx context.Context, limit int) (int64, error)
+	PurgeIdempotencyKeys(ctx context.Context, limit int) (int64, error)
+}
+
+// Janitor periodically deletes expired links and idempotency keys in batches,
+// so a large backlog never turns into one long-running DELETE.
+type Janitor struct {
+	log       *slog.Logger
+	purger    ExpiredPurger
+	interval  time.Duration
+	batchSize int
+}
+
+func New(log *slog.Logger, purger ExpiredPurger, interval time.Duration, batchSize int) *Janitor {
+	return &Janitor{
+		log:       log.With(slog.String("component", "storage/janitor")),
+		purger:    purger,
+		interval:  interval,
+		batchSize: batchSize,
+	}
+}
+
+// Run purges expired links and idempotency keys every interval until ctx is done.
+func (j *Janitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			j.Purge(ctx)
+		}
+	}
+}
+
+// Purge deletes expired links, then expired idempotency keys, batch by
+// batch until a batch comes back short. It returns the number of links.
+func (j *Janitor) Purge(ctx context.Context) int64 {
+	total, err := j.purgeAll(ctx, j.purger.PurgeExpired)
+	if err != nil {
+		j.log.Error("Failed to purge expired URLs", sl.Err(err))
+	}
+	if total > 0 {
+		j.log.Info("Expired URLs purged", slog.Int64("count", total))
+	}
+
+	keys, err := j.purgeAll(ctx, j.purger.PurgeIdempotencyKeys)
+	if err != nil {
+		j.log.Error("Failed to purge idempotency keys", sl.Err(err))
+	}
+	if keys > 0 {
+		j.log.Info("Idempotency keys purged", slog.Int64("count", keys))
+	}
+
+	return total
+}
+
+func (j *Janitor) purgeAll(ctx context.Context, purge func(context.Context, int) (int64, error)) (int64, error) {
+	var total int64
+
+	for ctx.Err() == nil {
+		purged, err := purge(ctx, j.batchSize)
+		if err != nil {
+			return total, err
+		}
+
+		total += purged
+		if purged < int64(j.batchSize) {
+			break
+		}
+	}
+
+	return total, nil
+}
@@ -0,0 +1,60 @@
+package janitor_test
+
+import (
+	"URL-shortener/internal/lib/logger/handlers/slogdiscard"
+	"URL-shortener/internal/storage"
+	"URL-shortener/internal/storage/janitor"
+	"URL-shortener/internal/storage/memory"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJanitor_Purge(t *testing.T) {
+	st, err := memory.New("")
+	require.NoError(t, err)
+
+	expired := storage.SaveOptions{ExpiresAt: time.Now().Add(-time.Second)}
+	for i := 0; i < 25; i++ {
+		_, err := st.SaveURL(context.Background(), "https://google.com", fmt.Sprintf("alias%d", i), expired)
+		require.NoError(t, err)
+	}
+	_, err = st.SaveURL(context.Background(), "https://google.com", "alive", storage.SaveOptions{})
+	require.NoError(t, err)
+
+	j := janitor.New(slogdiscard.NewDiscardLogger(), st, time.Minute, 10)
+
+	require.Equal(t, int64(25), j.Purge(context.Background()))
+	require.Zero(t, j.Purge(context.Background()))
+
+	_, err = st.GetURL(context.Background(), "alive")
+	require.NoError(t, err)
+}
+
+func TestJanitor_PurgeIdempotencyKeys(t *testing.T) {
+	st, err := memory.New("")
+	require.NoError(t, err)
+
+	for i := 0; i < 15; i++ {
+		_, err := st.ReserveIdempotencyKey(context.Background(), storage.IdempotencyRecord{
+			Key:       fmt.Sprintf("key%d", i),
+			ExpiresAt: time.Now().Add(-time.Second),
+		})
+		require.NoError(t, err)
+	}
+	_, err = st.ReserveIdempotencyKey(context.Background(), storage.IdempotencyRecord{Key: "alive", ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	j := janitor.New(slogdiscard.NewDiscardLogger(), st, time.Minute, 10)
+	j.Purge(context.Background())
+
+	purged, err := st.PurgeIdempotencyKeys(context.Background(), 100)
+	require.NoError(t, err)
+	require.Zero(t, purged)
+
+	_, err = st.ReserveIdempotencyKey(context.Background(), storage.IdempotencyRecord{Key: "alive", ExpiresAt: time.Now().Add(time.Hour)})
+	require.ErrorIs(t, err, storage.ErrIdempotencyKeyExists)
+}
@@ -0,0 +1,762 @@
+package memory
+
+import (
+	"URL-shortener/internal/storage"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Storage keeps URLs in process memory. It is safe for concurrent use.
+// If a snapshot path is set, the data is loaded from it on New and
+// written back on Close, otherwise everything is lost on exit.
+type Storage struct {
+	mu           sync.RWMutex
+	urls         map[string]record
+	clicks       map[string][]storage.Click
+	history      map[string][]revision
+	lastID       int64
+	apiKeys      []storage.APIKey
+	idempotency  map[idempotencyKey]storage.IdempotencyRecord
+	snapshotPath string
+}
+
+type idempotencyKey struct {
+	owner, key string
+}
+
+type record struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Owner     string    `json:"owner,omitempty"`
+	// Version is zero in snapshots taken before links had versions.
+	Version   int64     `json:"version,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+}
+
+// revision is a previous destination of a link.
+type revision struct {
+	URL       string    `json:"url"`
+	Version   int64     `json:"version"`
+	ChangedBy string    `json:"changed_by"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+func (r record) ownedBy(owner string) bool {
+	return owner == "" || r.Owner == owner
+}
+
+func (r record) expired(now time.Time) bool {
+	return !r.ExpiresAt.IsZero() && !r.ExpiresAt.After(now)
+}
+
+type snapshot struct {
+	LastID int64                      `json:"last_id"`
+	URLs   map[string]record          `json:"urls"`
+	Clicks map[string][]storage.Click `json:"clicks"`
+	// History holds the previous destinations by alias.
+	History map[string][]revision `json:"history,omitempty"`
+	// APIKeys are ordered by id, starting at 1.
+	APIKeys []storage.APIKey `json:"api_keys"`
+	// IdempotencyKeys keep stored responses across restarts, so a retry
+	// within the window is still answered from them.
+	IdempotencyKeys []storage.IdempotencyRecord `json:"idempotency_keys,omitempty"`
+}
+
+var _ storage.Backend = (*Storage)(nil)
+
+func New(snapshotPath string) (*Storage, error) {
+	const op = "storage.memory.New"
+
+	s := &Storage{
+		urls:         make(map[string]record),
+		clicks:       make(map[string][]storage.Click),
+		history:      make(map[string][]revision),
+		idempotency:  make(map[idempotencyKey]storage.IdempotencyRecord),
+		snapshotPath: snapshotPath,
+	}
+
+	if snapshotPath == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(snapshotPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: read snapshot: %w", op, err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("%s: decode snapshot: %w", op, err)
+	}
+
+	if snap.URLs != nil {
+		s.urls = snap.URLs
+	}
+	if snap.Clicks != nil {
+		s.clicks = snap.Clicks
+	}
+	if snap.History != nil {
+		s.history = snap.History
+	}
+	for alias, rec := range s.urls {
+		if rec.Version == 0 {
+			rec.Version = 1
+			s.urls[alias] = rec
+		}
+	}
+	s.lastID = snap.LastID
+	s.apiKeys = snap.APIKeys
+	for _, rec := range snap.IdempotencyKeys {
+		s.idempotency[idempotencyKey{owner: rec.Owner, key: rec.Key}] = rec
+	}
+
+	return s, nil
+}
+
+// SaveURL inserts a new URL and alias, returning the generated id.
+func (s *Storage) SaveURL(_ context.Context, urlToSave string, alias string, opts storage.SaveOptions) (int64, error) {
+	const op = "storage.memory.SaveURL"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.urls[alias]; ok {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrURLExists)
+	}
+
+	now := time.Now().UTC()
+
+	s.lastID++
+	s.urls[alias] = record{
+		ID:        s.lastID,
+		URL:       urlToSave,
+		ExpiresAt: opts.ExpiresAt,
+		Owner:     opts.Owner,
+		Version:   1,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Tags:      slices.Sorted(slices.Values(opts.Tags)),
+	}
+
+	return s.lastID, nil
+}
+
+// SaveURLs inserts a batch of links under a single lock. Taken aliases
+// are skipped, and with atomic any of them leaves the storage unchanged.
+func (s *Storage) SaveURLs(_ context.Context, items []storage.BatchItem, atomic bool) ([]storage.BatchResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := make([]storage.BatchResult, len(items))
+	taken := make(map[string]bool, len(items))
+	failed := false
+
+	for i, item := range items {
+		if _, ok := s.urls[item.Alias]; ok || taken[item.Alias] {
+			results[i].Err = storage.ErrURLExists
+			failed = true
+			continue
+		}
+		taken[item.Alias] = true
+	}
+
+	if failed && atomic {
+		storage.AbortBatch(results)
+		return results, nil
+	}
+
+	now := time.Now().UTC()
+
+	for i, item := range items {
+		if results[i].Err != nil {
+			continue
+		}
+
+		s.lastID++
+		s.urls[item.Alias] = record{
+			ID:        s.lastID,
+			URL:       item.URL,
+			ExpiresAt: item.Opts.ExpiresAt,
+			Owner:     item.Opts.Owner,
+			Version:   1,
+			CreatedAt: now,
+			UpdatedAt: now,
+			Tags:      slices.Sorted(slices.Values(item.Opts.Tags)),
+		}
+		results[i].ID = s.lastID
+	}
+
+	return results, nil
+}
+
+func (s *Storage) GetURL(_ context.Context, alias string) (string, error) {
+	const op = "storage.memory.GetURL"
+
+	url, _, err := s.resolve(alias)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return url, nil
+}
+
+// ResolveURL is GetURL that also returns when the link expires,
+// zero if never.
+func (s *Storage) ResolveURL(_ context.Context, alias string) (string, time.Time, error) {
+	const op = "storage.memory.ResolveURL"
+
+	url, expiresAt, err := s.resolve(alias)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return url, expiresAt, nil
+}
+
+func (s *Storage) resolve(alias string) (string, time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, ok := s.urls[alias]
+	if !ok {
+		return "", time.Time{}, storage.ErrURLNotFound
+	}
+	if rec.expired(time.Now()) {
+		return "", time.Time{}, storage.ErrURLExpired
+	}
+
+	return rec.URL, rec.ExpiresAt, nil
+}
+
+// GetLink returns the link with its metadata, expired links included.
+func (s *Storage) GetLink(_ context.Context, alias string, owner string) (storage.Link, error) {
+	const op = "storage.memory.GetLink"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, ok := s.urls[alias]
+	if !ok {
+		return storage.Link{}, fmt.Errorf("%s: %w", op, storage.ErrURLNotFound)
+	}
+	if !rec.ownedBy(owner) {
+		return storage.Link{}, fmt.Errorf("%s: %w", op, storage.ErrNotOwner)
+	}
+
+	return s.link(alias, rec), nil
+}
+
+// FindURL returns the oldest link of owner that never expires, has no
+// tags and whose destination normalizes like rawURL, see
+// storage.NormalizeURL. Unlike elsewhere, an empty owner only matches
+// links without one.
+func (s *Storage) FindURL(_ context.Context, owner string, rawURL string) (storage.Link, error) {
+	const op = "storage.memory.FindURL"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	want := storage.NormalizeURL(rawURL)
+
+	var (
+		found storage.Link
+		ok    bool
+	)
+	for alias, rec := range s.urls {
+		if rec.Owner != owner || !rec.ExpiresAt.IsZero() || len(rec.Tags) > 0 {
+			continue
+		}
+		if storage.NormalizeURL(rec.URL) != want {
+			continue
+		}
+		if !ok || rec.ID < found.ID {
+			found, ok = s.link(alias, rec), true
+		}
+	}
+	if !ok {
+		return storage.Link{}, fmt.Errorf("%s: %w", op, storage.ErrURLNotFound)
+	}
+
+	return found, nil
+}
+
+// ListURLs returns a page of links in id order. It scans every link,
+// which is fine for the sizes this storage is meant for.
+func (s *Storage) ListURLs(_ context.Context, opts storage.ListOptions) ([]storage.Link, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	links := []storage.Link{}
+	for alias, rec := range s.urls {
+		if rec.matches(alias, opts) {
+			links = append(links, s.link(alias, rec))
+		}
+	}
+
+	sort.Slice(links, func(i, j int) bool {
+		if opts.Ascending {
+			return links[i].ID < links[j].ID
+		}
+		return links[i].ID > links[j].ID
+	})
+	if len(links) > opts.Limit {
+		links = links[:opts.Limit]
+	}
+
+	return links, nil
+}
+
+// matches reports whether the link passes the filters of opts.
+func (r record) matches(alias string, opts storage.ListOptions) bool {
+	switch {
+	case opts.After != 0 && opts.Ascending && r.ID <= opts.After,
+		opts.After != 0 && !opts.Ascending && r.ID >= opts.After:
+		return false
+	case opts.Owner != "" && r.Owner != opts.Owner:
+		return false
+	case !opts.CreatedAfter.IsZero() && (r.CreatedAt.IsZero() || r.CreatedAt.Before(opts.CreatedAfter)):
+		return false
+	case !opts.CreatedBefore.IsZero() && (r.CreatedAt.IsZero() || !r.CreatedAt.Before(opts.CreatedBefore)):
+		return false
+	case opts.Domain != "" && storage.Domain(r.URL) != strings.ToLower(opts.Domain):
+		return false
+	case !strings.HasPrefix(alias, opts.AliasPrefix):
+		return false
+	case opts.Tag != "" && !slices.Contains(r.Tags, opts.Tag):
+		return false
+	case opts.Query != "" && !strings.Contains(strings.ToLower(r.URL), strings.ToLower(opts.Query)):
+		return false
+	}
+
+	return true
+}
+
+// link copies rec out, s.mu must be held.
+func (s *Storage) link(alias string, rec record) storage.Link {
+	return storage.Link{
+		ID:        rec.ID,
+		Alias:     alias,
+		URL:       rec.URL,
+		Owner:     rec.Owner,
+		Version:   rec.Version,
+		CreatedAt: rec.CreatedAt,
+		UpdatedAt: rec.UpdatedAt,
+		ExpiresAt: rec.ExpiresAt,
+		Clicks:    int64(len(s.clicks[alias])),
+		Tags:      append([]string{}, rec.Tags...),
+	}
+}
+
+// UpdateURL points the link to upd.URL and returns its new version.
+func (s *Storage) UpdateURL(_ context.Context, alias string, upd storage.URLUpdate) (int64, error) {
+	const op = "storage.memory.UpdateURL"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.urls[alias]
+	if !ok {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrURLNotFound)
+	}
+	if !rec.ownedBy(upd.Owner) {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrNotOwner)
+	}
+	if upd.Version != 0 && rec.Version != upd.Version {
+		return 0, fmt.Errorf("%s: %w", op, storage.ErrVersionMismatch)
+	}
+
+	now := time.Now().UTC()
+
+	s.history[alias] = append(s.history[alias], revision{
+		URL:       rec.URL,
+		Version:   rec.Version,
+		ChangedBy: upd.ChangedBy,
+		ChangedAt: now,
+	})
+
+	rec.URL = upd.URL
+	rec.Version++
+	rec.UpdatedAt = now
+	if upd.SetMeta {
+		rec.ExpiresAt = upd.ExpiresAt
+		rec.Tags = slices.Compact(slices.Sorted(slices.Values(upd.Tags)))
+	}
+	s.urls[alias] = rec
+
+	return rec.Version, nil
+}
+
+func (s *Storage) DeleteURL(_ context.Context, alias string, owner string) error {
+	const op = "storage.memory.DeleteURL"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, ok := s.urls[alias]
+	if !ok {
+		return fmt.Errorf("%s: %w", op, storage.ErrURLNotFound)
+	}
+	if !rec.ownedBy(owner) {
+		return fmt.Errorf("%s: %w", op, storage.ErrNotOwner)
+	}
+
+	delete(s.urls, alias)
+	delete(s.clicks, alias)
+	delete(s.history, alias)
+
+	return nil
+}
+
+// PurgeExpired deletes up to limit expired links and returns how many were deleted.
+func (s *Storage) PurgeExpired(_ context.Context, limit int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	var purged int64
+	for alias, rec := range s.urls {
+		if purged >= int64(limit) {
+			break
+		}
+		if rec.expired(now) {
+			delete(s.urls, alias)
+			delete(s.clicks, alias)
+			delete(s.history, alias)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// ReserveIdempotencyKey stores rec unless a live record holds the same
+// owner and key, in which case it returns that record and
+// storage.ErrIdempotencyKeyExists. Expired records are replaced.
+func (s *Storage) ReserveIdempotencyKey(_ context.Context, rec storage.IdempotencyRecord) (storage.IdempotencyRecord, error) {
+	const op = "storage.memory.ReserveIdempotencyKey"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := idempotencyKey{owner: rec.Owner, key: rec.Key}
+	if existing, ok := s.idempotency[k]; ok && existing.ExpiresAt.After(time.Now()) {
+		return existing, fmt.Errorf("%s: %w", op, storage.ErrIdempotencyKeyExists)
+	}
+
+	s.idempotency[k] = rec
+
+	return rec, nil
+}
+
+// CompleteIdempotencyKey stores the response of a reserved key.
+func (s *Storage) CompleteIdempotencyKey(_ context.Context, rec storage.IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := idempotencyKey{owner: rec.Owner, key: rec.Key}
+	if existing, ok := s.idempotency[k]; ok {
+		existing.Status = rec.Status
+		existing.Headers = rec.Headers
+		existing.Body = rec.Body
+		existing.ExpiresAt = rec.ExpiresAt
+		s.idempotency[k] = existing
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey deletes a reservation that got no response
+// worth keeping, so the request can be retried with the same key.
+func (s *Storage) ReleaseIdempotencyKey(_ context.Context, owner string, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := idempotencyKey{owner: owner, key: key}
+	if existing, ok := s.idempotency[k]; ok && existing.Status == 0 {
+		delete(s.idempotency, k)
+	}
+
+	return nil
+}
+
+// PurgeIdempotencyKeys deletes up to limit expired records and returns
+// how many were deleted.
+func (s *Storage) PurgeIdempotencyKeys(_ context.Context, limit int) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	var purged int64
+	for k, rec := range s.idempotency {
+		if purged >= int64(limit) {
+			break
+		}
+		if !rec.ExpiresAt.After(now) {
+			delete(s.idempotency, k)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// SaveClicks stores a batch of click events.
+// Clicks on aliases that no longer exist are skipped.
+func (s *Storage) SaveClicks(_ context.Context, clicks []storage.Click) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, c := range clicks {
+		if _, ok := s.urls[c.Alias]; ok {
+			s.clicks[c.Alias] = append(s.clicks[c.Alias], c)
+		}
+	}
+
+	return nil
+}
+
+// URLStats returns click statistics of a link, with per-day counts since the given time.
+func (s *Storage) URLStats(_ context.Context, alias string, owner string, since time.Time, topReferrers int) (storage.Stats, error) {
+	const op = "storage.memory.URLStats"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, ok := s.urls[alias]
+	if !ok {
+		return storage.Stats{}, fmt.Errorf("%s: %w", op, storage.ErrURLNotFound)
+	}
+	if !rec.ownedBy(owner) {
+		return storage.Stats{}, fmt.Errorf("%s: %w", op, storage.ErrNotOwner)
+	}
+
+	clicks := s.clicks[alias]
+
+	perDay := make(map[string]int64)
+	perReferrer := make(map[string]int64)
+	for _, c := range clicks {
+		if !c.ClickedAt.Before(since) {
+			perDay[c.ClickedAt.UTC().Format(time.DateOnly)]++
+		}
+		if c.Referrer != "" {
+			perReferrer[c.Referrer]++
+		}
+	}
+
+	stats := storage.Stats{
+		TotalClicks:  int64(len(clicks)),
+		ClicksPerDay: []storage.DailyClicks{},
+		TopReferrers: []storage.ReferrerClicks{},
+	}
+	for day, n := range perDay {
+		stats.ClicksPerDay = append(stats.ClicksPerDay, storage.DailyClicks{Day: day, Clicks: n})
+	}
+	sort.Slice(stats.ClicksPerDay, func(i, j int) bool {
+		return stats.ClicksPerDay[i].Day < stats.ClicksPerDay[j].Day
+	})
+
+	for referrer, n := range perReferrer {
+		stats.TopReferrers = append(stats.TopReferrers, storage.ReferrerClicks{Referrer: referrer, Clicks: n})
+	}
+	sort.Slice(stats.TopReferrers, func(i, j int) bool {
+		a, b := stats.TopReferrers[i], stats.TopReferrers[j]
+		if a.Clicks != b.Clicks {
+			return a.Clicks > b.Clicks
+		}
+		return a.Referrer < b.Referrer
+	})
+	if len(stats.TopReferrers) > topReferrers {
+		stats.TopReferrers = stats.TopReferrers[:topReferrers]
+	}
+
+	return stats, nil
+}
+
+// URLHistory returns the previous destinations of a link, newest first.
+func (s *Storage) URLHistory(_ context.Context, alias string, owner string) ([]storage.Revision, error) {
+	const op = "storage.memory.URLHistory"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, ok := s.urls[alias]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrURLNotFound)
+	}
+	if !rec.ownedBy(owner) {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrNotOwner)
+	}
+
+	revisions := s.history[alias]
+	history := make([]storage.Revision, 0, len(revisions))
+	for i := len(revisions) - 1; i >= 0; i-- {
+		history = append(history, storage.Revision(revisions[i]))
+	}
+
+	return history, nil
+}
+
+// SaveAPIKey stores a new API key, returning the generated id.
+func (s *Storage) SaveAPIKey(_ context.Context, key storage.APIKey) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key.ID = int64(len(s.apiKeys)) + 1
+	key.Scopes = append([]string{}, key.Scopes...)
+	s.apiKeys = append(s.apiKeys, key)
+
+	return key.ID, nil
+}
+
+// GetAPIKey returns the active key with the given hash.
+func (s *Storage) GetAPIKey(_ context.Context, hash string) (storage.APIKey, error) {
+	const op = "storage.memory.GetAPIKey"
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, key := range s.apiKeys {
+		if key.Hash == hash && key.RevokedAt.IsZero() {
+			return copyAPIKey(key), nil
+		}
+	}
+
+	return storage.APIKey{}, fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+}
+
+// ListAPIKeys returns all keys, revoked ones included, oldest first.
+func (s *Storage) ListAPIKeys(_ context.Context) ([]storage.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]storage.APIKey, 0, len(s.apiKeys))
+	for _, key := range s.apiKeys {
+		keys = append(keys, copyAPIKey(key))
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey marks an active key as revoked.
+func (s *Storage) RevokeAPIKey(_ context.Context, id int64) error {
+	const op = "storage.memory.RevokeAPIKey"
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id < 1 || id > int64(len(s.apiKeys)) || !s.apiKeys[id-1].RevokedAt.IsZero() {
+		return fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+	}
+
+	s.apiKeys[id-1].RevokedAt = time.Now()
+
+	return nil
+}
+
+// TouchAPIKey records when a key was last used.
+func (s *Storage) TouchAPIKey(_ context.Context, id int64, usedAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if id >= 1 && id <= int64(len(s.apiKeys)) {
+		s.apiKeys[id-1].LastUsedAt = usedAt
+	}
+
+	return nil
+}
+
+// copyAPIKey keeps callers from modifying the stored scopes.
+func copyAPIKey(key storage.APIKey) storage.APIKey {
+	key.Scopes = append([]string{}, key.Scopes...)
+	return key
+}
+
+// Snapshot writes the current contents to the snapshot path.
+// The file is replaced atomically so a crash never leaves a partial snapshot.
+func (s *Storage) Snapshot() error {
+	const op = "storage.memory.Snapshot"
+
+	if s.snapshotPath == "" {
+		return nil
+	}
+
+	s.mu.RLock()
+	data, err := json.Marshal(snapshot{
+		LastID:          s.lastID,
+		URLs:            s.urls,
+		Clicks:          s.clicks,
+		History:         s.history,
+		APIKeys:         s.apiKeys,
+		IdempotencyKeys: s.liveIdempotencyKeys(time.Now()),
+	})
+	s.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("%s: encode snapshot: %w", op, err)
+	}
+
+	dir := filepath.Dir(s.snapshotPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("%s: create dir: %w", op, err)
+	}
+
+	tmp, err := os.CreateTemp(dir, filepath.Base(s.snapshotPath)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("%s: create temp file: %w", op, err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("%s: write snapshot: %w", op, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("%s: close snapshot: %w", op, err)
+	}
+
+	if err := os.Rename(tmp.Name(), s.snapshotPath); err != nil {
+		return fmt.Errorf("%s: rename snapshot: %w", op, err)
+	}
+
+	return nil
+}
+
+// Close snapshots the data to disk if a snapshot path is configured.
+// liveIdempotencyKeys returns the records that haven't expired yet,
+// ordered by owner and key. The caller must hold s.mu.
+func (s *Storage) liveIdempotencyKeys(now time.Time) []storage.IdempotencyRecord {
+	var recs []storage.IdempotencyRecord
+	for _, rec := range s.idempotency {
+		if rec.ExpiresAt.After(now) {
+			recs = append(recs, rec)
+		}
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		if recs[i].Owner != recs[j].Owner {
+			return recs[i].Owner < recs[j].Owner
+		}
+		return recs[i].Key < recs[j].Key
+	})
+
+	return recs
+}
+
+func (s *Storage) Close() error {
+	return s.Snapshot()
+}
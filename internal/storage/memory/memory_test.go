@@ -0,0 +1,618 @@
+package memory_test
+
+import (
+	"URL-shortener/internal/storage"
+	"URL-shortener/internal/storage/memory"
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage(t *testing.T) {
+	st, err := memory.New("")
+	require.NoError(t, err)
+
+	id, err := st.SaveURL(context.Background(), "https://google.com", "google", storage.SaveOptions{})
+	require.NoError(t, err)
+	require.Equal(t, int64(1), id)
+
+	_, err = st.SaveURL(context.Background(), "https://example.com", "google", storage.SaveOptions{})
+	require.ErrorIs(t, err, storage.ErrURLExists)
+
+	got, err := st.GetURL(context.Background(), "google")
+	require.NoError(t, err)
+	require.Equal(t, "https://google.com", got)
+
+	require.NoError(t, st.DeleteURL(context.Background(), "google", ""))
+
+	_, err = st.GetURL(context.Background(), "google")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	require.ErrorIs(t, st.DeleteURL(context.Background(), "google", ""), storage.ErrURLNotFound)
+}
+
+func TestStorage_Concurrent(t *testing.T) {
+	st, err := memory.New("")
+	require.NoError(t, err)
+
+	const n = 100
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			alias := fmt.Sprintf("alias%d", i)
+			_, err := st.SaveURL(context.Background(), "https://example.com", alias, storage.SaveOptions{})
+			assert.NoError(t, err)
+
+			_, err = st.GetURL(context.Background(), alias)
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	id, err := st.SaveURL(context.Background(), "https://example.com", "last", storage.SaveOptions{})
+	require.NoError(t, err)
+	require.Equal(t, int64(n+1), id)
+}
+
+func TestStorage_Snapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+
+	st, err := memory.New(path)
+	require.NoError(t, err)
+
+	_, err = st.SaveURL(context.Background(), "https://google.com", "google", storage.SaveOptions{})
+	require.NoError(t, err)
+	require.NoError(t, st.Close())
+
+	restored, err := memory.New(path)
+	require.NoError(t, err)
+
+	got, err := restored.GetURL(context.Background(), "google")
+	require.NoError(t, err)
+	require.Equal(t, "https://google.com", got)
+
+	id, err := restored.SaveURL(context.Background(), "https://example.com", "example", storage.SaveOptions{})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), id)
+}
+
+func TestStorage_SnapshotIdempotencyKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	ctx := context.Background()
+
+	st, err := memory.New(path)
+	require.NoError(t, err)
+
+	rec := storage.IdempotencyRecord{
+		Owner:       "key:1",
+		Key:         "abc",
+		RequestHash: "hash",
+		Status:      201,
+		Headers:     map[string]string{"Location": "/google"},
+		Body:        []byte(`{"status":"OK"}`),
+		ExpiresAt:   time.Now().Add(time.Hour).UTC(),
+	}
+	_, err = st.ReserveIdempotencyKey(ctx, storage.IdempotencyRecord{Owner: rec.Owner, Key: rec.Key, RequestHash: rec.RequestHash, ExpiresAt: time.Now().Add(time.Minute)})
+	require.NoError(t, err)
+	require.NoError(t, st.CompleteIdempotencyKey(ctx, rec))
+	_, err = st.ReserveIdempotencyKey(ctx, storage.IdempotencyRecord{Owner: "key:1", Key: "old", ExpiresAt: time.Now().Add(-time.Second)})
+	require.NoError(t, err)
+	require.NoError(t, st.Close())
+
+	restored, err := memory.New(path)
+	require.NoError(t, err)
+
+	// A retry after the restart still gets the stored response.
+	existing, err := restored.ReserveIdempotencyKey(ctx, storage.IdempotencyRecord{Owner: "key:1", Key: "abc", RequestHash: "hash", ExpiresAt: time.Now().Add(time.Minute)})
+	require.ErrorIs(t, err, storage.ErrIdempotencyKeyExists)
+	require.Equal(t, rec.Status, existing.Status)
+	require.Equal(t, rec.Headers, existing.Headers)
+	require.Equal(t, rec.Body, existing.Body)
+	require.True(t, rec.ExpiresAt.Equal(existing.ExpiresAt))
+
+	// Expired keys aren't carried over.
+	purged, err := restored.PurgeIdempotencyKeys(ctx, 10)
+	require.NoError(t, err)
+	require.Zero(t, purged)
+}
+
+func TestStorage_Owner(t *testing.T) {
+	st, err := memory.New("")
+	require.NoError(t, err)
+
+	_, err = st.SaveURL(context.Background(), "https://google.com", "google", storage.SaveOptions{Owner: "key:1"})
+	require.NoError(t, err)
+
+	_, err = st.URLStats(context.Background(), "google", "key:2", time.Now(), 10)
+	require.ErrorIs(t, err, storage.ErrNotOwner)
+	_, err = st.URLStats(context.Background(), "google", "key:1", time.Now(), 10)
+	require.NoError(t, err)
+
+	require.ErrorIs(t, st.DeleteURL(context.Background(), "google", "key:2"), storage.ErrNotOwner)
+	require.ErrorIs(t, st.DeleteURL(context.Background(), "missing", "key:2"), storage.ErrURLNotFound)
+	require.NoError(t, st.DeleteURL(context.Background(), "google", "key:1"))
+
+	_, err = st.SaveURL(context.Background(), "https://google.com", "google", storage.SaveOptions{Owner: "key:1"})
+	require.NoError(t, err)
+	require.NoError(t, st.DeleteURL(context.Background(), "google", ""))
+}
+
+func TestStorage_Update(t *testing.T) {
+	st, err := memory.New("")
+	require.NoError(t, err)
+
+	_, err = st.SaveURL(context.Background(), "https://google.com", "google", storage.SaveOptions{Owner: "key:1"})
+	require.NoError(t, err)
+
+	version, err := st.UpdateURL(context.Background(), "google", storage.URLUpdate{URL: "https://example.com", Version: 1, Owner: "key:1"})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), version)
+
+	got, err := st.GetURL(context.Background(), "google")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", got)
+
+	_, err = st.UpdateURL(context.Background(), "google", storage.URLUpdate{URL: "https://example.org", Version: 1})
+	require.ErrorIs(t, err, storage.ErrVersionMismatch)
+
+	_, err = st.UpdateURL(context.Background(), "google", storage.URLUpdate{URL: "https://example.org", Owner: "key:2"})
+	require.ErrorIs(t, err, storage.ErrNotOwner)
+
+	_, err = st.UpdateURL(context.Background(), "missing", storage.URLUpdate{URL: "https://example.org"})
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	version, err = st.UpdateURL(context.Background(), "google", storage.URLUpdate{URL: "https://example.org", ChangedBy: "key:1"})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), version)
+
+	history, err := st.URLHistory(context.Background(), "google", "key:1")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	require.Equal(t, "https://example.com", history[0].URL)
+	require.Equal(t, int64(2), history[0].Version)
+	require.Equal(t, "key:1", history[0].ChangedBy)
+	require.WithinDuration(t, time.Now(), history[0].ChangedAt, time.Minute)
+	require.Equal(t, "https://google.com", history[1].URL)
+	require.Equal(t, int64(1), history[1].Version)
+
+	_, err = st.URLHistory(context.Background(), "google", "key:2")
+	require.ErrorIs(t, err, storage.ErrNotOwner)
+
+	_, err = st.URLHistory(context.Background(), "missing", "")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+func TestStorage_UpdateMeta(t *testing.T) {
+	st, err := memory.New("")
+	require.NoError(t, err)
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	_, err = st.SaveURL(context.Background(), "https://google.com", "google", storage.SaveOptions{ExpiresAt: expiresAt, Tags: []string{"promo"}})
+	require.NoError(t, err)
+
+	// Without SetMeta the expiry and tags are kept.
+	_, err = st.UpdateURL(context.Background(), "google", storage.URLUpdate{URL: "https://example.com"})
+	require.NoError(t, err)
+
+	link, err := st.GetLink(context.Background(), "google", "")
+	require.NoError(t, err)
+	require.True(t, link.ExpiresAt.Equal(expiresAt))
+	require.Equal(t, []string{"promo"}, link.Tags)
+
+	later := expiresAt.Add(time.Hour)
+	_, err = st.UpdateURL(context.Background(), "google", storage.URLUpdate{
+		URL:       "https://example.org",
+		SetMeta:   true,
+		ExpiresAt: later,
+		Tags:      []string{"docs", "blog", "docs"},
+	})
+	require.NoError(t, err)
+
+	link, err = st.GetLink(context.Background(), "google", "")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.org", link.URL)
+	require.True(t, link.ExpiresAt.Equal(later))
+	require.Equal(t, []string{"blog", "docs"}, link.Tags)
+
+	// Zero values clear them.
+	_, err = st.UpdateURL(context.Background(), "google", storage.URLUpdate{URL: "https://example.org", SetMeta: true})
+	require.NoError(t, err)
+
+	link, err = st.GetLink(context.Background(), "google", "")
+	require.NoError(t, err)
+	require.True(t, link.ExpiresAt.IsZero())
+	require.Empty(t, link.Tags)
+}
+
+func TestStorage_GetLink(t *testing.T) {
+	st, err := memory.New("")
+	require.NoError(t, err)
+
+	expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	id, err := st.SaveURL(context.Background(), "https://google.com", "google", storage.SaveOptions{Owner: "key:1", ExpiresAt: expiresAt})
+	require.NoError(t, err)
+	require.NoError(t, st.SaveClicks(context.Background(), []storage.Click{{Alias: "google", ClickedAt: time.Now()}}))
+
+	link, err := st.GetLink(context.Background(), "google", "key:1")
+	require.NoError(t, err)
+	require.Equal(t, id, link.ID)
+	require.Equal(t, "google", link.Alias)
+	require.Equal(t, "https://google.com", link.URL)
+	require.Equal(t, "key:1", link.Owner)
+	require.Equal(t, int64(1), link.Version)
+	require.Equal(t, int64(1), link.Clicks)
+	require.True(t, link.ExpiresAt.Equal(expiresAt))
+	require.WithinDuration(t, time.Now(), link.CreatedAt, time.Minute)
+	require.Equal(t, link.CreatedAt, link.UpdatedAt)
+
+	_, err = st.UpdateURL(context.Background(), "google", storage.URLUpdate{URL: "https://example.com"})
+	require.NoError(t, err)
+
+	link, err = st.GetLink(context.Background(), "google", "")
+	require.NoError(t, err)
+	require.Equal(t, "https://example.com", link.URL)
+	require.Equal(t, int64(2), link.Version)
+	require.False(t, link.UpdatedAt.Before(link.CreatedAt))
+
+	_, err = st.GetLink(context.Background(), "google", "key:2")
+	require.ErrorIs(t, err, storage.ErrNotOwner)
+
+	_, err = st.GetLink(context.Background(), "missing", "")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+func TestStorage_List(t *testing.T) {
+	st, err := memory.New("")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	saves := []struct {
+		url, alias, owner string
+		tags              []string
+	}{
+		{"https://Example.com/a?utm=1", "promo_a", "key:1", []string{"docs", "promo"}},
+		{"https://google.com/search", "promo-b", "key:2", []string{"promo"}},
+		{"https://example.com/100%25", "other", "key:1", nil},
+	}
+	ids := make([]int64, len(saves))
+	for i, s := range saves {
+		id, err := st.SaveURL(ctx, s.url, s.alias, storage.SaveOptions{Owner: s.owner, Tags: s.tags})
+		require.NoError(t, err)
+		ids[i] = id
+	}
+
+	link, err := st.GetLink(ctx, "promo_a", "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"docs", "promo"}, link.Tags)
+
+	aliases := func(opts storage.ListOptions) []string {
+		t.Helper()
+
+		if opts.Limit == 0 {
+			opts.Limit = 10
+		}
+		links, err := st.ListURLs(ctx, opts)
+		require.NoError(t, err)
+
+		aliases := []string{}
+		for _, link := range links {
+			aliases = append(aliases, link.Alias)
+		}
+		return aliases
+	}
+
+	require.Equal(t, []string{"other", "promo-b", "promo_a"}, aliases(storage.ListOptions{}))
+	require.Equal(t, []string{"promo_a", "promo-b", "other"}, aliases(storage.ListOptions{Ascending: true}))
+	require.Equal(t, []string{"other", "promo-b"}, aliases(storage.ListOptions{Limit: 2}))
+	require.Equal(t, []string{"promo_a"}, aliases(storage.ListOptions{After: ids[1]}))
+	require.Equal(t, []string{"other"}, aliases(storage.ListOptions{After: ids[1], Ascending: true}))
+	require.Equal(t, []string{"other", "promo_a"}, aliases(storage.ListOptions{Owner: "key:1"}))
+	require.Equal(t, []string{"other", "promo_a"}, aliases(storage.ListOptions{Domain: "EXAMPLE.com"}))
+	require.Equal(t, []string{"promo-b", "promo_a"}, aliases(storage.ListOptions{AliasPrefix: "promo"}))
+	require.Equal(t, []string{"promo_a"}, aliases(storage.ListOptions{AliasPrefix: "promo_"}))
+	require.Equal(t, []string{"promo_a"}, aliases(storage.ListOptions{Tag: "docs"}))
+	require.Equal(t, []string{"promo_a"}, aliases(storage.ListOptions{Query: "UTM="}))
+	require.Equal(t, []string{"other"}, aliases(storage.ListOptions{Query: "%25"}))
+	require.Empty(t, aliases(storage.ListOptions{Query: "_"}))
+	require.Empty(t, aliases(storage.ListOptions{CreatedAfter: time.Now().Add(time.Hour)}))
+	require.Len(t, aliases(storage.ListOptions{CreatedBefore: time.Now().Add(time.Hour)}), 3)
+	require.Empty(t, aliases(storage.ListOptions{Query: `\`}))
+	require.Equal(t, []string{"promo_a"}, aliases(storage.ListOptions{Owner: "key:1", Tag: "promo"}))
+
+	// Following the cursor visits every link once, in both directions.
+	walk := func(ascending bool) []string {
+		t.Helper()
+
+		walked := []string{}
+		opts := storage.ListOptions{Limit: 2, Ascending: ascending}
+		for {
+			links, err := st.ListURLs(ctx, opts)
+			require.NoError(t, err)
+			if len(links) == 0 {
+				return walked
+			}
+			for _, link := range links {
+				walked = append(walked, link.Alias)
+			}
+			opts.After = links[len(links)-1].ID
+		}
+	}
+	require.Equal(t, []string{"other", "promo-b", "promo_a"}, walk(false))
+	require.Equal(t, []string{"promo_a", "promo-b", "other"}, walk(true))
+
+	_, err = st.UpdateURL(ctx, "other", storage.URLUpdate{URL: "https://golang.org"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"other"}, aliases(storage.ListOptions{Domain: "golang.org"}))
+
+	require.NoError(t, st.DeleteURL(ctx, "promo_a", ""))
+	require.Empty(t, aliases(storage.ListOptions{Tag: "docs"}))
+}
+
+func TestStorage_Batch(t *testing.T) {
+	st, err := memory.New("")
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	_, err = st.SaveURL(ctx, "https://google.com", "taken", storage.SaveOptions{})
+	require.NoError(t, err)
+
+	items := []storage.BatchItem{
+		{URL: "https://a.com", Alias: "batch_a", Opts: storage.SaveOptions{Owner: "key:1", Tags: []string{"promo"}}},
+		{URL: "https://b.com", Alias: "taken"},
+		{URL: "https://c.com", Alias: "batch_a"},
+		{URL: "https://d.com", Alias: "batch_d"},
+	}
+
+	results, err := st.SaveURLs(ctx, items, true)
+	require.NoError(t, err)
+	require.Len(t, results, 4)
+	require.ErrorIs(t, results[0].Err, storage.ErrBatchAborted)
+	require.ErrorIs(t, results[1].Err, storage.ErrURLExists)
+	require.ErrorIs(t, results[2].Err, storage.ErrURLExists)
+	require.ErrorIs(t, results[3].Err, storage.ErrBatchAborted)
+
+	_, err = st.GetURL(ctx, "batch_a")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	results, err = st.SaveURLs(ctx, items, false)
+	require.NoError(t, err)
+	require.NoError(t, results[0].Err)
+	require.ErrorIs(t, results[1].Err, storage.ErrURLExists)
+	require.ErrorIs(t, results[2].Err, storage.ErrURLExists)
+	require.NoError(t, results[3].Err)
+	require.Less(t, results[0].ID, results[3].ID)
+
+	link, err := st.GetLink(ctx, "batch_a", "")
+	require.NoError(t, err)
+	require.Equal(t, results[0].ID, link.ID)
+	require.Equal(t, "https://a.com", link.URL)
+	require.Equal(t, "key:1", link.Owner)
+	require.Equal(t, []string{"promo"}, link.Tags)
+
+	got, err := st.GetURL(ctx, "batch_d")
+	require.NoError(t, err)
+	require.Equal(t, "https://d.com", got)
+
+	results, err = st.SaveURLs(ctx, nil, true)
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
+
+func TestStorage_Expiration(t *testing.T) {
+	st, err := memory.New("")
+	require.NoError(t, err)
+
+	_, err = st.SaveURL(context.Background(), "https://google.com", "expired", storage.SaveOptions{ExpiresAt: time.Now().Add(-time.Minute)})
+	require.NoError(t, err)
+	_, err = st.SaveURL(context.Background(), "https://google.com", "alive", storage.SaveOptions{ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = st.SaveURL(context.Background(), "https://google.com", "forever", storage.SaveOptions{})
+	require.NoError(t, err)
+
+	_, err = st.GetURL(context.Background(), "expired")
+	require.ErrorIs(t, err, storage.ErrURLExpired)
+
+	_, err = st.GetURL(context.Background(), "alive")
+	require.NoError(t, err)
+
+	purged, err := st.PurgeExpired(context.Background(), 100)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), purged)
+
+	_, err = st.GetURL(context.Background(), "expired")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	for _, alias := range []string{"alive", "forever"} {
+		_, err = st.GetURL(context.Background(), alias)
+		require.NoError(t, err)
+	}
+}
+
+func TestStorage_Clicks(t *testing.T) {
+	st, err := memory.New("")
+	require.NoError(t, err)
+
+	_, err = st.SaveURL(context.Background(), "https://google.com", "google", storage.SaveOptions{})
+	require.NoError(t, err)
+
+	now := time.Now().UTC()
+	yesterday := now.AddDate(0, 0, -1)
+	require.NoError(t, st.SaveClicks(context.Background(), []storage.Click{
+		{Alias: "google", ClickedAt: yesterday, Referrer: "https://a.com"},
+		{Alias: "google", ClickedAt: now, Referrer: "https://a.com"},
+		{Alias: "google", ClickedAt: now, Referrer: "https://b.com"},
+		{Alias: "google", ClickedAt: now.AddDate(0, 0, -40)},
+		// Clicks on links that are already gone are skipped.
+		{Alias: "deleted", ClickedAt: now},
+	}))
+
+	stats, err := st.URLStats(context.Background(), "google", "", now.Truncate(24*time.Hour).AddDate(0, 0, -1), 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), stats.TotalClicks)
+	require.Equal(t, []storage.DailyClicks{
+		{Day: yesterday.Format(time.DateOnly), Clicks: 1},
+		{Day: now.Format(time.DateOnly), Clicks: 2},
+	}, stats.ClicksPerDay)
+	require.Equal(t, []storage.ReferrerClicks{{Referrer: "https://a.com", Clicks: 2}}, stats.TopReferrers)
+
+	_, err = st.URLStats(context.Background(), "deleted", "", now, 10)
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	require.NoError(t, st.DeleteURL(context.Background(), "google", ""))
+	_, err = st.SaveURL(context.Background(), "https://google.com", "google", storage.SaveOptions{})
+	require.NoError(t, err)
+
+	stats, err = st.URLStats(context.Background(), "google", "", now.AddDate(0, 0, -1), 10)
+	require.NoError(t, err)
+	require.Zero(t, stats.TotalClicks)
+}
+
+func TestStorage_APIKeys(t *testing.T) {
+	st, err := memory.New("")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	createdAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+
+	id, err := st.SaveAPIKey(ctx, storage.APIKey{
+		Name:      "ci",
+		Prefix:    "usk_abcdefgh",
+		Hash:      "hash-1",
+		Scopes:    []string{"create", "read-stats"},
+		CreatedAt: createdAt,
+	})
+	require.NoError(t, err)
+	require.NotZero(t, id)
+
+	key, err := st.GetAPIKey(ctx, "hash-1")
+	require.NoError(t, err)
+	require.Equal(t, id, key.ID)
+	require.Equal(t, "ci", key.Name)
+	require.Equal(t, []string{"create", "read-stats"}, key.Scopes)
+	require.True(t, createdAt.Equal(key.CreatedAt))
+	require.True(t, key.LastUsedAt.IsZero())
+
+	_, err = st.GetAPIKey(ctx, "unknown")
+	require.ErrorIs(t, err, storage.ErrAPIKeyNotFound)
+
+	usedAt := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, st.TouchAPIKey(ctx, id, usedAt))
+
+	key, err = st.GetAPIKey(ctx, "hash-1")
+	require.NoError(t, err)
+	require.True(t, usedAt.Equal(key.LastUsedAt))
+
+	require.NoError(t, st.RevokeAPIKey(ctx, id))
+	require.ErrorIs(t, st.RevokeAPIKey(ctx, id), storage.ErrAPIKeyNotFound)
+	require.ErrorIs(t, st.RevokeAPIKey(ctx, id+100), storage.ErrAPIKeyNotFound)
+
+	_, err = st.GetAPIKey(ctx, "hash-1")
+	require.ErrorIs(t, err, storage.ErrAPIKeyNotFound)
+
+	keys, err := st.ListAPIKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	require.False(t, keys[0].RevokedAt.IsZero())
+}
+
+func TestStorage_FindURL(t *testing.T) {
+	st, err := memory.New("")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	_, err = st.SaveURL(ctx, "https://Google.com:443", "first", storage.SaveOptions{Owner: "key:1"})
+	require.NoError(t, err)
+	_, err = st.SaveURL(ctx, "https://google.com/", "second", storage.SaveOptions{Owner: "key:1"})
+	require.NoError(t, err)
+	_, err = st.SaveURL(ctx, "https://google.com", "expired", storage.SaveOptions{Owner: "key:2", ExpiresAt: time.Now().Add(-time.Second)})
+	require.NoError(t, err)
+	// Links with an expiry or tags aren't what a plain save would create.
+	_, err = st.SaveURL(ctx, "https://golang.org", "expiring", storage.SaveOptions{Owner: "key:1", ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = st.SaveURL(ctx, "https://golang.org", "tagged", storage.SaveOptions{Owner: "key:1", Tags: []string{"promo"}})
+	require.NoError(t, err)
+
+	link, err := st.FindURL(ctx, "key:1", "HTTPS://google.com")
+	require.NoError(t, err)
+	require.Equal(t, "first", link.Alias)
+
+	_, err = st.FindURL(ctx, "key:1", "https://google.com/search")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	_, err = st.FindURL(ctx, "key:2", "https://google.com")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	_, err = st.FindURL(ctx, "key:1", "https://golang.org")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	_, err = st.SaveURL(ctx, "https://golang.org", "plain", storage.SaveOptions{Owner: "key:1"})
+	require.NoError(t, err)
+
+	link, err = st.FindURL(ctx, "key:1", "https://golang.org")
+	require.NoError(t, err)
+	require.Equal(t, "plain", link.Alias)
+
+	// Unlike elsewhere, an empty owner isn't a wildcard.
+	_, err = st.FindURL(ctx, "", "https://google.com")
+	require.ErrorIs(t, err, storage.ErrURLNotFound)
+}
+
+func TestStorage_IdempotencyKeys(t *testing.T) {
+	st, err := memory.New("")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	rec := storage.IdempotencyRecord{Owner: "key:1", Key: "abc", RequestHash: "hash", ExpiresAt: time.Now().Add(time.Minute)}
+
+	_, err = st.ReserveIdempotencyKey(ctx, rec)
+	require.NoError(t, err)
+
+	existing, err := st.ReserveIdempotencyKey(ctx, rec)
+	require.ErrorIs(t, err, storage.ErrIdempotencyKeyExists)
+	require.Equal(t, "hash", existing.RequestHash)
+	require.Zero(t, existing.Status)
+
+	// Other owners have keys of their own.
+	_, err = st.ReserveIdempotencyKey(ctx, storage.IdempotencyRecord{Owner: "key:2", Key: "abc", ExpiresAt: time.Now().Add(time.Minute)})
+	require.NoError(t, err)
+
+	rec.Status = 201
+	rec.Headers = map[string]string{"Location": "/google"}
+	rec.Body = []byte(`{"status":"OK"}`)
+	rec.ExpiresAt = time.Now().Add(time.Hour)
+	require.NoError(t, st.CompleteIdempotencyKey(ctx, rec))
+
+	// Completed records outlive a release.
+	require.NoError(t, st.ReleaseIdempotencyKey(ctx, "key:1", "abc"))
+
+	existing, err = st.ReserveIdempotencyKey(ctx, rec)
+	require.ErrorIs(t, err, storage.ErrIdempotencyKeyExists)
+	require.Equal(t, 201, existing.Status)
+	require.Equal(t, rec.Headers, existing.Headers)
+	require.Equal(t, rec.Body, existing.Body)
+
+	require.NoError(t, st.ReleaseIdempotencyKey(ctx, "key:2", "abc"))
+	_, err = st.ReserveIdempotencyKey(ctx, storage.IdempotencyRecord{Owner: "key:2", Key: "abc", ExpiresAt: time.Now().Add(-time.Second)})
+	require.NoError(t, err)
+
+	purged, err := st.PurgeIdempotencyKeys(ctx, 10)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), purged)
+
+	// Expired keys can be reused before they are purged.
+	_, err = st.ReserveIdempotencyKey(ctx, storage.IdempotencyRecord{Owner: "key:3", Key: "abc", ExpiresAt: time.Now().Add(-time.Second)})
+	require.NoError(t, err)
+	_, err = st.ReserveIdempotencyKey(ctx, storage.IdempotencyRecord{Owner: "key:3", Key: "abc", RequestHash: "other", ExpiresAt: time.Now().Add(time.Minute)})
+	require.NoError(t, err)
+}
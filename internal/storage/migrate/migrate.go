@@ -0,0 +1,401 @@
+package migrate
+
+import (
+	"URL-shortener/internal/storage"
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations
+var migrationsFS embed.FS
+
+var (
+	ErrNoMigrations     = errors.New("no migrations to roll back")
+	ErrUnknownDriver    = errors.New("driver does not support migrations")
+	ErrUnknownMigration = errors.New("database has a migration this binary does not know")
+
+	// errNotMigrated means schema_migrations doesn't exist, so no
+	// migration has been applied.
+	errNotMigrated = errors.New("schema_migrations does not exist")
+)
+
+// lockID is the key of the Postgres advisory lock held while migrating,
+// so replicas booting at the same time apply migrations one after another.
+const lockID int64 = 0x75726c73686f7274
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+type dialect struct {
+	insertVersion string
+	deleteVersion string
+	hasVersions   string
+	lock          func(ctx context.Context, conn *sql.Conn) error
+	unlock        func(ctx context.Context, conn *sql.Conn) error
+}
+
+var dialects = map[string]dialect{
+	storage.DriverPostgres: {
+		insertVersion: `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+		deleteVersion: `DELETE FROM schema_migrations WHERE version = $1`,
+		hasVersions:   `SELECT to_regclass('schema_migrations') IS NOT NULL`,
+		lock: func(ctx context.Context, conn *sql.Conn) error {
+			_, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID)
+			return err
+		},
+		unlock: func(ctx context.Context, conn *sql.Conn) error {
+			_, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, lockID)
+			return err
+		},
+	},
+	// SQLite serializes writers on the database file itself,
+	// every migration runs in its own transaction so no extra lock is needed.
+	storage.DriverSQLite: {
+		insertVersion: `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`,
+		deleteVersion: `DELETE FROM schema_migrations WHERE version = ?`,
+		hasVersions:   `SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')`,
+		lock:          func(context.Context, *sql.Conn) error { return nil },
+		unlock:        func(context.Context, *sql.Conn) error { return nil },
+	},
+}
+
+const createVersionsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+`
+
+// Migrator applies the migrations embedded in the binary for one storage driver.
+type Migrator struct {
+	db         *sql.DB
+	dialect    dialect
+	migrations []Migration
+}
+
+func New(db *sql.DB, driver string) (*Migrator, error) {
+	const op = "storage.migrate.New"
+
+	if db == nil {
+		return nil, fmt.Errorf("%s: db is nil", op)
+	}
+
+	d, ok := dialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w: %s", op, ErrUnknownDriver, driver)
+	}
+
+	migrations, err := load(driver)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return &Migrator{db: db, dialect: d, migrations: migrations}, nil
+}
+
+// Latest returns the version of the newest embedded migration.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the version of the newest migration applied to the
+// database, zero if none is. It only reads, so it is safe to call from
+// probes and against read-only replicas.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	const op = "storage.migrate.Version"
+
+	var version sql.NullInt64
+	err := m.withConn(ctx, func(conn *sql.Conn) error {
+		return conn.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&version)
+	})
+	if errors.Is(err, errNotMigrated) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return int(version.Int64), nil
+}
+
+// Up applies every pending migration in order and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	const op = "storage.migrate.Up"
+
+	applied := 0
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, mig := range m.migrations {
+			if _, ok := done[mig.Version]; ok {
+				continue
+			}
+
+			if err := m.apply(ctx, conn, mig.Up, m.dialect.insertVersion, mig.Version, mig.Name); err != nil {
+				return fmt.Errorf("apply %04d_%s: %w", mig.Version, mig.Name, err)
+			}
+			applied++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return applied, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return applied, nil
+}
+
+// Down rolls back the newest applied migration and returns it.
+func (m *Migrator) Down(ctx context.Context) (Migration, error) {
+	const op = "storage.migrate.Down"
+
+	var rolledBack Migration
+
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		done, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		latest := 0
+		for version := range done {
+			if version > latest {
+				latest = version
+			}
+		}
+		if latest == 0 {
+			return ErrNoMigrations
+		}
+
+		mig, ok := m.find(latest)
+		if !ok {
+			return fmt.Errorf("%w: %d", ErrUnknownMigration, latest)
+		}
+
+		if err := m.apply(ctx, conn, mig.Down, m.dialect.deleteVersion, mig.Version); err != nil {
+			return fmt.Errorf("roll back %04d_%s: %w", mig.Version, mig.Name, err)
+		}
+		rolledBack = mig
+
+		return nil
+	})
+	if err != nil {
+		return Migration{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return rolledBack, nil
+}
+
+// Status lists every embedded migration together with whether it is
+// applied. Like Version it only reads.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	const op = "storage.migrate.Status"
+
+	var done map[int]time.Time
+
+	err := m.withConn(ctx, func(conn *sql.Conn) error {
+		var err error
+		done, err = appliedVersions(ctx, conn)
+		return err
+	})
+	if err != nil && !errors.Is(err, errNotMigrated) {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		appliedAt, ok := done[mig.Version]
+		statuses = append(statuses, Status{Migration: mig, Applied: ok, AppliedAt: appliedAt})
+	}
+
+	return statuses, nil
+}
+
+func (m *Migrator) find(version int) (Migration, bool) {
+	for _, mig := range m.migrations {
+		if mig.Version == version {
+			return mig, true
+		}
+	}
+
+	return Migration{}, false
+}
+
+// apply runs a migration script and records the change in schema_migrations
+// within a single transaction.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, script, record string, args ...any) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if strings.TrimSpace(script) != "" {
+		if _, err := tx.ExecContext(ctx, script); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// withConn runs fn for reading schema_migrations without changing the
+// schema. It fails with errNotMigrated when the table doesn't exist yet.
+func (m *Migrator) withConn(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("get connection: %w", err)
+	}
+	defer conn.Close()
+
+	var exists bool
+	if err := conn.QueryRowContext(ctx, m.dialect.hasVersions).Scan(&exists); err != nil {
+		return fmt.Errorf("look up schema_migrations: %w", err)
+	}
+	if !exists {
+		return errNotMigrated
+	}
+
+	return fn(conn)
+}
+
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if err := m.dialect.lock(ctx, conn); err != nil {
+		return fmt.Errorf("acquire lock: %w", err)
+	}
+	defer func() { _ = m.dialect.unlock(context.Background(), conn) }()
+
+	if _, err := conn.ExecContext(ctx, createVersionsTable); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	done := make(map[int]time.Time)
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		done[version] = appliedAt
+	}
+
+	return done, rows.Err()
+}
+
+// load reads migrations/<driver>/NNNN_name.{up,down}.sql from the embedded FS.
+func load(driver string) ([]Migration, error) {
+	dir := path.Join("migrations", driver)
+
+	entries, err := fs.ReadDir(migrationsFS, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		name := entry.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		rawVersion, migName, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("invalid migration file name %q", name)
+		}
+
+		version, err := strconv.Atoi(rawVersion)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version in %q", name)
+		}
+
+		body, err := fs.ReadFile(migrationsFS, path.Join(dir, name))
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", name, err)
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: migName}
+			byVersion[version] = mig
+		}
+
+		if direction == "up" {
+			mig.Up = string(body)
+		} else {
+			mig.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, fmt.Errorf("migration %04d_%s has no up script", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
@@ -0,0 +1,117 @@
+package migrate_test
+
+import (
+	"URL-shortener/internal/storage"
+	"URL-shortener/internal/storage/migrate"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "storage.db")+"?_foreign_keys=on")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	m, err := migrate.New(db, storage.DriverSQLite)
+	require.NoError(t, err)
+	require.NotZero(t, m.Latest())
+
+	version, err := m.Version(ctx)
+	require.NoError(t, err)
+	require.Zero(t, version)
+
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, m.Latest())
+	for _, st := range statuses {
+		require.False(t, st.Applied, "migration %d", st.Version)
+	}
+
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+	require.Equal(t, m.Latest(), applied)
+
+	// Applying again is a no-op.
+	applied, err = m.Up(ctx)
+	require.NoError(t, err)
+	require.Zero(t, applied)
+
+	version, err = m.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, m.Latest(), version)
+
+	statuses, err = m.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, m.Latest())
+	for _, st := range statuses {
+		require.True(t, st.Applied, "migration %d", st.Version)
+	}
+
+	for i := m.Latest(); i > 0; i-- {
+		mig, err := m.Down(ctx)
+		require.NoError(t, err)
+		require.Equal(t, i, mig.Version)
+	}
+
+	_, err = m.Down(ctx)
+	require.ErrorIs(t, err, migrate.ErrNoMigrations)
+
+	var tables int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'url'`).Scan(&tables))
+	require.Zero(t, tables)
+}
+
+func TestMigrator_ReadOnly(t *testing.T) {
+	ctx := context.Background()
+
+	path := filepath.Join(t.TempDir(), "storage.db")
+
+	rw, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = rw.Close() })
+	require.NoError(t, rw.Ping())
+
+	ro, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ro.Close() })
+
+	m, err := migrate.New(ro, storage.DriverSQLite)
+	require.NoError(t, err)
+
+	// Reading an unmigrated database neither fails nor creates tables.
+	version, err := m.Version(ctx)
+	require.NoError(t, err)
+	require.Zero(t, version)
+
+	_, err = m.Status(ctx)
+	require.NoError(t, err)
+
+	var tables int
+	require.NoError(t, rw.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'`).Scan(&tables))
+	require.Zero(t, tables)
+
+	up, err := migrate.New(rw, storage.DriverSQLite)
+	require.NoError(t, err)
+	_, err = up.Up(ctx)
+	require.NoError(t, err)
+
+	version, err = m.Version(ctx)
+	require.NoError(t, err)
+	require.Equal(t, m.Latest(), version)
+}
+
+func TestNew_UnknownDriver(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	_, err = migrate.New(db, storage.DriverMemory)
+	require.ErrorIs(t, err, migrate.ErrUnknownDriver)
+}
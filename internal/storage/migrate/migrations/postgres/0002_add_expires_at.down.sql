@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_url_expires_at;
+
+ALTER TABLE public.url DROP COLUMN IF EXISTS expires_at;
@@ -0,0 +1,3 @@
+ALTER TABLE public.url ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_url_expires_at ON public.url(expires_at) WHERE expires_at IS NOT NULL;
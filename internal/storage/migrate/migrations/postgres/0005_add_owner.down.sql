@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_url_owner;
+
+ALTER TABLE public.url DROP COLUMN IF EXISTS owner;
@@ -0,0 +1,3 @@
+ALTER TABLE public.url ADD COLUMN IF NOT EXISTS owner TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_url_owner ON public.url(owner);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS public.url_history;
+
+ALTER TABLE public.url DROP COLUMN IF EXISTS version;
@@ -0,0 +1,12 @@
+ALTER TABLE public.url ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+CREATE TABLE IF NOT EXISTS public.url_history (
+    id BIGSERIAL PRIMARY KEY,
+    url_id INTEGER NOT NULL REFERENCES public.url(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    version BIGINT NOT NULL,
+    changed_by TEXT NOT NULL DEFAULT '',
+    changed_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_url_history_url_id ON public.url_history(url_id);
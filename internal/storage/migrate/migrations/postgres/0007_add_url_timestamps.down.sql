@@ -0,0 +1,2 @@
+ALTER TABLE public.url DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE public.url DROP COLUMN IF EXISTS created_at;
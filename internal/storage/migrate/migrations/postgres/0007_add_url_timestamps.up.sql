@@ -0,0 +1,3 @@
+-- Links created before this migration keep NULL timestamps, their age is unknown.
+ALTER TABLE public.url ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ;
+ALTER TABLE public.url ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
@@ -0,0 +1,10 @@
+DROP INDEX IF EXISTS idx_url_url_trgm;
+DROP INDEX IF EXISTS idx_url_alias_pattern;
+DROP INDEX IF EXISTS idx_url_created_at;
+CREATE INDEX IF NOT EXISTS idx_url_owner ON public.url(owner);
+DROP INDEX IF EXISTS idx_url_owner_id;
+DROP INDEX IF EXISTS idx_url_domain_id;
+
+DROP TABLE IF EXISTS public.url_tags;
+
+ALTER TABLE public.url DROP COLUMN IF EXISTS domain;
@@ -0,0 +1,30 @@
+-- domain is the lower-cased host of the destination, for the listing filter.
+ALTER TABLE public.url ADD COLUMN IF NOT EXISTS domain TEXT NOT NULL DEFAULT '';
+
+UPDATE public.url SET domain = lower(coalesce(substring(url from '^[^:/?#]+://(?:[^/?#@]*@)?([^/?#:]+)'), ''));
+
+CREATE TABLE IF NOT EXISTS public.url_tags (
+    url_id INTEGER NOT NULL REFERENCES public.url(id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (url_id, tag)
+);
+
+-- Every listing filter has an index that ends in id, so a page is
+-- read in id order and stops after the limit.
+CREATE INDEX IF NOT EXISTS idx_url_tags_tag_url_id ON public.url_tags(tag, url_id);
+CREATE INDEX IF NOT EXISTS idx_url_domain_id ON public.url(domain, id);
+CREATE INDEX IF NOT EXISTS idx_url_owner_id ON public.url(owner, id);
+DROP INDEX IF EXISTS idx_url_owner;
+CREATE INDEX IF NOT EXISTS idx_url_created_at ON public.url(created_at);
+CREATE INDEX IF NOT EXISTS idx_url_alias_pattern ON public.url(alias text_pattern_ops);
+
+-- Substring search on the destination works without an index. Creating
+-- pg_trgm needs rights an app role usually lacks, so the index is only
+-- built when an operator has installed the extension, see README.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_trgm') THEN
+        CREATE INDEX IF NOT EXISTS idx_url_url_trgm ON public.url USING gin (url gin_trgm_ops);
+    END IF;
+END
+$$;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_url_owner;
+
+ALTER TABLE url DROP COLUMN owner;
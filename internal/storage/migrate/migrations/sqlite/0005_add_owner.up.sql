@@ -0,0 +1,3 @@
+ALTER TABLE url ADD COLUMN owner TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_url_owner ON url(owner);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS url_history;
+
+ALTER TABLE url DROP COLUMN version;
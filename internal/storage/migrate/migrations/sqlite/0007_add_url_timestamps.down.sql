@@ -0,0 +1,2 @@
+ALTER TABLE url DROP COLUMN updated_at;
+ALTER TABLE url DROP COLUMN created_at;
@@ -0,0 +1,3 @@
+-- Links created before this migration keep NULL timestamps, their age is unknown.
+ALTER TABLE url ADD COLUMN created_at TIMESTAMP;
+ALTER TABLE url ADD COLUMN updated_at TIMESTAMP;
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_url_created_at;
+CREATE INDEX IF NOT EXISTS idx_url_owner ON url(owner);
+DROP INDEX IF EXISTS idx_url_owner_id;
+DROP INDEX IF EXISTS idx_url_domain_id;
+
+DROP TABLE IF EXISTS url_tags;
+
+ALTER TABLE url DROP COLUMN domain;
@@ -0,0 +1,26 @@
+-- domain is the lower-cased host of the destination, for the listing filter.
+ALTER TABLE url ADD COLUMN domain TEXT NOT NULL DEFAULT '';
+
+-- SQLite has no regular expressions, so the host is cut out step by step:
+-- drop the scheme, then everything from the path, query or fragment on,
+-- then the user info and the port.
+UPDATE url SET domain = lower(substr(url, instr(url, '://') + 3)) WHERE instr(url, '://') > 0;
+UPDATE url SET domain = substr(domain, 1, instr(domain, '/') - 1) WHERE instr(domain, '/') > 0;
+UPDATE url SET domain = substr(domain, 1, instr(domain, '?') - 1) WHERE instr(domain, '?') > 0;
+UPDATE url SET domain = substr(domain, 1, instr(domain, '#') - 1) WHERE instr(domain, '#') > 0;
+UPDATE url SET domain = substr(domain, instr(domain, '@') + 1) WHERE instr(domain, '@') > 0;
+UPDATE url SET domain = substr(domain, 1, instr(domain, ':') - 1) WHERE instr(domain, ':') > 0;
+
+CREATE TABLE IF NOT EXISTS url_tags (
+    url_id INTEGER NOT NULL REFERENCES url(id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (url_id, tag)
+);
+
+-- Every listing filter has an index that ends in id, so a page is
+-- read in id order and stops after the limit.
+CREATE INDEX IF NOT EXISTS idx_url_tags_tag_url_id ON url_tags(tag, url_id);
+CREATE INDEX IF NOT EXISTS idx_url_domain_id ON url(domain, id);
+CREATE INDEX IF NOT EXISTS idx_url_owner_id ON url(owner, id);
+DROP INDEX IF EXISTS idx_url_owner;
+CREATE INDEX IF NOT EXISTS idx_url_created_at ON url(created_at);
@@ -0,0 +1,1070 @@
+package sqlite
+
+import (
+	"URL-shortener/internal/storage"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+type Storage struct {
+	db *sql.DB
+
+	saveStmt   *sql.Stmt
+	getStmt    *sql.Stmt
+	deleteStmt *sql.Stmt
+}
+
+var _ storage.Backend = (*Storage)(nil)
+
+// New wraps an open SQLite connection and prepares the statements
+// used on every request. The schema is expected to be up to date,
+// see the migrate package.
+func New(db *sql.DB) (*Storage, error) {
+	const op = "storage.sqlite.New"
+
+	if db == nil {
+		return nil, fmt.Errorf("%s: db is nil", op)
+	}
+
+	s := &Storage{db: db}
+
+	stmts := []struct {
+		dst   **sql.Stmt
+		query string
+	}{
+		{&s.saveStmt, `INSERT INTO url (url, alias, expires_at, owner, domain, created_at, updated_at) VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?6)`},
+		{&s.getStmt, `SELECT url, expires_at FROM url WHERE alias = ?`},
+		{&s.deleteStmt, `DELETE FROM url WHERE alias = ?1 AND (?2 = '' OR owner = ?2)`},
+	}
+	for _, st := range stmts {
+		stmt, err := db.Prepare(st.query)
+		if err != nil {
+			_ = s.Close()
+			return nil, fmt.Errorf("%s: prepare: %w", op, err)
+		}
+		*st.dst = stmt
+	}
+
+	return s, nil
+}
+
+// Close releases the prepared statements. The connection pool
+// belongs to the caller and stays open.
+func (s *Storage) Close() error {
+	var errs []error
+	for _, stmt := range []*sql.Stmt{s.saveStmt, s.getStmt, s.deleteStmt} {
+		if stmt != nil {
+			errs = append(errs, stmt.Close())
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// DBStats returns the connection pool statistics.
+func (s *Storage) DBStats() sql.DBStats {
+	return s.db.Stats()
+}
+
+// SaveURL inserts a new URL and alias with its tags, returning the generated id.
+func (s *Storage) SaveURL(ctx context.Context, urlToSave string, alias string, opts storage.SaveOptions) (_ int64, err error) {
+	const op = "storage.sqlite.SaveURL"
+
+	ctx, span := storage.StartSpan(ctx, op)
+	defer func() { storage.EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return 0, fmt.Errorf("%s: db is nil", op)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: begin: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.StmtContext(ctx, s.saveStmt).ExecContext(ctx,
+		urlToSave, alias, nullTime(opts.ExpiresAt), opts.Owner, storage.Domain(urlToSave), time.Now().UTC())
+	if err != nil {
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return 0, fmt.Errorf("%s: %w", op, storage.ErrURLExists)
+		}
+		return 0, fmt.Errorf("%s: insert: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: get last insert id: %w", op, err)
+	}
+
+	for _, tag := range opts.Tags {
+		if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO url_tags (url_id, tag) VALUES (?, ?)`, id, tag); err != nil {
+			return 0, fmt.Errorf("%s: insert tag: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// SaveURLs inserts a batch of links in a single transaction. Taken
+// aliases are skipped, and with atomic any of them rolls the whole
+// batch back.
+func (s *Storage) SaveURLs(ctx context.Context, items []storage.BatchItem, atomic bool) (_ []storage.BatchResult, err error) {
+	const op = "storage.sqlite.SaveURLs"
+
+	ctx, span := storage.StartSpan(ctx, op)
+	defer func() { storage.EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return nil, fmt.Errorf("%s: db is nil", op)
+	}
+
+	results := make([]storage.BatchResult, len(items))
+	if len(items) == 0 {
+		return results, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: begin: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	saveStmt, err := tx.PrepareContext(ctx, `
+INSERT INTO url (url, alias, expires_at, owner, domain, created_at, updated_at) VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?6)
+ON CONFLICT (alias) DO NOTHING`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: prepare: %w", op, err)
+	}
+	defer saveStmt.Close()
+
+	tagStmt, err := tx.PrepareContext(ctx, `INSERT OR IGNORE INTO url_tags (url_id, tag) VALUES (?, ?)`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: prepare tags: %w", op, err)
+	}
+	defer tagStmt.Close()
+
+	now := time.Now().UTC()
+	failed := false
+
+	for i, item := range items {
+		res, err := saveStmt.ExecContext(ctx,
+			item.URL, item.Alias, nullTime(item.Opts.ExpiresAt), item.Opts.Owner, storage.Domain(item.URL), now)
+		if err != nil {
+			return nil, fmt.Errorf("%s: insert: %w", op, err)
+		}
+
+		if n, err := res.RowsAffected(); err != nil {
+			return nil, fmt.Errorf("%s: rows affected: %w", op, err)
+		} else if n == 0 {
+			results[i].Err = storage.ErrURLExists
+			failed = true
+			continue
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, fmt.Errorf("%s: get last insert id: %w", op, err)
+		}
+		results[i].ID = id
+
+		for _, tag := range item.Opts.Tags {
+			if _, err := tagStmt.ExecContext(ctx, id, tag); err != nil {
+				return nil, fmt.Errorf("%s: insert tag: %w", op, err)
+			}
+		}
+	}
+
+	if failed && atomic {
+		storage.AbortBatch(results)
+		return results, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return results, nil
+}
+
+func (s *Storage) GetURL(ctx context.Context, alias string) (_ string, err error) {
+	const op = "storage.sqlite.GetURL"
+
+	ctx, span := storage.StartSpan(ctx, op)
+	defer func() { storage.EndSpan(span, err) }()
+
+	resURL, _, err := s.resolve(ctx, alias)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return resURL, nil
+}
+
+// ResolveURL is GetURL that also returns when the link expires,
+// zero if never.
+func (s *Storage) ResolveURL(ctx context.Context, alias string) (_ string, _ time.Time, err error) {
+	const op = "storage.sqlite.ResolveURL"
+
+	ctx, span := storage.StartSpan(ctx, op)
+	defer func() { storage.EndSpan(span, err) }()
+
+	resURL, expiresAt, err := s.resolve(ctx, alias)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return resURL, expiresAt, nil
+}
+
+func (s *Storage) resolve(ctx context.Context, alias string) (string, time.Time, error) {
+	if s == nil || s.db == nil {
+		return "", time.Time{}, errors.New("db is nil")
+	}
+
+	var resURL string
+	var expiresAt sql.NullTime
+	err := s.getStmt.QueryRowContext(ctx, alias).Scan(&resURL, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", time.Time{}, storage.ErrURLNotFound
+	}
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("scan: %w", err)
+	}
+	if expiresAt.Valid && !expiresAt.Time.After(time.Now()) {
+		return "", time.Time{}, storage.ErrURLExpired
+	}
+
+	return resURL, expiresAt.Time, nil
+}
+
+// GetLink returns the link with its metadata, expired links included.
+func (s *Storage) GetLink(ctx context.Context, alias string, owner string) (_ storage.Link, err error) {
+	const op = "storage.sqlite.GetLink"
+
+	ctx, span := storage.StartSpan(ctx, op)
+	defer func() { storage.EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return storage.Link{}, fmt.Errorf("%s: db is nil", op)
+	}
+
+	link, err := scanLink(s.db.QueryRowContext(ctx, `SELECT `+linkColumns+` FROM url u WHERE u.alias = ?`, alias))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Link{}, fmt.Errorf("%s: %w", op, storage.ErrURLNotFound)
+	}
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: scan: %w", op, err)
+	}
+	if owner != "" && link.Owner != owner {
+		return storage.Link{}, fmt.Errorf("%s: %w", op, storage.ErrNotOwner)
+	}
+
+	return link, nil
+}
+
+// FindURL returns the oldest link of owner that never expires, has no
+// tags and whose destination normalizes like rawURL, see
+// storage.NormalizeURL. Unlike elsewhere, an empty owner only matches
+// links without one.
+func (s *Storage) FindURL(ctx context.Context, owner string, rawURL string) (_ storage.Link, err error) {
+	const op = "storage.sqlite.FindURL"
+
+	ctx, span := storage.StartSpan(ctx, op)
+	defer func() { storage.EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return storage.Link{}, fmt.Errorf("%s: db is nil", op)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT `+linkColumns+` FROM url u
+WHERE u.owner = ? AND u.domain = ? AND u.expires_at IS NULL
+    AND NOT EXISTS (SELECT 1 FROM url_tags t WHERE t.url_id = u.id)
+ORDER BY u.id`, owner, storage.Domain(rawURL))
+	if err != nil {
+		return storage.Link{}, fmt.Errorf("%s: query: %w", op, err)
+	}
+	defer rows.Close()
+
+	want := storage.NormalizeURL(rawURL)
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return storage.Link{}, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		if storage.NormalizeURL(link.URL) == want {
+			return link, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return storage.Link{}, fmt.Errorf("%s: rows: %w", op, err)
+	}
+
+	return storage.Link{}, fmt.Errorf("%s: %w", op, storage.ErrURLNotFound)
+}
+
+// ListURLs returns a page of links in id order, continuing after the
+// last id of the previous page. The URL search can't use an index in
+// SQLite and scans the links in id order until the page is full.
+func (s *Storage) ListURLs(ctx context.Context, opts storage.ListOptions) (_ []storage.Link, err error) {
+	const op = "storage.sqlite.ListURLs"
+
+	ctx, span := storage.StartSpan(ctx, op)
+	defer func() { storage.EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return nil, fmt.Errorf("%s: db is nil", op)
+	}
+
+	var (
+		where []string
+		args  []any
+	)
+
+	order, after := "DESC", "<"
+	if opts.Ascending {
+		order, after = "ASC", ">"
+	}
+	if opts.After != 0 {
+		where = append(where, "u.id "+after+" ?")
+		args = append(args, opts.After)
+	}
+	if opts.Owner != "" {
+		where = append(where, "u.owner = ?")
+		args = append(args, opts.Owner)
+	}
+	if !opts.CreatedAfter.IsZero() {
+		where = append(where, "u.created_at >= ?")
+		args = append(args, nullTime(opts.CreatedAfter))
+	}
+	if !opts.CreatedBefore.IsZero() {
+		where = append(where, "u.created_at < ?")
+		args = append(args, nullTime(opts.CreatedBefore))
+	}
+	if opts.Domain != "" {
+		where = append(where, "u.domain = ?")
+		args = append(args, strings.ToLower(opts.Domain))
+	}
+	if opts.AliasPrefix != "" {
+		// A range on the alias index, LIKE ignores case and can't use it.
+		where = append(where, "u.alias >= ? AND u.alias < ?")
+		args = append(args, opts.AliasPrefix, opts.AliasPrefix+string(utf8.MaxRune))
+	}
+	if opts.Tag != "" {
+		where = append(where, "EXISTS (SELECT 1 FROM url_tags t WHERE t.url_id = u.id AND t.tag = ?)")
+		args = append(args, opts.Tag)
+	}
+	if opts.Query != "" {
+		where = append(where, `u.url LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+likeEscaper.Replace(opts.Query)+"%")
+	}
+
+	query := `SELECT ` + linkColumns + ` FROM url u`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY u.id ` + order + ` LIMIT ?`
+	args = append(args, opts.Limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: query: %w", op, err)
+	}
+	defer rows.Close()
+
+	links := []storage.Link{}
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows: %w", op, err)
+	}
+
+	return links, nil
+}
+
+// linkColumns are the columns of url u read by scanLink.
+const linkColumns = `u.id, u.alias, u.url, u.owner, u.version, u.created_at, u.updated_at, u.expires_at,
+    (SELECT COUNT(*) FROM clicks c WHERE c.url_id = u.id),
+    (SELECT COALESCE(group_concat(t.tag, ','), '') FROM url_tags t WHERE t.url_id = u.id)`
+
+func scanLink(row interface{ Scan(dest ...any) error }) (storage.Link, error) {
+	var (
+		link                            storage.Link
+		createdAt, updatedAt, expiresAt sql.NullTime
+		tags                            string
+	)
+	err := row.Scan(&link.ID, &link.Alias, &link.URL, &link.Owner, &link.Version,
+		&createdAt, &updatedAt, &expiresAt, &link.Clicks, &tags)
+	if err != nil {
+		return storage.Link{}, err
+	}
+
+	link.CreatedAt = createdAt.Time
+	link.UpdatedAt = updatedAt.Time
+	link.ExpiresAt = expiresAt.Time
+	link.Tags = storage.SplitTags(tags)
+
+	return link, nil
+}
+
+// likeEscaper escapes the LIKE wildcards, for use with ESCAPE '\'.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// UpdateURL points the link to upd.URL and returns its new version.
+// The previous destination is added to the history in the same
+// transaction. Its first statement is a write, so the transaction
+// holds the write lock from the checks to the update.
+func (s *Storage) UpdateURL(ctx context.Context, alias string, upd storage.URLUpdate) (_ int64, err error) {
+	const op = "storage.sqlite.UpdateURL"
+
+	ctx, span := storage.StartSpan(ctx, op)
+	defer func() { storage.EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return 0, fmt.Errorf("%s: db is nil", op)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: begin: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `
+INSERT INTO url_history (url_id, url, version, changed_by, changed_at)
+SELECT id, url, version, ?4, ?5 FROM url
+WHERE alias = ?1 AND (?2 = '' OR owner = ?2) AND (?3 = 0 OR version = ?3)`,
+		alias, upd.Owner, upd.Version, upd.ChangedBy, time.Now().UTC())
+	if err != nil {
+		return 0, fmt.Errorf("%s: save history: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: get rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return 0, fmt.Errorf("%s: %w", op, updateConflict(ctx, tx, alias, upd.Owner))
+	}
+
+	var id, version int64
+	err = tx.QueryRowContext(ctx, `UPDATE url SET url = ?, domain = ?, version = version + 1, updated_at = ? WHERE alias = ? RETURNING id, version`,
+		upd.URL, storage.Domain(upd.URL), time.Now().UTC(), alias).Scan(&id, &version)
+	if err != nil {
+		return 0, fmt.Errorf("%s: update: %w", op, err)
+	}
+
+	if upd.SetMeta {
+		if _, err := tx.ExecContext(ctx, `UPDATE url SET expires_at = ? WHERE id = ?`, nullTime(upd.ExpiresAt), id); err != nil {
+			return 0, fmt.Errorf("%s: update expiry: %w", op, err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM url_tags WHERE url_id = ?`, id); err != nil {
+			return 0, fmt.Errorf("%s: delete tags: %w", op, err)
+		}
+		for _, tag := range upd.Tags {
+			if _, err := tx.ExecContext(ctx, `INSERT OR IGNORE INTO url_tags (url_id, tag) VALUES (?, ?)`, id, tag); err != nil {
+				return 0, fmt.Errorf("%s: insert tags: %w", op, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return version, nil
+}
+
+// updateConflict tells why an update of alias matched no row:
+// the link is missing, belongs to someone else or has a newer version.
+func updateConflict(ctx context.Context, tx *sql.Tx, alias string, owner string) error {
+	var linkOwner string
+	err := tx.QueryRowContext(ctx, `SELECT owner FROM url WHERE alias = ?`, alias).Scan(&linkOwner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.ErrURLNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("check version: %w", err)
+	}
+	if owner != "" && linkOwner != owner {
+		return storage.ErrNotOwner
+	}
+
+	return storage.ErrVersionMismatch
+}
+
+// DeleteURL deletes the link if it belongs to owner. The ownership check
+// is part of the DELETE, so a link can't change hands in between.
+func (s *Storage) DeleteURL(ctx context.Context, alias string, owner string) (err error) {
+	const op = "storage.sqlite.DeleteURL"
+
+	ctx, span := storage.StartSpan(ctx, op)
+	defer func() { storage.EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return fmt.Errorf("%s: db is nil", op)
+	}
+
+	res, err := s.deleteStmt.ExecContext(ctx, alias, owner)
+	if err != nil {
+		return fmt.Errorf("%s: delete: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: get rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, s.missingOrForeign(ctx, alias))
+	}
+
+	return nil
+}
+
+// missingOrForeign tells why nothing matched alias and its owner.
+// It only picks the error, the decision has been made already.
+func (s *Storage) missingOrForeign(ctx context.Context, alias string) error {
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM url WHERE alias = ?)`, alias).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("check owner: %w", err)
+	}
+	if exists {
+		return storage.ErrNotOwner
+	}
+
+	return storage.ErrURLNotFound
+}
+
+// PurgeExpired deletes up to limit expired links and returns how many were deleted.
+func (s *Storage) PurgeExpired(ctx context.Context, limit int) (_ int64, err error) {
+	const op = "storage.sqlite.PurgeExpired"
+
+	ctx, span := storage.StartSpan(ctx, op)
+	defer func() { storage.EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return 0, fmt.Errorf("%s: db is nil", op)
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+DELETE FROM url WHERE id IN (
+    SELECT id FROM url WHERE expires_at <= ? LIMIT ?
+)`, time.Now().UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: delete: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: get rows affected: %w", op, err)
+	}
+
+	return rowsAffected, nil
+}
+
+// nullTime stores times in UTC, so they compare correctly as text in SQLite.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t.UTC(), Valid: !t.IsZero()}
+}
+
+// SaveClicks stores a batch of click events in a single transaction.
+// Clicks on aliases that no longer exist are skipped.
+func (s *Storage) SaveClicks(ctx context.Context, clicks []storage.Click) (err error) {
+	const op = "storage.sqlite.SaveClicks"
+
+	ctx, span := storage.StartSpan(ctx, op)
+	defer func() { storage.EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return fmt.Errorf("%s: db is nil", op)
+	}
+
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%s: begin: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.PrepareContext(ctx, `
+INSERT INTO clicks (url_id, clicked_at, referrer, user_agent, request_id)
+SELECT id, ?, ?, ?, ? FROM url WHERE alias = ?`)
+	if err != nil {
+		return fmt.Errorf("%s: prepare: %w", op, err)
+	}
+	defer stmt.Close()
+
+	for _, c := range clicks {
+		if _, err := stmt.ExecContext(ctx, c.ClickedAt.UTC(), c.Referrer, c.UserAgent, c.RequestID, c.Alias); err != nil {
+			return fmt.Errorf("%s: insert: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return nil
+}
+
+// URLStats returns click statistics of a link, with per-day counts since the given time.
+func (s *Storage) URLStats(ctx context.Context, alias string, owner string, since time.Time, topReferrers int) (_ storage.Stats, err error) {
+	const op = "storage.sqlite.URLStats"
+
+	ctx, span := storage.StartSpan(ctx, op)
+	defer func() { storage.EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return storage.Stats{}, fmt.Errorf("%s: db is nil", op)
+	}
+
+	var stats storage.Stats
+	var urlID int64
+	var urlOwner string
+
+	err = s.db.QueryRowContext(ctx, `
+SELECT u.id, u.owner, (SELECT COUNT(*) FROM clicks c WHERE c.url_id = u.id)
+FROM url u WHERE u.alias = ?`, alias).Scan(&urlID, &urlOwner, &stats.TotalClicks)
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.Stats{}, fmt.Errorf("%s: %w", op, storage.ErrURLNotFound)
+	}
+	if err != nil {
+		return storage.Stats{}, fmt.Errorf("%s: total: %w", op, err)
+	}
+	if owner != "" && urlOwner != owner {
+		return storage.Stats{}, fmt.Errorf("%s: %w", op, storage.ErrNotOwner)
+	}
+
+	// clicked_at is stored as UTC text, so its first 10 characters are the date.
+	rows, err := s.db.QueryContext(ctx, `
+SELECT substr(clicked_at, 1, 10) AS day, COUNT(*)
+FROM clicks
+WHERE url_id = ? AND clicked_at >= ?
+GROUP BY day
+ORDER BY day`, urlID, since.UTC())
+	if err != nil {
+		return storage.Stats{}, fmt.Errorf("%s: per day: %w", op, err)
+	}
+	stats.ClicksPerDay, err = scanDailyClicks(rows)
+	if err != nil {
+		return storage.Stats{}, fmt.Errorf("%s: per day: %w", op, err)
+	}
+
+	rows, err = s.db.QueryContext(ctx, `
+SELECT referrer, COUNT(*) AS clicks
+FROM clicks
+WHERE url_id = ? AND referrer <> ''
+GROUP BY referrer
+ORDER BY clicks DESC, referrer
+LIMIT ?`, urlID, topReferrers)
+	if err != nil {
+		return storage.Stats{}, fmt.Errorf("%s: referrers: %w", op, err)
+	}
+	stats.TopReferrers, err = scanReferrerClicks(rows)
+	if err != nil {
+		return storage.Stats{}, fmt.Errorf("%s: referrers: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+// URLHistory returns the previous destinations of a link, newest first.
+func (s *Storage) URLHistory(ctx context.Context, alias string, owner string) (_ []storage.Revision, err error) {
+	const op = "storage.sqlite.URLHistory"
+
+	ctx, span := storage.StartSpan(ctx, op)
+	defer func() { storage.EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return nil, fmt.Errorf("%s: db is nil", op)
+	}
+
+	var urlID int64
+	var urlOwner string
+
+	err = s.db.QueryRowContext(ctx, `SELECT id, owner FROM url WHERE alias = ?`, alias).Scan(&urlID, &urlOwner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrURLNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: select url: %w", op, err)
+	}
+	if owner != "" && urlOwner != owner {
+		return nil, fmt.Errorf("%s: %w", op, storage.ErrNotOwner)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT url, version, changed_by, changed_at
+FROM url_history
+WHERE url_id = ?
+ORDER BY version DESC`, urlID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: select: %w", op, err)
+	}
+	history, err := scanRevisions(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: scan: %w", op, err)
+	}
+
+	return history, nil
+}
+
+// SaveAPIKey stores a new API key, returning the generated id.
+func (s *Storage) SaveAPIKey(ctx context.Context, key storage.APIKey) (_ int64, err error) {
+	const op = "storage.sqlite.SaveAPIKey"
+
+	ctx, span := storage.StartSpan(ctx, op)
+	defer func() { storage.EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return 0, fmt.Errorf("%s: db is nil", op)
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+INSERT INTO api_keys (name, prefix, key_hash, scopes, created_at) VALUES (?, ?, ?, ?, ?)`,
+		key.Name, key.Prefix, key.Hash, storage.JoinScopes(key.Scopes), key.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("%s: insert: %w", op, err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("%s: get last insert id: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// GetAPIKey returns the active key with the given hash.
+func (s *Storage) GetAPIKey(ctx context.Context, hash string) (_ storage.APIKey, err error) {
+	const op = "storage.sqlite.GetAPIKey"
+
+	ctx, span := storage.StartSpan(ctx, op)
+	defer func() { storage.EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return storage.APIKey{}, fmt.Errorf("%s: db is nil", op)
+	}
+
+	key, err := scanAPIKey(s.db.QueryRowContext(ctx, `
+SELECT id, name, prefix, key_hash, scopes, created_at, last_used_at, revoked_at
+FROM api_keys WHERE key_hash = ? AND revoked_at IS NULL`, hash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return storage.APIKey{}, fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+	}
+	if err != nil {
+		return storage.APIKey{}, fmt.Errorf("%s: scan: %w", op, err)
+	}
+
+	return key, nil
+}
+
+// ListAPIKeys returns all keys, revoked ones included, oldest first.
+func (s *Storage) ListAPIKeys(ctx context.Context) (_ []storage.APIKey, err error) {
+	const op = "storage.sqlite.ListAPIKeys"
+
+	ctx, span := storage.StartSpan(ctx, op)
+	defer func() { storage.EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return nil, fmt.Errorf("%s: db is nil", op)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT id, name, prefix, key_hash, scopes, created_at, last_used_at, revoked_at
+FROM api_keys ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: select: %w", op, err)
+	}
+	defer rows.Close()
+
+	keys := []storage.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: scan: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey marks an active key as revoked. Revoked keys are kept
+// so that listings still show who had access.
+func (s *Storage) RevokeAPIKey(ctx context.Context, id int64) (err error) {
+	const op = "storage.sqlite.RevokeAPIKey"
+
+	ctx, span := storage.StartSpan(ctx, op)
+	defer func() { storage.EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return fmt.Errorf("%s: db is nil", op)
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, time.Now().UTC(), id)
+	if err != nil {
+		return fmt.Errorf("%s: update: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("%s: get rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, storage.ErrAPIKeyNotFound)
+	}
+
+	return nil
+}
+
+// TouchAPIKey records when a key was last used.
+func (s *Storage) TouchAPIKey(ctx context.Context, id int64, usedAt time.Time) (err error) {
+	const op = "storage.sqlite.TouchAPIKey"
+
+	ctx, span := storage.StartSpan(ctx, op)
+	defer func() { storage.EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return fmt.Errorf("%s: db is nil", op)
+	}
+
+	_, err = s.db.ExecContext(ctx, `UPDATE api_keys SET last_used_at = ? WHERE id = ?`, usedAt.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("%s: update: %w", op, err)
+	}
+
+	return nil
+}
+
+func scanDailyClicks(rows *sql.Rows) ([]storage.DailyClicks, error) {
+	defer rows.Close()
+
+	res := []storage.DailyClicks{}
+	for rows.Next() {
+		var d storage.DailyClicks
+		if err := rows.Scan(&d.Day, &d.Clicks); err != nil {
+			return nil, err
+		}
+		res = append(res, d)
+	}
+
+	return res, rows.Err()
+}
+
+func scanRevisions(rows *sql.Rows) ([]storage.Revision, error) {
+	defer rows.Close()
+
+	res := []storage.Revision{}
+	for rows.Next() {
+		var rev storage.Revision
+		if err := rows.Scan(&rev.URL, &rev.Version, &rev.ChangedBy, &rev.ChangedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, rev)
+	}
+
+	return res, rows.Err()
+}
+
+func scanReferrerClicks(rows *sql.Rows) ([]storage.ReferrerClicks, error) {
+	defer rows.Close()
+
+	res := []storage.ReferrerClicks{}
+	for rows.Next() {
+		var rc storage.ReferrerClicks
+		if err := rows.Scan(&rc.Referrer, &rc.Clicks); err != nil {
+			return nil, err
+		}
+		res = append(res, rc)
+	}
+
+	return res, rows.Err()
+}
+
+// scanAPIKey reads a row of id, name, prefix, key_hash, scopes,
+// created_at, last_used_at and revoked_at.
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (storage.APIKey, error) {
+	var (
+		key                   storage.APIKey
+		scopes                string
+		lastUsedAt, revokedAt sql.NullTime
+	)
+	if err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Hash, &scopes, &key.CreatedAt, &lastUsedAt, &revokedAt); err != nil {
+		return storage.APIKey{}, err
+	}
+
+	key.Scopes = storage.SplitScopes(scopes)
+	key.LastUsedAt = lastUsedAt.Time
+	key.RevokedAt = revokedAt.Time
+
+	return key, nil
+}
+
+// ReserveIdempotencyKey stores rec unless a live record holds the same
+// owner and key, in which case it returns that record and
+// storage.ErrIdempotencyKeyExists. Expired records are replaced.
+func (s *Storage) ReserveIdempotencyKey(ctx context.Context, rec storage.IdempotencyRecord) (_ storage.IdempotencyRecord, err error) {
+	const op = "storage.sqlite.ReserveIdempotencyKey"
+
+	ctx, span := storage.StartSpan(ctx, op)
+	defer func() { storage.EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return storage.IdempotencyRecord{}, fmt.Errorf("%s: db is nil", op)
+	}
+
+	headers, err := json.Marshal(rec.Headers)
+	if err != nil {
+		return storage.IdempotencyRecord{}, fmt.Errorf("%s: encode headers: %w", op, err)
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+INSERT INTO idempotency_keys (owner, key, request_hash, status, headers, body, expires_at)
+VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7)
+ON CONFLICT (owner, key) DO UPDATE SET
+    request_hash = excluded.request_hash, status = excluded.status, headers = excluded.headers,
+    body = excluded.body, expires_at = excluded.expires_at
+WHERE idempotency_keys.expires_at <= ?8`,
+		rec.Owner, rec.Key, rec.RequestHash, rec.Status, string(headers), rec.Body, rec.ExpiresAt.UTC(), time.Now().UTC())
+	if err != nil {
+		return storage.IdempotencyRecord{}, fmt.Errorf("%s: insert: %w", op, err)
+	}
+
+	reserved, err := res.RowsAffected()
+	if err != nil {
+		return storage.IdempotencyRecord{}, fmt.Errorf("%s: get rows affected: %w", op, err)
+	}
+	if reserved == 1 {
+		return rec, nil
+	}
+
+	existing, err := scanIdempotencyRecord(s.db.QueryRowContext(ctx, `
+SELECT owner, key, request_hash, status, headers, body, expires_at
+FROM idempotency_keys WHERE owner = ? AND key = ?`, rec.Owner, rec.Key))
+	if err != nil {
+		return storage.IdempotencyRecord{}, fmt.Errorf("%s: select: %w", op, err)
+	}
+
+	return existing, fmt.Errorf("%s: %w", op, storage.ErrIdempotencyKeyExists)
+}
+
+// CompleteIdempotencyKey stores the response of a reserved key.
+func (s *Storage) CompleteIdempotencyKey(ctx context.Context, rec storage.IdempotencyRecord) (err error) {
+	const op = "storage.sqlite.CompleteIdempotencyKey"
+
+	ctx, span := storage.StartSpan(ctx, op)
+	defer func() { storage.EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return fmt.Errorf("%s: db is nil", op)
+	}
+
+	headers, err := json.Marshal(rec.Headers)
+	if err != nil {
+		return fmt.Errorf("%s: encode headers: %w", op, err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+UPDATE idempotency_keys SET status = ?, headers = ?, body = ?, expires_at = ?
+WHERE owner = ? AND key = ?`,
+		rec.Status, string(headers), rec.Body, rec.ExpiresAt.UTC(), rec.Owner, rec.Key)
+	if err != nil {
+		return fmt.Errorf("%s: update: %w", op, err)
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey deletes a reservation that got no response
+// worth keeping, so the request can be retried with the same key.
+func (s *Storage) ReleaseIdempotencyKey(ctx context.Context, owner string, key string) (err error) {
+	const op = "storage.sqlite.ReleaseIdempotencyKey"
+
+	ctx, span := storage.StartSpan(ctx, op)
+	defer func() { storage.EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return fmt.Errorf("%s: db is nil", op)
+	}
+
+	_, err = s.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE owner = ? AND key = ? AND status = 0`, owner, key)
+	if err != nil {
+		return fmt.Errorf("%s: delete: %w", op, err)
+	}
+
+	return nil
+}
+
+// PurgeIdempotencyKeys deletes up to limit expired records and returns
+// how many were deleted.
+func (s *Storage) PurgeIdempotencyKeys(ctx context.Context, limit int) (_ int64, err error) {
+	const op = "storage.sqlite.PurgeIdempotencyKeys"
+
+	ctx, span := storage.StartSpan(ctx, op)
+	defer func() { storage.EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return 0, fmt.Errorf("%s: db is nil", op)
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+DELETE FROM idempotency_keys WHERE rowid IN (
+    SELECT rowid FROM idempotency_keys WHERE expires_at <= ? LIMIT ?
+)`, time.Now().UTC(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: delete: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: get rows affected: %w", op, err)
+	}
+
+	return rowsAffected, nil
+}
+
+// scanIdempotencyRecord reads a row of owner, key, request_hash, status,
+// headers, body and expires_at.
+func scanIdempotencyRecord(row interface{ Scan(dest ...any) error }) (storage.IdempotencyRecord, error) {
+	var (
+		rec     storage.IdempotencyRecord
+		headers string
+	)
+	if err := row.Scan(&rec.Owner, &rec.Key, &rec.RequestHash, &rec.Status, &headers, &rec.Body, &rec.ExpiresAt); err != nil {
+		return storage.IdempotencyRecord{}, err
+	}
+	if err := json.Unmarshal([]byte(headers), &rec.Headers); err != nil {
+		return storage.IdempotencyRecord{}, fmt.Errorf("decode headers: %w", err)
+	}
+
+	return rec, nil
+}
@@ -1,9 +1,14 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/lib/pq"
 )
@@ -11,12 +16,31 @@ import (
 var (
 	ErrURLNotFound = errors.New("Url not found")
 	ErrURLExists   = errors.New("URL already exists")
+	ErrURLExpired  = errors.New("URL expired")
+	ErrNotOwner    = errors.New("URL belongs to another owner")
+	// ErrVersionMismatch means the link was changed by someone else.
+	ErrVersionMismatch = errors.New("URL version mismatch")
+	// ErrBatchAborted means the item was not saved because another item
+	// of the same all-or-nothing batch failed.
+	ErrBatchAborted = errors.New("batch aborted")
+
+	ErrAPIKeyNotFound = errors.New("API key not found")
+
+	// ErrIdempotencyKeyExists means a live record holds the key.
+	ErrIdempotencyKeyExists = errors.New("idempotency key exists")
 )
 
 type Storage struct {
 	db *sql.DB
+
+	saveStmt   *sql.Stmt
+	getStmt    *sql.Stmt
+	deleteStmt *sql.Stmt
 }
 
+// New wraps an open Postgres connection and prepares the statements
+// used on every request. The schema is expected to be up to date,
+// see the migrate package.
 func New(db *sql.DB) (*Storage, error) {
 	const op = "storage.New"
 
@@ -24,42 +48,69 @@ func New(db *sql.DB) (*Storage, error) {
 		return nil, fmt.Errorf("%s: db is nil", op)
 	}
 
-	// Create table and index if they don't exist
-	if _, err := db.Exec(`
-CREATE TABLE IF NOT EXISTS public.url (
-    id SERIAL PRIMARY KEY,
-    alias TEXT NOT NULL UNIQUE,
-    url TEXT NOT NULL
-);
-`); err != nil {
-		return nil, fmt.Errorf("%s: create table: %w", op, err)
+	s := &Storage{db: db}
+
+	stmts := []struct {
+		dst   **sql.Stmt
+		query string
+	}{
+		{&s.saveStmt, `
+WITH u AS (
+    INSERT INTO public.url (url, alias, expires_at, owner, domain, created_at, updated_at)
+    VALUES ($1, $2, $3, $4, $5, NOW(), NOW()) RETURNING id
+), t AS (
+    INSERT INTO public.url_tags (url_id, tag) SELECT DISTINCT u.id, tag FROM u, unnest($6::text[]) AS tag
+)
+SELECT id FROM u`},
+		{&s.getStmt, `SELECT url, expires_at FROM public.url WHERE alias = $1`},
+		{&s.deleteStmt, `DELETE FROM public.url WHERE alias = $1 AND ($2 = '' OR owner = $2)`},
+	}
+	for _, st := range stmts {
+		stmt, err := db.Prepare(st.query)
+		if err != nil {
+			_ = s.Close()
+			return nil, fmt.Errorf("%s: prepare: %w", op, err)
+		}
+		*st.dst = stmt
 	}
 
-	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idx_alias ON public.url(alias);`); err != nil {
-		return nil, fmt.Errorf("%s: create index: %w", op, err)
+	return s, nil
+}
+
+// Close releases the prepared statements. The connection pool
+// belongs to the caller and stays open.
+func (s *Storage) Close() error {
+	var errs []error
+	for _, stmt := range []*sql.Stmt{s.saveStmt, s.getStmt, s.deleteStmt} {
+		if stmt != nil {
+			errs = append(errs, stmt.Close())
+		}
 	}
 
-	return &Storage{db: db}, nil
+	return errors.Join(errs...)
+}
+
+// DBStats returns the connection pool statistics.
+func (s *Storage) DBStats() sql.DBStats {
+	return s.db.Stats()
 }
 
 // SaveURL inserts a new URL and alias, returning the generated id.
-func (s *Storage) SaveURL(urlToSave string, alias string) (int64, error) {
+func (s *Storage) SaveURL(ctx context.Context, urlToSave string, alias string, opts SaveOptions) (id int64, err error) {
 	const op = "storage.SaveURL"
 
+	ctx, span := StartSpan(ctx, op)
+	defer func() { EndSpan(span, err) }()
+
 	if s == nil || s.db == nil {
 		return 0, fmt.Errorf("%s: db is nil", op)
 	}
 
-	var id int64
-	// Use PostgreSQL-style placeholders and RETURNING to get the id
-	err := s.db.QueryRow(
-		`INSERT INTO public.url (url, alias) VALUES ($1, $2) RETURNING id`,
-		urlToSave, alias,
-	).Scan(&id)
+	err = s.saveStmt.QueryRowContext(ctx, urlToSave, alias, nullTime(opts.ExpiresAt), opts.Owner, Domain(urlToSave), pq.Array(opts.Tags)).Scan(&id)
 	if err != nil {
 		// Unique violation code for Postgres is 23505
 		if pgErr, ok := err.(*pq.Error); ok && string(pgErr.Code) == "23505" {
-			return 0, fmt.Errorf("%s: unique violation: %w", op, err)
+			return 0, fmt.Errorf("%s: %w", op, ErrURLExists)
 		}
 		return 0, fmt.Errorf("%s: insert: %w", op, err)
 	}
@@ -67,50 +118,748 @@ func (s *Storage) SaveURL(urlToSave string, alias string) (int64, error) {
 	return id, nil
 }
 
-func (s *Storage) GetURL(alias string) (string, error) {
-	const op = "storage.GetURL"
+// SaveURLs inserts a batch of links with a single statement in one
+// transaction. Taken aliases are skipped, and with atomic any of them
+// rolls the whole batch back.
+func (s *Storage) SaveURLs(ctx context.Context, items []BatchItem, atomic bool) (_ []BatchResult, err error) {
+	const op = "storage.SaveURLs"
+
+	ctx, span := StartSpan(ctx, op)
+	defer func() { EndSpan(span, err) }()
 
 	if s == nil || s.db == nil {
-		return "", fmt.Errorf("%s: db is nil", op)
+		return nil, fmt.Errorf("%s: db is nil", op)
+	}
+
+	results := make([]BatchResult, len(items))
+	if len(items) == 0 {
+		return results, nil
+	}
+
+	urls := make([]string, len(items))
+	aliases := make([]string, len(items))
+	expiresAt := make([]sql.NullTime, len(items))
+	owners := make([]string, len(items))
+	domains := make([]string, len(items))
+	for i, item := range items {
+		urls[i] = item.URL
+		aliases[i] = item.Alias
+		expiresAt[i] = nullTime(item.Opts.ExpiresAt)
+		owners[i] = item.Opts.Owner
+		domains[i] = Domain(item.URL)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: begin: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// The first item with an alias wins, like it would one by one.
+	rows, err := tx.QueryContext(ctx, `
+INSERT INTO public.url (url, alias, expires_at, owner, domain, created_at, updated_at)
+SELECT l.url, l.alias, l.expires_at, l.owner, l.domain, NOW(), NOW()
+FROM unnest($1::text[], $2::text[], $3::timestamptz[], $4::text[], $5::text[]) WITH ORDINALITY
+    AS l(url, alias, expires_at, owner, domain, n)
+ORDER BY l.n
+ON CONFLICT (alias) DO NOTHING
+RETURNING id, alias`,
+		pq.Array(urls), pq.Array(aliases), pq.Array(expiresAt), pq.Array(owners), pq.Array(domains),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: insert: %w", op, err)
+	}
+
+	saved := make(map[string]int64, len(items))
+	for rows.Next() {
+		var (
+			id    int64
+			alias string
+		)
+		if err := rows.Scan(&id, &alias); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		saved[alias] = id
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows: %w", op, err)
+	}
+
+	var (
+		failed    bool
+		tagURLIDs []int64
+		tags      []string
+	)
+	for i, item := range items {
+		id, ok := saved[item.Alias]
+		if !ok {
+			results[i].Err = ErrURLExists
+			failed = true
+			continue
+		}
+		// A later item with the same alias was skipped by the insert.
+		delete(saved, item.Alias)
+
+		results[i].ID = id
+		for _, tag := range item.Opts.Tags {
+			tagURLIDs = append(tagURLIDs, id)
+			tags = append(tags, tag)
+		}
+	}
+
+	if failed && atomic {
+		AbortBatch(results)
+		return results, nil
+	}
+
+	if len(tags) > 0 {
+		_, err = tx.ExecContext(ctx, `
+INSERT INTO public.url_tags (url_id, tag)
+SELECT DISTINCT t.url_id, t.tag FROM unnest($1::bigint[], $2::text[]) AS t(url_id, tag)`,
+			pq.Array(tagURLIDs), pq.Array(tags),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("%s: insert tags: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("%s: commit: %w", op, err)
 	}
 
-	stmt, err := s.db.Prepare("SELECT url FROM public.url WHERE alias = $1")
+	return results, nil
+}
+
+func (s *Storage) GetURL(ctx context.Context, alias string) (_ string, err error) {
+	const op = "storage.GetURL"
+
+	ctx, span := StartSpan(ctx, op)
+	defer func() { EndSpan(span, err) }()
+
+	url, _, err := s.resolve(ctx, alias)
 	if err != nil {
-		return "", fmt.Errorf("%s: prepare: %w", op, err)
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+
+	return url, nil
+}
+
+// ResolveURL is GetURL that also returns when the link expires,
+// zero if never.
+func (s *Storage) ResolveURL(ctx context.Context, alias string) (_ string, _ time.Time, err error) {
+	const op = "storage.ResolveURL"
+
+	ctx, span := StartSpan(ctx, op)
+	defer func() { EndSpan(span, err) }()
+
+	url, expiresAt, err := s.resolve(ctx, alias)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return url, expiresAt, nil
+}
+
+func (s *Storage) resolve(ctx context.Context, alias string) (string, time.Time, error) {
+	if s == nil || s.db == nil {
+		return "", time.Time{}, errors.New("db is nil")
 	}
-	defer stmt.Close()
 
 	var ResUrl string
-	err = stmt.QueryRow(alias).Scan(&ResUrl)
+	var expiresAt sql.NullTime
+	err := s.getStmt.QueryRowContext(ctx, alias).Scan(&ResUrl, &expiresAt)
 	if err == sql.ErrNoRows {
-		return "", fmt.Errorf("%s: %w", op, ErrURLNotFound)
+		return "", time.Time{}, ErrURLNotFound
 	}
 	if err != nil {
-		return "", fmt.Errorf("%s: scan: %w", op, err)
+		return "", time.Time{}, fmt.Errorf("scan: %w", err)
 	}
 	if ResUrl == "" {
-		return "", fmt.Errorf("%s: %w", op, ErrURLNotFound)
+		return "", time.Time{}, ErrURLNotFound
+	}
+	if expiresAt.Valid && !expiresAt.Time.After(time.Now()) {
+		return "", time.Time{}, ErrURLExpired
+	}
+
+	return ResUrl, expiresAt.Time, nil
+}
+
+// GetLink returns the link with its metadata, expired links included.
+func (s *Storage) GetLink(ctx context.Context, alias string, owner string) (_ Link, err error) {
+	const op = "storage.GetLink"
+
+	ctx, span := StartSpan(ctx, op)
+	defer func() { EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return Link{}, fmt.Errorf("%s: db is nil", op)
+	}
+
+	link, err := scanLink(s.db.QueryRowContext(ctx, `SELECT `+linkColumns+` FROM public.url u WHERE u.alias = $1`, alias))
+	if err == sql.ErrNoRows {
+		return Link{}, fmt.Errorf("%s: %w", op, ErrURLNotFound)
+	}
+	if err != nil {
+		return Link{}, fmt.Errorf("%s: scan: %w", op, err)
+	}
+	if owner != "" && link.Owner != owner {
+		return Link{}, fmt.Errorf("%s: %w", op, ErrNotOwner)
+	}
+
+	return link, nil
+}
+
+// FindURL returns the oldest link of owner that never expires, has no
+// tags and whose destination normalizes like rawURL, see NormalizeURL.
+// Unlike elsewhere, an empty owner only matches links without one.
+func (s *Storage) FindURL(ctx context.Context, owner string, rawURL string) (_ Link, err error) {
+	const op = "storage.FindURL"
+
+	ctx, span := StartSpan(ctx, op)
+	defer func() { EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return Link{}, fmt.Errorf("%s: db is nil", op)
+	}
+
+	// The owner and domain narrow the candidates down with an index,
+	// the rest of the comparison needs NormalizeURL.
+	rows, err := s.db.QueryContext(ctx, `
+SELECT `+linkColumns+` FROM public.url u
+WHERE u.owner = $1 AND u.domain = $2 AND u.expires_at IS NULL
+    AND NOT EXISTS (SELECT 1 FROM public.url_tags t WHERE t.url_id = u.id)
+ORDER BY u.id`, owner, Domain(rawURL))
+	if err != nil {
+		return Link{}, fmt.Errorf("%s: query: %w", op, err)
+	}
+	defer rows.Close()
+
+	want := NormalizeURL(rawURL)
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return Link{}, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		if NormalizeURL(link.URL) == want {
+			return link, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return Link{}, fmt.Errorf("%s: rows: %w", op, err)
+	}
+
+	return Link{}, fmt.Errorf("%s: %w", op, ErrURLNotFound)
+}
+
+// ListURLs returns a page of links in id order. Every filter is backed
+// by an index, and paging continues after the last id instead of
+// skipping rows with OFFSET.
+func (s *Storage) ListURLs(ctx context.Context, opts ListOptions) (_ []Link, err error) {
+	const op = "storage.ListURLs"
+
+	ctx, span := StartSpan(ctx, op)
+	defer func() { EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return nil, fmt.Errorf("%s: db is nil", op)
+	}
+
+	var (
+		where []string
+		args  []any
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	order, after := "DESC", "<"
+	if opts.Ascending {
+		order, after = "ASC", ">"
+	}
+	if opts.After != 0 {
+		where = append(where, "u.id "+after+" "+arg(opts.After))
+	}
+	if opts.Owner != "" {
+		where = append(where, "u.owner = "+arg(opts.Owner))
+	}
+	if !opts.CreatedAfter.IsZero() {
+		where = append(where, "u.created_at >= "+arg(opts.CreatedAfter))
+	}
+	if !opts.CreatedBefore.IsZero() {
+		where = append(where, "u.created_at < "+arg(opts.CreatedBefore))
+	}
+	if opts.Domain != "" {
+		where = append(where, "u.domain = "+arg(strings.ToLower(opts.Domain)))
+	}
+	if opts.AliasPrefix != "" {
+		where = append(where, "u.alias LIKE "+arg(escapeLike(opts.AliasPrefix)+"%")+` ESCAPE '\'`)
+	}
+	if opts.Tag != "" {
+		where = append(where, "EXISTS (SELECT 1 FROM public.url_tags t WHERE t.url_id = u.id AND t.tag = "+arg(opts.Tag)+")")
+	}
+	if opts.Query != "" {
+		where = append(where, "u.url ILIKE "+arg("%"+escapeLike(opts.Query)+"%")+` ESCAPE '\'`)
+	}
+
+	query := `SELECT ` + linkColumns + ` FROM public.url u`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, ` AND `)
+	}
+	query += ` ORDER BY u.id ` + order + ` LIMIT ` + arg(opts.Limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: query: %w", op, err)
+	}
+	defer rows.Close()
+
+	links := []Link{}
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: rows: %w", op, err)
 	}
 
-	return ResUrl, nil
+	return links, nil
 }
 
-func (s *Storage) DeleteURL(alias string) error {
+// linkColumns are the columns of url u read by scanLink.
+const linkColumns = `u.id, u.alias, u.url, u.owner, u.version, u.created_at, u.updated_at, u.expires_at,
+    (SELECT COUNT(*) FROM public.clicks c WHERE c.url_id = u.id),
+    (SELECT COALESCE(string_agg(t.tag, ','), '') FROM public.url_tags t WHERE t.url_id = u.id)`
+
+func scanLink(row interface{ Scan(dest ...any) error }) (Link, error) {
+	var (
+		link                            Link
+		createdAt, updatedAt, expiresAt sql.NullTime
+		tags                            string
+	)
+	err := row.Scan(&link.ID, &link.Alias, &link.URL, &link.Owner, &link.Version,
+		&createdAt, &updatedAt, &expiresAt, &link.Clicks, &tags)
+	if err != nil {
+		return Link{}, err
+	}
+
+	link.CreatedAt = createdAt.Time
+	link.UpdatedAt = updatedAt.Time
+	link.ExpiresAt = expiresAt.Time
+	link.Tags = SplitTags(tags)
+
+	return link, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s, for use with ESCAPE '\'.
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// UpdateURL points the link to upd.URL and returns its new version.
+// The previous destination is added to the history in the same
+// transaction, and the row stays locked from the checks to the update.
+func (s *Storage) UpdateURL(ctx context.Context, alias string, upd URLUpdate) (_ int64, err error) {
+	const op = "storage.UpdateURL"
+
+	ctx, span := StartSpan(ctx, op)
+	defer func() { EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return 0, fmt.Errorf("%s: db is nil", op)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("%s: begin: %w", op, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, `
+INSERT INTO public.url_history (url_id, url, version, changed_by, changed_at)
+SELECT id, url, version, $4, NOW() FROM public.url
+WHERE alias = $1 AND ($2 = '' OR owner = $2) AND ($3::bigint = 0 OR version = $3)
+FOR UPDATE`, alias, upd.Owner, upd.Version, upd.ChangedBy)
+	if err != nil {
+		return 0, fmt.Errorf("%s: save history: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: get rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return 0, fmt.Errorf("%s: %w", op, updateConflict(ctx, tx, alias, upd.Owner))
+	}
+
+	var id, version int64
+	err = tx.QueryRowContext(ctx, `UPDATE public.url SET url = $1, domain = $2, version = version + 1, updated_at = NOW() WHERE alias = $3 RETURNING id, version`,
+		upd.URL, Domain(upd.URL), alias).Scan(&id, &version)
+	if err != nil {
+		return 0, fmt.Errorf("%s: update: %w", op, err)
+	}
+
+	if upd.SetMeta {
+		if _, err := tx.ExecContext(ctx, `UPDATE public.url SET expires_at = $1 WHERE id = $2`, nullTime(upd.ExpiresAt), id); err != nil {
+			return 0, fmt.Errorf("%s: update expiry: %w", op, err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM public.url_tags WHERE url_id = $1`, id); err != nil {
+			return 0, fmt.Errorf("%s: delete tags: %w", op, err)
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO public.url_tags (url_id, tag) SELECT DISTINCT $1::bigint, tag FROM unnest($2::text[]) AS tag`,
+			id, pq.Array(upd.Tags))
+		if err != nil {
+			return 0, fmt.Errorf("%s: insert tags: %w", op, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("%s: commit: %w", op, err)
+	}
+
+	return version, nil
+}
+
+// updateConflict tells why an update of alias matched no row:
+// the link is missing, belongs to someone else or has a newer version.
+func updateConflict(ctx context.Context, tx *sql.Tx, alias string, owner string) error {
+	var linkOwner string
+	err := tx.QueryRowContext(ctx, `SELECT owner FROM public.url WHERE alias = $1`, alias).Scan(&linkOwner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrURLNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("check version: %w", err)
+	}
+	if owner != "" && linkOwner != owner {
+		return ErrNotOwner
+	}
+
+	return ErrVersionMismatch
+}
+
+// DeleteURL deletes the link if it belongs to owner. The ownership check
+// is part of the DELETE, so a link can't change hands in between.
+func (s *Storage) DeleteURL(ctx context.Context, alias string, owner string) (err error) {
 	const op = "storage.DeleteURL"
 
+	ctx, span := StartSpan(ctx, op)
+	defer func() { EndSpan(span, err) }()
+
 	if s == nil || s.db == nil {
 		return fmt.Errorf("%s: db is nil", op)
 	}
 
-	stmt, err := s.db.Prepare("DELETE FROM public.url WHERE alias = $1")
+	res, err := s.deleteStmt.ExecContext(ctx, alias, owner)
+	if err != nil {
+		return fmt.Errorf("%s: delete: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("%s: prepare delete: %w", op, err)
+		return fmt.Errorf("%s: get rows affected: %w", op, err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("%s: %w", op, s.missingOrForeign(ctx, alias))
 	}
-	defer stmt.Close()
 
-	res, err := stmt.Exec(alias)
+	return nil
+}
+
+// missingOrForeign tells why nothing matched alias and its owner.
+// It only picks the error, the decision has been made already.
+func (s *Storage) missingOrForeign(ctx context.Context, alias string) error {
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM public.url WHERE alias = $1)`, alias).Scan(&exists)
 	if err != nil {
-		return fmt.Errorf("%s: delete: %w", op, err)
+		return fmt.Errorf("check owner: %w", err)
+	}
+	if exists {
+		return ErrNotOwner
+	}
+
+	return ErrURLNotFound
+}
+
+// PurgeExpired deletes up to limit expired links and returns how many were deleted.
+func (s *Storage) PurgeExpired(ctx context.Context, limit int) (_ int64, err error) {
+	const op = "storage.PurgeExpired"
+
+	ctx, span := StartSpan(ctx, op)
+	defer func() { EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return 0, fmt.Errorf("%s: db is nil", op)
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+DELETE FROM public.url WHERE id IN (
+    SELECT id FROM public.url WHERE expires_at <= now() LIMIT $1 FOR UPDATE SKIP LOCKED
+)`, limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: delete: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: get rows affected: %w", op, err)
+	}
+
+	return rowsAffected, nil
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// SaveClicks stores a batch of click events with a single statement.
+// Clicks on aliases that no longer exist are skipped.
+func (s *Storage) SaveClicks(ctx context.Context, clicks []Click) (err error) {
+	const op = "storage.SaveClicks"
+
+	ctx, span := StartSpan(ctx, op)
+	defer func() { EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return fmt.Errorf("%s: db is nil", op)
+	}
+
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	aliases := make([]string, len(clicks))
+	clickedAt := make([]time.Time, len(clicks))
+	referrers := make([]string, len(clicks))
+	userAgents := make([]string, len(clicks))
+	requestIDs := make([]string, len(clicks))
+	for i, c := range clicks {
+		aliases[i] = c.Alias
+		clickedAt[i] = c.ClickedAt
+		referrers[i] = c.Referrer
+		userAgents[i] = c.UserAgent
+		requestIDs[i] = c.RequestID
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+INSERT INTO public.clicks (url_id, clicked_at, referrer, user_agent, request_id)
+SELECT u.id, c.clicked_at, c.referrer, c.user_agent, c.request_id
+FROM unnest($1::text[], $2::timestamptz[], $3::text[], $4::text[], $5::text[])
+    AS c(alias, clicked_at, referrer, user_agent, request_id)
+JOIN public.url u ON u.alias = c.alias`,
+		pq.Array(aliases), pq.Array(clickedAt), pq.Array(referrers), pq.Array(userAgents), pq.Array(requestIDs),
+	)
+	if err != nil {
+		return fmt.Errorf("%s: insert: %w", op, err)
+	}
+
+	return nil
+}
+
+// URLStats returns click statistics of a link, with per-day counts since the given time.
+func (s *Storage) URLStats(ctx context.Context, alias string, owner string, since time.Time, topReferrers int) (_ Stats, err error) {
+	const op = "storage.URLStats"
+
+	ctx, span := StartSpan(ctx, op)
+	defer func() { EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return Stats{}, fmt.Errorf("%s: db is nil", op)
+	}
+
+	var stats Stats
+	var urlID int64
+	var urlOwner string
+
+	err = s.db.QueryRowContext(ctx, `
+SELECT u.id, u.owner, (SELECT COUNT(*) FROM public.clicks c WHERE c.url_id = u.id)
+FROM public.url u WHERE u.alias = $1`, alias).Scan(&urlID, &urlOwner, &stats.TotalClicks)
+	if err == sql.ErrNoRows {
+		return Stats{}, fmt.Errorf("%s: %w", op, ErrURLNotFound)
+	}
+	if err != nil {
+		return Stats{}, fmt.Errorf("%s: total: %w", op, err)
+	}
+	if owner != "" && urlOwner != owner {
+		return Stats{}, fmt.Errorf("%s: %w", op, ErrNotOwner)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT to_char(clicked_at AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS day, COUNT(*)
+FROM public.clicks
+WHERE url_id = $1 AND clicked_at >= $2
+GROUP BY day
+ORDER BY day`, urlID, since)
+	if err != nil {
+		return Stats{}, fmt.Errorf("%s: per day: %w", op, err)
+	}
+	stats.ClicksPerDay, err = scanDailyClicks(rows)
+	if err != nil {
+		return Stats{}, fmt.Errorf("%s: per day: %w", op, err)
+	}
+
+	rows, err = s.db.QueryContext(ctx, `
+SELECT referrer, COUNT(*) AS clicks
+FROM public.clicks
+WHERE url_id = $1 AND referrer <> ''
+GROUP BY referrer
+ORDER BY clicks DESC, referrer
+LIMIT $2`, urlID, topReferrers)
+	if err != nil {
+		return Stats{}, fmt.Errorf("%s: referrers: %w", op, err)
+	}
+	stats.TopReferrers, err = scanReferrerClicks(rows)
+	if err != nil {
+		return Stats{}, fmt.Errorf("%s: referrers: %w", op, err)
+	}
+
+	return stats, nil
+}
+
+// URLHistory returns the previous destinations of a link, newest first.
+func (s *Storage) URLHistory(ctx context.Context, alias string, owner string) (_ []Revision, err error) {
+	const op = "storage.URLHistory"
+
+	ctx, span := StartSpan(ctx, op)
+	defer func() { EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return nil, fmt.Errorf("%s: db is nil", op)
+	}
+
+	var urlID int64
+	var urlOwner string
+
+	err = s.db.QueryRowContext(ctx, `SELECT id, owner FROM public.url WHERE alias = $1`, alias).Scan(&urlID, &urlOwner)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%s: %w", op, ErrURLNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: select url: %w", op, err)
+	}
+	if owner != "" && urlOwner != owner {
+		return nil, fmt.Errorf("%s: %w", op, ErrNotOwner)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT url, version, changed_by, changed_at
+FROM public.url_history
+WHERE url_id = $1
+ORDER BY version DESC`, urlID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: select: %w", op, err)
+	}
+	history, err := scanRevisions(rows)
+	if err != nil {
+		return nil, fmt.Errorf("%s: scan: %w", op, err)
+	}
+
+	return history, nil
+}
+
+// SaveAPIKey stores a new API key, returning the generated id.
+func (s *Storage) SaveAPIKey(ctx context.Context, key APIKey) (id int64, err error) {
+	const op = "storage.SaveAPIKey"
+
+	ctx, span := StartSpan(ctx, op)
+	defer func() { EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return 0, fmt.Errorf("%s: db is nil", op)
+	}
+
+	err = s.db.QueryRowContext(ctx, `
+INSERT INTO public.api_keys (name, prefix, key_hash, scopes, created_at)
+VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		key.Name, key.Prefix, key.Hash, JoinScopes(key.Scopes), key.CreatedAt,
+	).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("%s: insert: %w", op, err)
+	}
+
+	return id, nil
+}
+
+// GetAPIKey returns the active key with the given hash.
+func (s *Storage) GetAPIKey(ctx context.Context, hash string) (_ APIKey, err error) {
+	const op = "storage.GetAPIKey"
+
+	ctx, span := StartSpan(ctx, op)
+	defer func() { EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return APIKey{}, fmt.Errorf("%s: db is nil", op)
+	}
+
+	key, err := scanAPIKey(s.db.QueryRowContext(ctx, `
+SELECT id, name, prefix, key_hash, scopes, created_at, last_used_at, revoked_at
+FROM public.api_keys WHERE key_hash = $1 AND revoked_at IS NULL`, hash))
+	if err == sql.ErrNoRows {
+		return APIKey{}, fmt.Errorf("%s: %w", op, ErrAPIKeyNotFound)
+	}
+	if err != nil {
+		return APIKey{}, fmt.Errorf("%s: scan: %w", op, err)
+	}
+
+	return key, nil
+}
+
+// ListAPIKeys returns all keys, revoked ones included, oldest first.
+func (s *Storage) ListAPIKeys(ctx context.Context) (_ []APIKey, err error) {
+	const op = "storage.ListAPIKeys"
+
+	ctx, span := StartSpan(ctx, op)
+	defer func() { EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return nil, fmt.Errorf("%s: db is nil", op)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+SELECT id, name, prefix, key_hash, scopes, created_at, last_used_at, revoked_at
+FROM public.api_keys ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("%s: select: %w", op, err)
+	}
+	defer rows.Close()
+
+	keys := []APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("%s: scan: %w", op, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: scan: %w", op, err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey marks an active key as revoked. Revoked keys are kept
+// so that listings still show who had access.
+func (s *Storage) RevokeAPIKey(ctx context.Context, id int64) (err error) {
+	const op = "storage.RevokeAPIKey"
+
+	ctx, span := StartSpan(ctx, op)
+	defer func() { EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return fmt.Errorf("%s: db is nil", op)
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+UPDATE public.api_keys SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return fmt.Errorf("%s: update: %w", op, err)
 	}
 
 	rowsAffected, err := res.RowsAffected()
@@ -118,8 +867,232 @@ func (s *Storage) DeleteURL(alias string) error {
 		return fmt.Errorf("%s: get rows affected: %w", op, err)
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("%s: %w", op, ErrURLNotFound)
+		return fmt.Errorf("%s: %w", op, ErrAPIKeyNotFound)
 	}
 
 	return nil
 }
+
+// TouchAPIKey records when a key was last used.
+func (s *Storage) TouchAPIKey(ctx context.Context, id int64, usedAt time.Time) (err error) {
+	const op = "storage.TouchAPIKey"
+
+	ctx, span := StartSpan(ctx, op)
+	defer func() { EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return fmt.Errorf("%s: db is nil", op)
+	}
+
+	_, err = s.db.ExecContext(ctx, `UPDATE public.api_keys SET last_used_at = $2 WHERE id = $1`, id, usedAt)
+	if err != nil {
+		return fmt.Errorf("%s: update: %w", op, err)
+	}
+
+	return nil
+}
+
+func scanDailyClicks(rows *sql.Rows) ([]DailyClicks, error) {
+	defer rows.Close()
+
+	res := []DailyClicks{}
+	for rows.Next() {
+		var d DailyClicks
+		if err := rows.Scan(&d.Day, &d.Clicks); err != nil {
+			return nil, err
+		}
+		res = append(res, d)
+	}
+
+	return res, rows.Err()
+}
+
+func scanRevisions(rows *sql.Rows) ([]Revision, error) {
+	defer rows.Close()
+
+	res := []Revision{}
+	for rows.Next() {
+		var rev Revision
+		if err := rows.Scan(&rev.URL, &rev.Version, &rev.ChangedBy, &rev.ChangedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, rev)
+	}
+
+	return res, rows.Err()
+}
+
+func scanReferrerClicks(rows *sql.Rows) ([]ReferrerClicks, error) {
+	defer rows.Close()
+
+	res := []ReferrerClicks{}
+	for rows.Next() {
+		var rc ReferrerClicks
+		if err := rows.Scan(&rc.Referrer, &rc.Clicks); err != nil {
+			return nil, err
+		}
+		res = append(res, rc)
+	}
+
+	return res, rows.Err()
+}
+
+// scanAPIKey reads a row of id, name, prefix, key_hash, scopes,
+// created_at, last_used_at and revoked_at.
+func scanAPIKey(row interface{ Scan(dest ...any) error }) (APIKey, error) {
+	var (
+		key                   APIKey
+		scopes                string
+		lastUsedAt, revokedAt sql.NullTime
+	)
+	if err := row.Scan(&key.ID, &key.Name, &key.Prefix, &key.Hash, &scopes, &key.CreatedAt, &lastUsedAt, &revokedAt); err != nil {
+		return APIKey{}, err
+	}
+
+	key.Scopes = SplitScopes(scopes)
+	key.LastUsedAt = lastUsedAt.Time
+	key.RevokedAt = revokedAt.Time
+
+	return key, nil
+}
+
+// ReserveIdempotencyKey stores rec unless a live record holds the same
+// owner and key, in which case it returns that record and
+// ErrIdempotencyKeyExists. Expired records are replaced.
+func (s *Storage) ReserveIdempotencyKey(ctx context.Context, rec IdempotencyRecord) (_ IdempotencyRecord, err error) {
+	const op = "storage.ReserveIdempotencyKey"
+
+	ctx, span := StartSpan(ctx, op)
+	defer func() { EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return IdempotencyRecord{}, fmt.Errorf("%s: db is nil", op)
+	}
+
+	headers, err := json.Marshal(rec.Headers)
+	if err != nil {
+		return IdempotencyRecord{}, fmt.Errorf("%s: encode headers: %w", op, err)
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+INSERT INTO public.idempotency_keys (owner, key, request_hash, status, headers, body, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (owner, key) DO UPDATE SET
+    request_hash = EXCLUDED.request_hash, status = EXCLUDED.status, headers = EXCLUDED.headers,
+    body = EXCLUDED.body, expires_at = EXCLUDED.expires_at
+WHERE public.idempotency_keys.expires_at <= now()`,
+		rec.Owner, rec.Key, rec.RequestHash, rec.Status, string(headers), rec.Body, rec.ExpiresAt.UTC())
+	if err != nil {
+		return IdempotencyRecord{}, fmt.Errorf("%s: insert: %w", op, err)
+	}
+
+	reserved, err := res.RowsAffected()
+	if err != nil {
+		return IdempotencyRecord{}, fmt.Errorf("%s: get rows affected: %w", op, err)
+	}
+	if reserved == 1 {
+		return rec, nil
+	}
+
+	existing, err := scanIdempotencyRecord(s.db.QueryRowContext(ctx, `
+SELECT owner, key, request_hash, status, headers, body, expires_at
+FROM public.idempotency_keys WHERE owner = $1 AND key = $2`, rec.Owner, rec.Key))
+	if err != nil {
+		// Released meanwhile, the caller may simply retry.
+		return IdempotencyRecord{}, fmt.Errorf("%s: select: %w", op, err)
+	}
+
+	return existing, fmt.Errorf("%s: %w", op, ErrIdempotencyKeyExists)
+}
+
+// CompleteIdempotencyKey stores the response of a reserved key.
+func (s *Storage) CompleteIdempotencyKey(ctx context.Context, rec IdempotencyRecord) (err error) {
+	const op = "storage.CompleteIdempotencyKey"
+
+	ctx, span := StartSpan(ctx, op)
+	defer func() { EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return fmt.Errorf("%s: db is nil", op)
+	}
+
+	headers, err := json.Marshal(rec.Headers)
+	if err != nil {
+		return fmt.Errorf("%s: encode headers: %w", op, err)
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+UPDATE public.idempotency_keys SET status = $3, headers = $4, body = $5, expires_at = $6
+WHERE owner = $1 AND key = $2`,
+		rec.Owner, rec.Key, rec.Status, string(headers), rec.Body, rec.ExpiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("%s: update: %w", op, err)
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey deletes a reservation that got no response
+// worth keeping, so the request can be retried with the same key.
+func (s *Storage) ReleaseIdempotencyKey(ctx context.Context, owner string, key string) (err error) {
+	const op = "storage.ReleaseIdempotencyKey"
+
+	ctx, span := StartSpan(ctx, op)
+	defer func() { EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return fmt.Errorf("%s: db is nil", op)
+	}
+
+	_, err = s.db.ExecContext(ctx, `DELETE FROM public.idempotency_keys WHERE owner = $1 AND key = $2 AND status = 0`, owner, key)
+	if err != nil {
+		return fmt.Errorf("%s: delete: %w", op, err)
+	}
+
+	return nil
+}
+
+// PurgeIdempotencyKeys deletes up to limit expired records and returns
+// how many were deleted.
+func (s *Storage) PurgeIdempotencyKeys(ctx context.Context, limit int) (_ int64, err error) {
+	const op = "storage.PurgeIdempotencyKeys"
+
+	ctx, span := StartSpan(ctx, op)
+	defer func() { EndSpan(span, err) }()
+
+	if s == nil || s.db == nil {
+		return 0, fmt.Errorf("%s: db is nil", op)
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+DELETE FROM public.idempotency_keys WHERE (owner, key) IN (
+    SELECT owner, key FROM public.idempotency_keys WHERE expires_at <= now() LIMIT $1 FOR UPDATE SKIP LOCKED
+)`, limit)
+	if err != nil {
+		return 0, fmt.Errorf("%s: delete: %w", op, err)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("%s: get rows affected: %w", op, err)
+	}
+
+	return rowsAffected, nil
+}
+
+// scanIdempotencyRecord reads a row of owner, key, request_hash, status,
+// headers, body and expires_at.
+func scanIdempotencyRecord(row interface{ Scan(dest ...any) error }) (IdempotencyRecord, error) {
+	var (
+		rec     IdempotencyRecord
+		headers string
+	)
+	if err := row.Scan(&rec.Owner, &rec.Key, &rec.RequestHash, &rec.Status, &headers, &rec.Body, &rec.ExpiresAt); err != nil {
+		return IdempotencyRecord{}, err
+	}
+	if err := json.Unmarshal([]byte(headers), &rec.Headers); err != nil {
+		return IdempotencyRecord{}, fmt.Errorf("decode headers: %w", err)
+	}
+
+	return rec, nil
+}
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Timeouts bounds how long each storage operation may run.
+// A zero value leaves the caller's deadline as is.
+type Timeouts struct {
+	Get        time.Duration
+	Save       time.Duration
+	Delete     time.Duration
+	Stats      time.Duration
+	Purge      time.Duration
+	SaveClicks time.Duration
+}
+
+type timeoutBackend struct {
+	Backend
+	timeouts Timeouts
+}
+
+// WithTimeouts wraps b so that every call gets the matching timeout from t
+// on top of whatever deadline the caller's context already has.
+func WithTimeouts(b Backend, t Timeouts) Backend {
+	return &timeoutBackend{Backend: b, timeouts: t}
+}
+
+func (b *timeoutBackend) SaveURL(ctx context.Context, urlToSave string, alias string, opts SaveOptions) (int64, error) {
+	ctx, cancel := withTimeout(ctx, b.timeouts.Save)
+	defer cancel()
+
+	id, err := b.Backend.SaveURL(ctx, urlToSave, alias, opts)
+
+	return id, contextErr(ctx, err)
+}
+
+func (b *timeoutBackend) SaveURLs(ctx context.Context, items []BatchItem, atomic bool) ([]BatchResult, error) {
+	ctx, cancel := withTimeout(ctx, b.timeouts.Save)
+	defer cancel()
+
+	results, err := b.Backend.SaveURLs(ctx, items, atomic)
+
+	return results, contextErr(ctx, err)
+}
+
+func (b *timeoutBackend) GetURL(ctx context.Context, alias string) (string, error) {
+	ctx, cancel := withTimeout(ctx, b.timeouts.Get)
+	defer cancel()
+
+	url, err := b.Backend.GetURL(ctx, alias)
+
+	return url, contextErr(ctx, err)
+}
+
+func (b *timeoutBackend) ResolveURL(ctx context.Context, alias string) (string, time.Time, error) {
+	ctx, cancel := withTimeout(ctx, b.timeouts.Get)
+	defer cancel()
+
+	url, expiresAt, err := b.Backend.ResolveURL(ctx, alias)
+
+	return url, expiresAt, contextErr(ctx, err)
+}
+
+func (b *timeoutBackend) GetLink(ctx context.Context, alias string, owner string) (Link, error) {
+	ctx, cancel := withTimeout(ctx, b.timeouts.Get)
+	defer cancel()
+
+	link, err := b.Backend.GetLink(ctx, alias, owner)
+
+	return link, contextErr(ctx, err)
+}
+
+func (b *timeoutBackend) FindURL(ctx context.Context, owner string, rawURL string) (Link, error) {
+	ctx, cancel := withTimeout(ctx, b.timeouts.Get)
+	defer cancel()
+
+	link, err := b.Backend.FindURL(ctx, owner, rawURL)
+
+	return link, contextErr(ctx, err)
+}
+
+// ListURLs shares the stats timeout, both read many rows per request.
+func (b *timeoutBackend) ListURLs(ctx context.Context, opts ListOptions) ([]Link, error) {
+	ctx, cancel := withTimeout(ctx, b.timeouts.Stats)
+	defer cancel()
+
+	links, err := b.Backend.ListURLs(ctx, opts)
+
+	return links, contextErr(ctx, err)
+}
+
+func (b *timeoutBackend) UpdateURL(ctx context.Context, alias string, upd URLUpdate) (int64, error) {
+	ctx, cancel := withTimeout(ctx, b.timeouts.Save)
+	defer cancel()
+
+	version, err := b.Backend.UpdateURL(ctx, alias, upd)
+
+	return version, contextErr(ctx, err)
+}
+
+func (b *timeoutBackend) DeleteURL(ctx context.Context, alias string, owner string) error {
+	ctx, cancel := withTimeout(ctx, b.timeouts.Delete)
+	defer cancel()
+
+	return contextErr(ctx, b.Backend.DeleteURL(ctx, alias, owner))
+}
+
+func (b *timeoutBackend) PurgeExpired(ctx context.Context, limit int) (int64, error) {
+	ctx, cancel := withTimeout(ctx, b.timeouts.Purge)
+	defer cancel()
+
+	purged, err := b.Backend.PurgeExpired(ctx, limit)
+
+	return purged, contextErr(ctx, err)
+}
+
+func (b *timeoutBackend) SaveClicks(ctx context.Context, clicks []Click) error {
+	ctx, cancel := withTimeout(ctx, b.timeouts.SaveClicks)
+	defer cancel()
+
+	return contextErr(ctx, b.Backend.SaveClicks(ctx, clicks))
+}
+
+func (b *timeoutBackend) URLStats(ctx context.Context, alias string, owner string, since time.Time, topReferrers int) (Stats, error) {
+	ctx, cancel := withTimeout(ctx, b.timeouts.Stats)
+	defer cancel()
+
+	stats, err := b.Backend.URLStats(ctx, alias, owner, since, topReferrers)
+
+	return stats, contextErr(ctx, err)
+}
+
+func (b *timeoutBackend) URLHistory(ctx context.Context, alias string, owner string) ([]Revision, error) {
+	ctx, cancel := withTimeout(ctx, b.timeouts.Get)
+	defer cancel()
+
+	history, err := b.Backend.URLHistory(ctx, alias, owner)
+
+	return history, contextErr(ctx, err)
+}
+
+func (b *timeoutBackend) SaveAPIKey(ctx context.Context, key APIKey) (int64, error) {
+	ctx, cancel := withTimeout(ctx, b.timeouts.Save)
+	defer cancel()
+
+	id, err := b.Backend.SaveAPIKey(ctx, key)
+
+	return id, contextErr(ctx, err)
+}
+
+func (b *timeoutBackend) GetAPIKey(ctx context.Context, hash string) (APIKey, error) {
+	ctx, cancel := withTimeout(ctx, b.timeouts.Get)
+	defer cancel()
+
+	key, err := b.Backend.GetAPIKey(ctx, hash)
+
+	return key, contextErr(ctx, err)
+}
+
+func (b *timeoutBackend) ListAPIKeys(ctx context.Context) ([]APIKey, error) {
+	ctx, cancel := withTimeout(ctx, b.timeouts.Get)
+	defer cancel()
+
+	keys, err := b.Backend.ListAPIKeys(ctx)
+
+	return keys, contextErr(ctx, err)
+}
+
+func (b *timeoutBackend) RevokeAPIKey(ctx context.Context, id int64) error {
+	ctx, cancel := withTimeout(ctx, b.timeouts.Delete)
+	defer cancel()
+
+	return contextErr(ctx, b.Backend.RevokeAPIKey(ctx, id))
+}
+
+func (b *timeoutBackend) TouchAPIKey(ctx context.Context, id int64, usedAt time.Time) error {
+	ctx, cancel := withTimeout(ctx, b.timeouts.Save)
+	defer cancel()
+
+	return contextErr(ctx, b.Backend.TouchAPIKey(ctx, id, usedAt))
+}
+
+func (b *timeoutBackend) ReserveIdempotencyKey(ctx context.Context, rec IdempotencyRecord) (IdempotencyRecord, error) {
+	ctx, cancel := withTimeout(ctx, b.timeouts.Save)
+	defer cancel()
+
+	existing, err := b.Backend.ReserveIdempotencyKey(ctx, rec)
+
+	return existing, contextErr(ctx, err)
+}
+
+func (b *timeoutBackend) CompleteIdempotencyKey(ctx context.Context, rec IdempotencyRecord) error {
+	ctx, cancel := withTimeout(ctx, b.timeouts.Save)
+	defer cancel()
+
+	return contextErr(ctx, b.Backend.CompleteIdempotencyKey(ctx, rec))
+}
+
+func (b *timeoutBackend) ReleaseIdempotencyKey(ctx context.Context, owner string, key string) error {
+	ctx, cancel := withTimeout(ctx, b.timeouts.Delete)
+	defer cancel()
+
+	return contextErr(ctx, b.Backend.ReleaseIdempotencyKey(ctx, owner, key))
+}
+
+func (b *timeoutBackend) PurgeIdempotencyKeys(ctx context.Context, limit int) (int64, error) {
+	ctx, cancel := withTimeout(ctx, b.timeouts.Purge)
+	defer cancel()
+
+	purged, err := b.Backend.PurgeIdempotencyKeys(ctx, limit)
+
+	return purged, contextErr(ctx, err)
+}
+
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// contextErr makes sure an error caused by ctx being done matches
+// context.DeadlineExceeded or context.Canceled. Some drivers report
+// a cancelled query with their own error instead.
+func contextErr(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || errors.Is(err, ctx.Err()) {
+		return err
+	}
+
+	return fmt.Errorf("%w: %w", err, ctx.Err())
+}
@@ -0,0 +1,46 @@
+package storage_test
+
+import (
+	"URL-shortener/internal/storage"
+	"URL-shortener/internal/storage/memory"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// slowBackend blocks until the context is done and then fails
+// with a driver-specific error, like lib/pq does for cancelled queries.
+type slowBackend struct {
+	storage.Backend
+}
+
+var errQueryCanceled = errors.New("pq: canceling statement due to user request")
+
+func (b slowBackend) GetURL(ctx context.Context, alias string) (string, error) {
+	<-ctx.Done()
+	return "", errQueryCanceled
+}
+
+func TestWithTimeouts(t *testing.T) {
+	st, err := memory.New("")
+	require.NoError(t, err)
+
+	b := storage.WithTimeouts(slowBackend{Backend: st}, storage.Timeouts{Get: 10 * time.Millisecond})
+
+	_, err = b.GetURL(context.Background(), "alias")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorIs(t, err, errQueryCanceled)
+
+	// A zero timeout leaves the call alone.
+	_, err = b.SaveURL(context.Background(), "https://google.com", "alias", storage.SaveOptions{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = b.GetURL(ctx, "alias")
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer follows the global provider, so spans are dropped
+// until tracing is set up.
+var tracer = otel.Tracer("URL-shortener/internal/storage")
+
+// StartSpan starts a span of the storage operation op as a child of ctx.
+func StartSpan(ctx context.Context, op string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, op, trace.WithSpanKind(trace.SpanKindClient))
+}
+
+// EndSpan records err and ends the span. Missing, expired, taken and
+// foreign links are regular answers and don't mark the span as failed.
+func EndSpan(span trace.Span, err error) {
+	defer span.End()
+
+	if err == nil {
+		return
+	}
+
+	span.RecordError(err)
+	for _, expected := range expectedErrs {
+		if errors.Is(err, expected) {
+			return
+		}
+	}
+	span.SetStatus(codes.Error, err.Error())
+}
+
+var expectedErrs = []error{ErrURLNotFound, ErrURLExpired, ErrURLExists, ErrNotOwner, ErrVersionMismatch, ErrAPIKeyNotFound}
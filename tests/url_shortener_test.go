@@ -37,33 +37,37 @@ func TestURLShortener_HappyPath(t *testing.T) {
 		}).
 		WithBasicAuth("admin", "admin").
 		Expect().
-		Status(200).
+		Status(http.StatusCreated).
 		JSON().Object().
 		ContainsKey("alias")
 }
 
 func TestURLShortener_SaveRedirect(t *testing.T) {
 	testCases := []struct {
-		name  string
-		url   string
-		alias string
-		error string
+		name   string
+		url    string
+		alias  string
+		error  string
+		status int
 	}{
 		{
-			name:  "Valid URL",
-			url:   gofakeit.URL(),
-			alias: gofakeit.Word() + gofakeit.Word(),
+			name:   "Valid URL",
+			url:    gofakeit.URL(),
+			alias:  gofakeit.Word() + gofakeit.Word(),
+			status: http.StatusCreated,
 		},
 		{
-			name:  "Invalid URL",
-			url:   "invalid url",
-			alias: gofakeit.Word(),
-			error: "Field URL is not a valid URL",
+			name:   "Invalid URL",
+			url:    "invalid url",
+			alias:  gofakeit.Word(),
+			error:  "Field URL is not a valid URL",
+			status: http.StatusUnprocessableEntity,
 		},
 		{
-			name:  "Invalid Alias",
-			url:   gofakeit.URL(),
-			alias: "",
+			name:   "Invalid Alias",
+			url:    gofakeit.URL(),
+			alias:  "",
+			status: http.StatusCreated,
 		},
 	}
 
@@ -81,7 +85,7 @@ func TestURLShortener_SaveRedirect(t *testing.T) {
 				Alias: tc.alias,
 			}).
 				WithBasicAuth("admin", "admin").
-				Expect().Status(http.StatusOK).JSON().Object()
+				Expect().Status(tc.status).JSON().Object()
 
 			if tc.error != "" {
 				resp.NotContainsKey("alias")
@@ -130,9 +134,9 @@ func testRedirect(t *testing.T, alias, urlToRedirect string) {
 }
 
 func testRedirectNotFound(t *testing.T, e *httpexpect.Expect, alias string) {
-	// После удаления URL, GET запрос должен вернуть JSON с ошибкой "URL not found"
+	// После удаления URL, GET запрос должен вернуть 404 и JSON с ошибкой "URL not found"
 	resp := e.GET("/" + alias).
-		Expect().Status(http.StatusOK).JSON().Object()
+		Expect().Status(http.StatusNotFound).JSON().Object()
 
 	resp.Value("status").String().IsEqual("ERROR")
 	resp.Value("error").String().IsEqual("URL not found")